@@ -0,0 +1,144 @@
+package ast
+
+import (
+	"regexp"
+	"strings"
+)
+
+// FormatOptions represents options to format a template.
+type FormatOptions struct {
+	// Indent is the indentation of nested block tags (defaults to two spaces)
+	Indent string
+
+	// Quote is the preferred string literals delimiter: '"' (default) or '\''
+	Quote byte
+
+	// KeepIndent disables block tags re-indentation
+	KeepIndent bool
+}
+
+var (
+	// matches whitespaces ending the first line of a content
+	rFirstLineBlanks = regexp.MustCompile(`^[ \t]+(\r?\n)`)
+
+	// matches a content starting with an empty line
+	rFirstLineEmpty = regexp.MustCompile(`^[ \t]*\r?\n`)
+)
+
+// Format returns a normalized handlebars template representation of given AST, that was parsed from given source.
+//
+// Whitespaces inside mustaches, string literals quotes and hash pairs spacing are normalized. Standalone
+// block and comment tags are re-indented according to their nesting depth. Content is rendered as is,
+// so only whitespaces that are stripped from output are modified.
+func Format(node Node, source string, opts *FormatOptions) string {
+	format := FormatOptions{}
+	if opts != nil {
+		format = *opts
+	}
+
+	if format.Indent == "" {
+		format.Indent = "  "
+	}
+
+	if (format.Quote != '"') && (format.Quote != '\'') {
+		format.Quote = '"'
+	}
+
+	visitor := newPrintOriginalVisitor(source)
+	visitor.format = &format
+
+	node.Accept(visitor)
+
+	return visitor.output()
+}
+
+// isTag returns true if given node is a statement that can stand alone on its line, without being indented in output
+func isTag(node Node) bool {
+	if block, ok := AsBlock(node); ok {
+		return !block.Raw
+	}
+
+	_, ok := node.(*CommentStatement)
+	return ok
+}
+
+// inverseNext returns the statement following the `else` tag that opens given inverse program, and the tilde of that tag
+func inverseNext(inverse *Program) (Node, bool) {
+	tilde := (inverse.Strip != nil) && inverse.Strip.Close
+
+	if inverse.Chained {
+		block := inverse.Body[0].(*BlockStatement)
+		tilde = (block.OpenStrip != nil) && block.OpenStrip.Close
+
+		inverse, _ = blockPrograms(block)
+	}
+
+	if (inverse == nil) || (len(inverse.Body) == 0) {
+		return nil, tilde
+	}
+
+	return inverse.Body[0], tilde
+}
+
+// strippedAfter returns true if given statement, following a tag without a right tilde, is a content which
+// first line was stripped because that tag is standalone
+func strippedAfter(next Node, tilde bool) bool {
+	content, ok := next.(*ContentStatement)
+	return ok && !tilde && content.RightStripped && rFirstLineEmpty.MatchString(content.Original)
+}
+
+// formatStatement sets up formatting of statement at given index in program body
+func (v *printOriginalVisitor) formatStatement(node *Program, i int, closeNext Node, closeTilde bool) {
+	body := node.Body
+
+	v.next = nil
+	if i < len(body)-1 {
+		v.next = body[i+1]
+	}
+
+	v.first = (node == v.root) && (i == 0)
+
+	v.afterTag = (node != v.root) && (i == 0)
+	if i > 0 {
+		v.afterTag = isTag(body[i-1])
+	}
+
+	v.beforeTag, v.standalone = -1, false
+
+	if (v.next != nil) && isTag(v.next) {
+		v.beforeTag = v.depth
+
+		if tag, ok := AsBlock(v.next); ok {
+			first, _ := blockPrograms(tag)
+			if (first != nil) && (len(first.Body) > 0) {
+				v.standalone = strippedAfter(first.Body[0], (tag.OpenStrip != nil) && tag.OpenStrip.Close)
+			}
+		} else if tag, ok := v.next.(*CommentStatement); ok && (i < len(body)-2) {
+			v.standalone = strippedAfter(body[i+2], (tag.Strip != nil) && tag.Strip.Close)
+		}
+	} else if (v.next == nil) && (node != v.root) {
+		// followed by the `else` or close tag of parent block
+		v.beforeTag = v.depth - 1
+		v.standalone = strippedAfter(closeNext, closeTilde)
+	}
+}
+
+// formatContent returns given content string with whitespaces stripped from output normalized
+func (v *printOriginalVisitor) formatContent(str string, node *ContentStatement) string {
+	// trailing whitespaces after a tag
+	if v.afterTag && node.RightStripped {
+		str = rFirstLineBlanks.ReplaceAllString(str, "$1")
+	}
+
+	// indentation before a tag
+	if (v.beforeTag >= 0) && !v.format.KeepIndent && (node.LeftStripped || v.standalone) {
+		i := strings.LastIndex(str, "\n")
+		if (i >= 0) || v.first {
+			if strings.Trim(str[i+1:], " \t") == "" {
+				str = str[:i+1] + strings.Repeat(v.format.Indent, v.beforeTag)
+			}
+		}
+	}
+
+	return str
+}
@@ -33,8 +33,11 @@ type Visitor interface {
 
 	// statements
 	VisitMustache(*MustacheStatement) interface{}
+	VisitDecorator(*Decorator) interface{}
 	VisitBlock(*BlockStatement) interface{}
+	VisitDecoratorBlock(*DecoratorBlock) interface{}
 	VisitPartial(*PartialStatement) interface{}
+	VisitPartialBlock(*PartialBlock) interface{}
 	VisitContent(*ContentStatement) interface{}
 	VisitComment(*CommentStatement) interface{}
 
@@ -103,12 +106,26 @@ const (
 
 	// NodeHashPair is the hash pair node
 	NodeHashPair
+
+	// NodeDecoratorBlock is the decorator block node
+	NodeDecoratorBlock
+
+	// NodePartialBlock is the partial block node
+	NodePartialBlock
+
+	// NodeDecorator is the decorator node
+	NodeDecorator
 )
 
 // Loc represents the position of a parsed node in source file.
 type Loc struct {
-	Pos  int // Byte position
-	Line int // Line number
+	Pos    int // Byte position
+	Line   int // Line number
+	Column int // Column number, in runes
+
+	End       int // Byte position following the node
+	EndLine   int // Line number of End position
+	EndColumn int // Column number of End position, in runes
 }
 
 // Location returns itself, and permits struct includers to satisfy that part of Node interface.
@@ -168,7 +185,7 @@ type Program struct {
 func NewProgram(pos int, line int) *Program {
 	return &Program{
 		NodeType: NodeProgram,
-		Loc:      Loc{pos, line},
+		Loc:      Loc{Pos: pos, Line: line},
 	}
 }
 
@@ -207,7 +224,7 @@ type MustacheStatement struct {
 func NewMustacheStatement(pos int, line int, unescaped bool) *MustacheStatement {
 	return &MustacheStatement{
 		NodeType:  NodeMustache,
-		Loc:       Loc{pos, line},
+		Loc:       Loc{Pos: pos, Line: line},
 		Unescaped: unescaped,
 	}
 }
@@ -222,6 +239,36 @@ func (node *MustacheStatement) Accept(visitor Visitor) interface{} {
 	return visitor.VisitMustache(node)
 }
 
+//
+// Decorator
+//
+
+// Decorator represents a decorator node, eg: {{* name param}}
+//
+// It is parsed as a mustache, but it outputs nothing: the decorator is applied when the enclosing program is evaluated.
+type Decorator struct {
+	MustacheStatement
+}
+
+// NewDecorator instanciates a new decorator node from given parsed mustache.
+func NewDecorator(mustache *MustacheStatement) *Decorator {
+	result := &Decorator{MustacheStatement: *mustache}
+	result.NodeType = NodeDecorator
+	result.Unescaped = false
+
+	return result
+}
+
+// String returns a string representation of receiver that can be used for debugging.
+func (node *Decorator) String() string {
+	return fmt.Sprintf("Decorator{Pos: %d}", node.Loc.Pos)
+}
+
+// Accept is the receiver entry point for visitors.
+func (node *Decorator) Accept(visitor Visitor) interface{} {
+	return visitor.VisitDecorator(node)
+}
+
 //
 // Block Statement
 //
@@ -236,6 +283,9 @@ type BlockStatement struct {
 	Program *Program
 	Inverse *Program
 
+	// raw block: {{{{raw}}}} ... {{{{/raw}}}}
+	Raw bool
+
 	// whitespace management
 	OpenStrip    *Strip
 	InverseStrip *Strip
@@ -246,7 +296,7 @@ type BlockStatement struct {
 func NewBlockStatement(pos int, line int) *BlockStatement {
 	return &BlockStatement{
 		NodeType: NodeBlock,
-		Loc:      Loc{pos, line},
+		Loc:      Loc{Pos: pos, Line: line},
 	}
 }
 
@@ -260,6 +310,51 @@ func (node *BlockStatement) Accept(visitor Visitor) interface{} {
 	return visitor.VisitBlock(node)
 }
 
+//
+// Decorator Block
+//
+
+// DecoratorBlock represents a decorator block node, eg: {{#*inline "name"}} ... {{/inline}}
+//
+// It is parsed as a block, but its program is not rendered in place: the decorator is applied when the enclosing
+// program is evaluated.
+type DecoratorBlock struct {
+	BlockStatement
+}
+
+// NewDecoratorBlock instanciates a new decorator block node from given parsed block.
+func NewDecoratorBlock(block *BlockStatement) *DecoratorBlock {
+	result := &DecoratorBlock{BlockStatement: *block}
+	result.NodeType = NodeDecoratorBlock
+
+	return result
+}
+
+// String returns a string representation of receiver that can be used for debugging.
+func (node *DecoratorBlock) String() string {
+	return fmt.Sprintf("DecoratorBlock{Pos: %d}", node.Loc.Pos)
+}
+
+// Accept is the receiver entry point for visitors.
+func (node *DecoratorBlock) Accept(visitor Visitor) interface{} {
+	return visitor.VisitDecoratorBlock(node)
+}
+
+// AsBlock returns given node as a block statement, with a boolean set to false if it is not a block, a decorator
+// block or a partial block.
+func AsBlock(node Node) (*BlockStatement, bool) {
+	switch n := node.(type) {
+	case *BlockStatement:
+		return n, true
+	case *DecoratorBlock:
+		return &n.BlockStatement, true
+	case *PartialBlock:
+		return &n.BlockStatement, true
+	}
+
+	return nil, false
+}
+
 //
 // Partial Statement
 //
@@ -282,7 +377,7 @@ type PartialStatement struct {
 func NewPartialStatement(pos int, line int) *PartialStatement {
 	return &PartialStatement{
 		NodeType: NodePartial,
-		Loc:      Loc{pos, line},
+		Loc:      Loc{Pos: pos, Line: line},
 	}
 }
 
@@ -296,6 +391,36 @@ func (node *PartialStatement) Accept(visitor Visitor) interface{} {
 	return visitor.VisitPartial(node)
 }
 
+//
+// Partial Block
+//
+
+// PartialBlock represents a partial block node, eg: {{#> layout}} ... {{/layout}}
+//
+// It is parsed as a block: the expression path is the partial name, and expression params and hash are the partial
+// params and hash. Its program is rendered in place of a missing partial, and by {{> @partial-block}} inside the partial.
+type PartialBlock struct {
+	BlockStatement
+}
+
+// NewPartialBlock instanciates a new partial block node from given parsed block.
+func NewPartialBlock(block *BlockStatement) *PartialBlock {
+	result := &PartialBlock{BlockStatement: *block}
+	result.NodeType = NodePartialBlock
+
+	return result
+}
+
+// String returns a string representation of receiver that can be used for debugging.
+func (node *PartialBlock) String() string {
+	return fmt.Sprintf("PartialBlock{Name:%s, Pos:%d}", node.Expression.Path, node.Loc.Pos)
+}
+
+// Accept is the receiver entry point for visitors.
+func (node *PartialBlock) Accept(visitor Visitor) interface{} {
+	return visitor.VisitPartialBlock(node)
+}
+
 //
 // Content Statement
 //
@@ -317,7 +442,7 @@ type ContentStatement struct {
 func NewContentStatement(pos int, line int, val string) *ContentStatement {
 	return &ContentStatement{
 		NodeType: NodeContent,
-		Loc:      Loc{pos, line},
+		Loc:      Loc{Pos: pos, Line: line},
 
 		Value:    val,
 		Original: val,
@@ -353,7 +478,7 @@ type CommentStatement struct {
 func NewCommentStatement(pos int, line int, val string) *CommentStatement {
 	return &CommentStatement{
 		NodeType: NodeComment,
-		Loc:      Loc{pos, line},
+		Loc:      Loc{Pos: pos, Line: line},
 
 		Value: val,
 	}
@@ -387,7 +512,7 @@ type Expression struct {
 func NewExpression(pos int, line int) *Expression {
 	return &Expression{
 		NodeType: NodeExpression,
-		Loc:      Loc{pos, line},
+		Loc:      Loc{Pos: pos, Line: line},
 	}
 }
 
@@ -505,7 +630,7 @@ type SubExpression struct {
 func NewSubExpression(pos int, line int) *SubExpression {
 	return &SubExpression{
 		NodeType: NodeSubExpression,
-		Loc:      Loc{pos, line},
+		Loc:      Loc{Pos: pos, Line: line},
 	}
 }
 
@@ -539,7 +664,7 @@ type PathExpression struct {
 func NewPathExpression(pos int, line int, data bool) *PathExpression {
 	result := &PathExpression{
 		NodeType: NodePath,
-		Loc:      Loc{pos, line},
+		Loc:      Loc{Pos: pos, Line: line},
 
 		Data: data,
 	}
@@ -602,7 +727,7 @@ type StringLiteral struct {
 func NewStringLiteral(pos int, line int, val string) *StringLiteral {
 	return &StringLiteral{
 		NodeType: NodeString,
-		Loc:      Loc{pos, line},
+		Loc:      Loc{Pos: pos, Line: line},
 
 		Value: val,
 	}
@@ -635,7 +760,7 @@ type BooleanLiteral struct {
 func NewBooleanLiteral(pos int, line int, val bool, original string) *BooleanLiteral {
 	return &BooleanLiteral{
 		NodeType: NodeBoolean,
-		Loc:      Loc{pos, line},
+		Loc:      Loc{Pos: pos, Line: line},
 
 		Value:    val,
 		Original: original,
@@ -679,7 +804,7 @@ type NumberLiteral struct {
 func NewNumberLiteral(pos int, line int, val float64, isInt bool, original string) *NumberLiteral {
 	return &NumberLiteral{
 		NodeType: NodeNumber,
-		Loc:      Loc{pos, line},
+		Loc:      Loc{Pos: pos, Line: line},
 
 		Value:    val,
 		IsInt:    isInt,
@@ -731,7 +856,7 @@ type Hash struct {
 func NewHash(pos int, line int) *Hash {
 	return &Hash{
 		NodeType: NodeHash,
-		Loc:      Loc{pos, line},
+		Loc:      Loc{Pos: pos, Line: line},
 	}
 }
 
@@ -771,7 +896,7 @@ type HashPair struct {
 func NewHashPair(pos int, line int) *HashPair {
 	return &HashPair{
 		NodeType: NodeHashPair,
-		Loc:      Loc{pos, line},
+		Loc:      Loc{Pos: pos, Line: line},
 	}
 }
 
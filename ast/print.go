@@ -82,11 +82,39 @@ func (v *printVisitor) VisitMustache(node *MustacheStatement) interface{} {
 	return nil
 }
 
+// VisitDecorator implements corresponding Visitor interface method
+func (v *printVisitor) VisitDecorator(node *Decorator) interface{} {
+	v.indent()
+	v.str("{{* ")
+
+	node.Expression.Accept(v)
+
+	v.str(" }}")
+	v.nl()
+
+	return nil
+}
+
 // VisitBlock implements corresponding Visitor interface method
 func (v *printVisitor) VisitBlock(node *BlockStatement) interface{} {
+	return v.printBlock(node, "BLOCK:")
+}
+
+// VisitDecoratorBlock implements corresponding Visitor interface method
+func (v *printVisitor) VisitDecoratorBlock(node *DecoratorBlock) interface{} {
+	return v.printBlock(&node.BlockStatement, "DIRECTIVE BLOCK:")
+}
+
+// VisitPartialBlock implements corresponding Visitor interface method
+func (v *printVisitor) VisitPartialBlock(node *PartialBlock) interface{} {
+	return v.printBlock(&node.BlockStatement, "PARTIAL BLOCK:")
+}
+
+// printBlock prints given block with given header
+func (v *printVisitor) printBlock(node *BlockStatement, header string) interface{} {
 	v.inBlock = true
 
-	v.line("BLOCK:")
+	v.line(header)
 	v.depth++
 
 	node.Expression.Accept(v)
@@ -1,53 +1,209 @@
 package ast
 
 import (
-	"fmt"
+	"bytes"
+	"regexp"
 	"strings"
 )
 
-// printOriginalVistor implements the Visitor interface to print a AST.
-type printOriginalVistor struct {
-	buf   string
+// printOriginalVisitor implements the Visitor interface to print an AST as a handlebars template.
+//
+// When the source the AST was parsed from is known, unmodified nodes are printed exactly as they
+// appear in that source: the text found between child nodes (mustache delimiters, whitespace,
+// strip markers, parentheses...) is reused as long as it is still consistent with the node.
+// Otherwise, a canonical handlebars representation is generated.
+type printOriginalVisitor struct {
+	buf    bytes.Buffer
+	source string
+	root   *Program
+
+	// next visited block is an `else` chained block
+	chained bool
+
+	// next visited content is followed by a mustache
+	beforeMustache bool
+
+	// visiting raw block content
+	raw bool
+
+	// formatting options, if formatting
+	format *FormatOptions
+
+	// nesting depth of visited statements
 	depth int
 
-	original bool
-	inBlock  bool
+	// next visited content follows a block or comment tag
+	afterTag bool
+
+	// next visited content precedes a block or comment tag with that depth, or -1
+	beforeTag int
+
+	// that tag is standalone
+	standalone bool
+
+	// next visited content starts the template
+	first bool
+
+	// statement following next visited statement
+	next Node
+
+	// statement following the tag that closes next visited program, and tilde of that tag
+	closeNext  Node
+	closeTilde bool
 }
 
-func newPrintOriginalVisitor() *printOriginalVistor {
-	return &printOriginalVistor{}
+var (
+	rCommentOpen  = regexp.MustCompile(`^\{\{~?!-?-?`)
+	rCommentClose = regexp.MustCompile(`-?-?~?\}\}$`)
+)
+
+func newPrintOriginalVisitor(source string) *printOriginalVisitor {
+	return &printOriginalVisitor{
+		source:    source,
+		beforeTag: -1,
+	}
 }
 
-// Print returns a string representation of given AST, that can be used for debugging purpose.
+// PrintOriginal returns a handlebars template representation of given AST.
+//
+// The original formatting is lost, use PrintSource() to preserve it.
 func PrintOriginal(node Node) string {
-	visitor := newPrintOriginalVisitor()
+	return PrintSource(node, "")
+}
+
+// PrintSource returns a handlebars template representation of given AST, that was parsed from given source.
+//
+// Unmodified nodes are printed exactly as they appear in source, so that printing an unmodified AST
+// returns the source. Modified nodes are printed in a canonical form.
+func PrintSource(node Node, source string) string {
+	visitor := newPrintOriginalVisitor(source)
 	node.Accept(visitor)
 	return visitor.output()
 }
 
-func (v *printOriginalVistor) output() string {
-	return v.buf
+func (v *printOriginalVisitor) output() string {
+	return v.buf.String()
+}
+
+func (v *printOriginalVisitor) str(val string) {
+	v.buf.WriteString(val)
 }
 
-func (v *printOriginalVistor) indent() {
-	for i := 0; i < v.depth; {
-		v.buf += "  "
-		i++
+// original returns the source of given node, with a boolean set to false if not available
+func (v *printOriginalVisitor) original(node Node) (string, bool) {
+	loc := node.Location()
+	if (v.source == "") || (loc.Pos < 0) || (loc.End <= loc.Pos) || (loc.End > len(v.source)) {
+		return "", false
 	}
+
+	return v.source[loc.Pos:loc.End], true
 }
 
-func (v *printOriginalVistor) str(val string) {
-	v.buf += val
+// spanned returns true if given node and its children can be located in source
+func (v *printOriginalVisitor) spanned(node Node, children []Node) bool {
+	loc := node.Location()
+	if (v.source == "") || (loc.Pos < 0) || (loc.End < loc.Pos) || (loc.End > len(v.source)) {
+		return false
+	}
+
+	pos := loc.Pos
+	for _, child := range children {
+		childLoc := child.Location()
+		if (childLoc.Pos < pos) || (childLoc.End <= childLoc.Pos) || (childLoc.End > loc.End) {
+			return false
+		}
+		pos = childLoc.End
+	}
+
+	return true
 }
 
-func (v *printOriginalVistor) nl() {
-	v.str("\n")
+// gap prints source between given positions if it matches one of given canonical forms, ignoring
+// whitespaces. Otherwise the first canonical form is printed.
+//
+// When formatting, the matching canonical form is printed instead of source.
+func (v *printOriginalVisitor) gap(spanned bool, from int, to int, canonical ...string) {
+	str := canonical[0]
+
+	if spanned && (from <= to) {
+		for _, c := range canonical {
+			if stripSpaces(v.source[from:to]) == stripSpaces(c) {
+				if v.format == nil {
+					v.str(v.source[from:to])
+					return
+				}
+
+				str = c
+				break
+			}
+		}
+	}
+
+	v.str(str)
 }
 
-func (v *printOriginalVistor) line(val string) {
-	v.indent()
-	v.str(val)
-	v.nl()
+// stripSpaces removes all whitespaces from given string
+func stripSpaces(str string) string {
+	return strings.Join(strings.Fields(str), "")
+}
+
+// tilde returns the strip marker if given flag is set
+func tilde(strip bool) string {
+	if strip {
+		return "~"
+	}
+	return ""
+}
+
+// exprChildren returns expression children nodes in source order
+func exprChildren(path Node, params []Node, hash *Hash) []Node {
+	var result []Node
+
+	if path != nil {
+		result = append(result, path)
+	}
+
+	result = append(result, params...)
+
+	if hash != nil {
+		result = append(result, hash)
+	}
+
+	return result
+}
+
+// blockPrograms returns block programs in source order
+func blockPrograms(node *BlockStatement) (*Program, *Program) {
+	first, second := node.Program, node.Inverse
+
+	if (first == nil) || ((second != nil) && !second.Chained && (second.Pos < first.Pos)) {
+		first, second = second, first
+	}
+
+	return first, second
+}
+
+// nameOriginal returns the original string representation of a block name
+func nameOriginal(node Node) string {
+	if path, ok := node.(*PathExpression); ok {
+		return path.Original
+	}
+
+	return PrintOriginal(node)
+}
+
+// quote returns a handlebars string literal for given value, preferably delimited with given quote
+func quote(val string, quote byte) string {
+	delim, other := string(quote), "'"
+	if delim == other {
+		other = "\""
+	}
+
+	if strings.Contains(val, delim) && !strings.Contains(val, other) {
+		delim = other
+	}
+
+	return delim + strings.Replace(val, delim, "\\"+delim, -1) + delim
 }
 
 //
@@ -57,101 +213,282 @@ func (v *printOriginalVistor) line(val string) {
 // Statements
 
 // VisitProgram implements corresponding Visitor interface method
-func (v *printOriginalVistor) VisitProgram(node *Program) interface{} {
-	if len(node.BlockParams) > 0 {
-		v.line("BLOCK PARAMS: [ " + strings.Join(node.BlockParams, " ") + " ]")
+func (v *printOriginalVisitor) VisitProgram(node *Program) interface{} {
+	spanned := v.spanned(node, node.Body)
+	pos := node.Pos
+
+	if v.root == nil {
+		v.root = node
 	}
 
-	for _, n := range node.Body {
+	// set by parent block
+	closeNext, closeTilde := v.closeNext, v.closeTilde
+
+	for i, n := range node.Body {
+		if spanned {
+			// only escape characters are found between statements
+			if str := v.source[pos:n.Location().Pos]; strings.Trim(str, "\\") == "" {
+				v.str(str)
+			}
+			pos = n.Location().End
+		}
+
+		if node.Chained {
+			v.chained = true
+		}
+
+		v.beforeMustache = (i < len(node.Body)-1) || (node != v.root)
+
+		if v.format != nil {
+			v.formatStatement(node, i, closeNext, closeTilde)
+		}
+
 		n.Accept(v)
 	}
 
+	if spanned {
+		if str := v.source[pos:node.End]; strings.Trim(str, "\\") == "" {
+			v.str(str)
+		}
+	}
+
 	return nil
 }
 
 // VisitMustache implements corresponding Visitor interface method
-func (v *printOriginalVistor) VisitMustache(node *MustacheStatement) interface{} {
-	v.str("{{")
+func (v *printOriginalVisitor) VisitMustache(node *MustacheStatement) interface{} {
+	return v.printMustache(node, "")
+}
+
+// VisitDecorator implements corresponding Visitor interface method
+func (v *printOriginalVisitor) VisitDecorator(node *Decorator) interface{} {
+	return v.printMustache(&node.MustacheStatement, "*")
+}
+
+// printMustache prints given mustache, opened with given marker
+func (v *printOriginalVisitor) printMustache(node *MustacheStatement, marker string) interface{} {
+	strip := node.Strip
+	if strip == nil {
+		strip = &Strip{}
+	}
+
+	open := []string{"{{" + tilde(strip.Open) + marker}
+	close := []string{tilde(strip.Close) + "}}"}
+
+	if node.Unescaped {
+		open = []string{"{{" + tilde(strip.Open) + "{", "{{" + tilde(strip.Open) + "&", open[0]}
+		close = []string{"}" + close[0], close[0]}
+	}
+
+	spanned := v.spanned(node, []Node{node.Expression})
+
+	v.gap(spanned, node.Pos, node.Expression.Pos, open...)
 	node.Expression.Accept(v)
-	v.str("}}")
+	v.gap(spanned, node.Expression.End, node.End, close...)
+
 	return nil
 }
 
 // VisitBlock implements corresponding Visitor interface method
-func (v *printOriginalVistor) VisitBlock(node *BlockStatement) interface{} {
-	v.inBlock = true
-	// v.str("{{#")
-	v.depth++
+func (v *printOriginalVisitor) VisitBlock(node *BlockStatement) interface{} {
+	return v.printBlock(node, "#")
+}
+
+// VisitDecoratorBlock implements corresponding Visitor interface method
+func (v *printOriginalVisitor) VisitDecoratorBlock(node *DecoratorBlock) interface{} {
+	return v.printBlock(&node.BlockStatement, "#*")
+}
+
+// VisitPartialBlock implements corresponding Visitor interface method
+func (v *printOriginalVisitor) VisitPartialBlock(node *PartialBlock) interface{} {
+	return v.printBlock(&node.BlockStatement, "#>")
+}
+
+// printBlock prints given block, opened with given marker when it is not raw, chained or inverted
+func (v *printOriginalVisitor) printBlock(node *BlockStatement, marker string) interface{} {
+	chained := v.chained
+	v.chained = false
+
+	first, second := blockPrograms(node)
+
+	children := []Node{node.Expression}
+	if first != nil {
+		children = append(children, first)
+	}
+	if second != nil {
+		children = append(children, second)
+	}
+
+	spanned := v.spanned(node, children)
+
+	openStrip, closeStrip := node.OpenStrip, node.CloseStrip
+	if openStrip == nil {
+		openStrip = &Strip{}
+	}
+	if closeStrip == nil {
+		closeStrip = &Strip{}
+	}
+
+	// open
+	var open, openClose string
+
+	switch {
+	case node.Raw:
+		open, openClose = "{{{{", "}}}}"
+	case chained:
+		open = "{{" + tilde(openStrip.Open) + "else "
+	case first == node.Inverse:
+		open = "{{" + tilde(openStrip.Open) + "^"
+	default:
+		open = "{{" + tilde(openStrip.Open) + marker
+	}
 
-	v.str("{{#")
+	if !node.Raw {
+		if (first != nil) && (len(first.BlockParams) > 0) {
+			openClose = " as |" + strings.Join(first.BlockParams, " ") + "|"
+		}
+		openClose += tilde(openStrip.Close) + "}}"
+	}
+
+	v.gap(spanned, node.Pos, node.Expression.Pos, open)
 	node.Expression.Accept(v)
-	v.str("}}")
 
-	if node.Program != nil {
-		// v.line("PROGRAM:")
+	end := node.Expression.End
+
+	// programs of an `else` chained block are printed at the depth of their parent block
+	if !chained && !node.Raw {
 		v.depth++
-		node.Program.Accept(v)
-		v.depth--
+		defer func() { v.depth-- }()
 	}
 
-	if node.Inverse != nil {
-		// if node.Program != nil {
-		// 	v.depth++
-		// }
+	// statement following the close tag
+	closeNext, closeTilde := v.closeNext, v.closeTilde
+	if !chained {
+		closeNext, closeTilde = v.next, closeStrip.Close
 
-		v.str("{{else}}")
-		v.depth++
-		node.Inverse.Accept(v)
-		v.depth--
+		if (second != nil) && second.Chained {
+			// the close tag of an `else` chain does not strip its indentation when standalone
+			closeNext = nil
+		}
+	}
 
-		// if node.Program != nil {
-		// 	v.depth--
-		// }
+	if first != nil {
+		v.gap(spanned, end, first.Pos, openClose)
+
+		v.closeNext, v.closeTilde = closeNext, closeTilde
+		if second != nil {
+			v.closeNext, v.closeTilde = inverseNext(second)
+		}
+
+		v.raw = node.Raw
+		first.Accept(v)
+		v.raw = false
+
+		end = first.End
 	}
-	if node.Expression.Path != nil {
-		if p, ok := node.Expression.Path.(*PathExpression); ok {
-			v.str(fmt.Sprintf("{{/%s}}", p.Original))
+
+	if second != nil {
+		if second.Chained {
+			v.gap(spanned, end, second.Pos, "")
+		} else {
+			strip := second.Strip
+			if strip == nil {
+				strip = &Strip{}
+			}
+
+			inverse := "{{" + tilde(strip.Open) + "else" + tilde(strip.Close) + "}}"
+			v.gap(spanned, end, second.Pos, inverse, "{{"+tilde(strip.Open)+"^"+tilde(strip.Close)+"}}")
 		}
+
+		v.closeNext, v.closeTilde = closeNext, closeTilde
+
+		second.Accept(v)
+		end = second.End
 	}
 
-	v.inBlock = false
+	// close
+	switch {
+	case chained:
+		v.gap(spanned, end, node.End, "")
+	case node.Raw:
+		v.gap(spanned, end, node.End, "{{{{/"+nameOriginal(node.Expression.Path)+"}}}}")
+	default:
+		v.gap(spanned, end, node.End, "{{"+tilde(closeStrip.Open)+"/"+nameOriginal(node.Expression.Path)+tilde(closeStrip.Close)+"}}")
+	}
 
 	return nil
 }
 
 // VisitPartial implements corresponding Visitor interface method
-func (v *printOriginalVistor) VisitPartial(node *PartialStatement) interface{} {
-	// v.indent()
-	v.str("{{> PARTIAL:")
+func (v *printOriginalVisitor) VisitPartial(node *PartialStatement) interface{} {
+	strip := node.Strip
+	if strip == nil {
+		strip = &Strip{}
+	}
 
-	v.original = true
-	node.Name.Accept(v)
-	v.original = false
+	children := exprChildren(node.Name, node.Params, node.Hash)
+	spanned := v.spanned(node, children)
 
-	if len(node.Params) > 0 {
-		v.str(" ")
-		node.Params[0].Accept(v)
-	}
+	v.gap(spanned, node.Pos, node.Name.Location().Pos, "{{"+tilde(strip.Open)+"> ")
 
-	// hash
-	if node.Hash != nil {
-		v.str(" ")
-		node.Hash.Accept(v)
+	for i, n := range children {
+		if i > 0 {
+			v.gap(spanned, children[i-1].Location().End, n.Location().Pos, " ")
+		}
+		n.Accept(v)
 	}
 
-	v.str(" }}")
+	v.gap(spanned, children[len(children)-1].Location().End, node.End, tilde(strip.Close)+"}}")
+
 	return nil
 }
 
 // VisitContent implements corresponding Visitor interface method
-func (v *printOriginalVistor) VisitContent(node *ContentStatement) interface{} {
-	v.str(node.Original)
+func (v *printOriginalVisitor) VisitContent(node *ContentStatement) interface{} {
+	if str, ok := v.original(node); (ok && (str == node.Original)) || v.raw {
+		if (v.format != nil) && !v.raw {
+			v.str(v.formatContent(node.Original, node))
+		} else {
+			v.str(node.Original)
+		}
+	} else {
+		// escape mustaches
+		str := strings.Replace(node.Original, "{{", "\\{{", -1)
+
+		// a trailing escape character must not escape next mustache
+		if v.beforeMustache && strings.HasSuffix(str, "\\") {
+			str += "\\"
+		}
+
+		v.str(str)
+	}
+
 	return nil
 }
 
 // VisitComment implements corresponding Visitor interface method
-func (v *printOriginalVistor) VisitComment(node *CommentStatement) interface{} {
-	v.line("{{! '" + node.Value + "' }}")
+func (v *printOriginalVisitor) VisitComment(node *CommentStatement) interface{} {
+	if str, ok := v.original(node); ok {
+		value := rCommentOpen.ReplaceAllString(str, "")
+		value = rCommentClose.ReplaceAllString(value, "")
+
+		if value == node.Value {
+			v.str(str)
+			return nil
+		}
+	}
+
+	strip := node.Strip
+	if strip == nil {
+		strip = &Strip{}
+	}
+
+	dashes := ""
+	if strings.Contains(node.Value, "}}") {
+		dashes = "--"
+	}
+
+	v.str("{{" + tilde(strip.Open) + "!" + dashes + node.Value + dashes + tilde(strip.Close) + "}}")
 
 	return nil
 }
@@ -159,90 +496,114 @@ func (v *printOriginalVistor) VisitComment(node *CommentStatement) interface{} {
 // Expressions
 
 // VisitExpression implements corresponding Visitor interface method
-func (v *printOriginalVistor) VisitExpression(node *Expression) interface{} {
-	if v.inBlock {
-		// v.str("{{#")
-		// v.indent()
-	}
-
-	// path
-	node.Path.Accept(v)
+func (v *printOriginalVisitor) VisitExpression(node *Expression) interface{} {
+	children := exprChildren(node.Path, node.Params, node.Hash)
+	spanned := v.spanned(node, children)
 
-	// params
-	for _, n := range node.Params {
-		v.str(" ")
+	for i, n := range children {
+		if i > 0 {
+			v.gap(spanned, children[i-1].Location().End, n.Location().Pos, " ")
+		}
 		n.Accept(v)
 	}
 
-	// hash
-	if node.Hash != nil {
-		v.str(" ")
-		node.Hash.Accept(v)
-	}
-
-	if v.inBlock {
-		// v.str("}}")
-		// v.nl()
-	}
-
 	return nil
 }
 
 // VisitSubExpression implements corresponding Visitor interface method
-func (v *printOriginalVistor) VisitSubExpression(node *SubExpression) interface{} {
+func (v *printOriginalVisitor) VisitSubExpression(node *SubExpression) interface{} {
+	spanned := v.spanned(node, []Node{node.Expression})
+
+	v.gap(spanned, node.Pos, node.Expression.Pos, "(")
 	node.Expression.Accept(v)
+	v.gap(spanned, node.Expression.End, node.End, ")")
 
 	return nil
 }
 
 // VisitPath implements corresponding Visitor interface method
-func (v *printOriginalVistor) VisitPath(node *PathExpression) interface{} {
-	v.str(node.Original)
+func (v *printOriginalVisitor) VisitPath(node *PathExpression) interface{} {
+	if str, ok := v.original(node); ok && (str == node.Original) {
+		v.str(str)
+	} else if node.Original != "" {
+		v.str(node.Original)
+	} else {
+		v.str(strings.Join(node.Parts, "."))
+	}
+
 	return nil
 }
 
 // Literals
 
 // VisitString implements corresponding Visitor interface method
-func (v *printOriginalVistor) VisitString(node *StringLiteral) interface{} {
-	v.str(node.Value)
+func (v *printOriginalVisitor) VisitString(node *StringLiteral) interface{} {
+	if (v.format != nil) && !strings.Contains(node.Value, "\\") {
+		v.str(quote(node.Value, v.format.Quote))
+		return nil
+	}
+
+	if str, ok := v.original(node); ok && (len(str) >= 2) {
+		delim := str[:1]
+		if strings.Replace(str[1:len(str)-1], "\\"+delim, delim, -1) == node.Value {
+			v.str(str)
+			return nil
+		}
+	}
+
+	v.str(quote(node.Value, '"'))
+
 	return nil
 }
 
 // VisitBoolean implements corresponding Visitor interface method
-func (v *printOriginalVistor) VisitBoolean(node *BooleanLiteral) interface{} {
-	v.str(node.Original)
+func (v *printOriginalVisitor) VisitBoolean(node *BooleanLiteral) interface{} {
+	if str, ok := v.original(node); ok && (str == node.Original) && (str == node.Canonical()) {
+		v.str(str)
+	} else {
+		v.str(node.Canonical())
+	}
 
 	return nil
 }
 
 // VisitNumber implements corresponding Visitor interface method
-func (v *printOriginalVistor) VisitNumber(node *NumberLiteral) interface{} {
-	v.str(node.Original)
+func (v *printOriginalVisitor) VisitNumber(node *NumberLiteral) interface{} {
+	if str, ok := v.original(node); ok && (str == node.Original) {
+		v.str(str)
+	} else {
+		v.str(node.Canonical())
+	}
+
 	return nil
 }
 
 // Miscellaneous
 
 // VisitHash implements corresponding Visitor interface method
-func (v *printOriginalVistor) VisitHash(node *Hash) interface{} {
-	v.str("HASH{")
+func (v *printOriginalVisitor) VisitHash(node *Hash) interface{} {
+	children := make([]Node, len(node.Pairs))
+	for i, p := range node.Pairs {
+		children[i] = p
+	}
+
+	spanned := v.spanned(node, children)
 
 	for i, p := range node.Pairs {
 		if i > 0 {
-			v.str(", ")
+			v.gap(spanned, node.Pairs[i-1].End, p.Pos, " ")
 		}
 		p.Accept(v)
 	}
 
-	v.str("}")
-
 	return nil
 }
 
 // VisitHashPair implements corresponding Visitor interface method
-func (v *printOriginalVistor) VisitHashPair(node *HashPair) interface{} {
-	v.str(node.Key + "=")
+func (v *printOriginalVisitor) VisitHashPair(node *HashPair) interface{} {
+	spanned := v.spanned(node, []Node{node.Val})
+
+	v.gap(spanned, node.Pos, node.Val.Location().Pos, node.Key+"=")
 	node.Val.Accept(v)
 
 	return nil
@@ -0,0 +1,61 @@
+package ast
+
+// Walk calls given function on given node and all its descendants, in source order.
+func Walk(node Node, fn func(Node)) {
+	fn(node)
+
+	switch n := node.(type) {
+	case *Program:
+		for _, child := range n.Body {
+			Walk(child, fn)
+		}
+	case *MustacheStatement:
+		Walk(n.Expression, fn)
+	case *Decorator:
+		Walk(n.Expression, fn)
+	case *BlockStatement:
+		walkBlock(n, fn)
+	case *DecoratorBlock:
+		walkBlock(&n.BlockStatement, fn)
+	case *PartialBlock:
+		walkBlock(&n.BlockStatement, fn)
+	case *PartialStatement:
+		Walk(n.Name, fn)
+		walkParamsHash(n.Params, n.Hash, fn)
+	case *Expression:
+		Walk(n.Path, fn)
+		walkParamsHash(n.Params, n.Hash, fn)
+	case *SubExpression:
+		Walk(n.Expression, fn)
+	case *Hash:
+		for _, pair := range n.Pairs {
+			Walk(pair, fn)
+		}
+	case *HashPair:
+		Walk(n.Val, fn)
+	}
+}
+
+// walkBlock walks through given block children
+func walkBlock(block *BlockStatement, fn func(Node)) {
+	Walk(block.Expression, fn)
+
+	if block.Program != nil {
+		Walk(block.Program, fn)
+	}
+
+	if block.Inverse != nil {
+		Walk(block.Inverse, fn)
+	}
+}
+
+// walkParamsHash walks through given params and hash
+func walkParamsHash(params []Node, hash *Hash, fn func(Node)) {
+	for _, param := range params {
+		Walk(param, fn)
+	}
+
+	if hash != nil {
+		Walk(hash, fn)
+	}
+}
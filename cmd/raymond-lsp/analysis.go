@@ -0,0 +1,290 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/komand/raymond"
+	"github.com/komand/raymond/ast"
+	"github.com/komand/raymond/parser"
+)
+
+// diagnosticSource is the source of published diagnostics
+const diagnosticSource = "raymond"
+
+// contains returns true if given byte offset is in given location, end included
+func contains(loc ast.Loc, offset int) bool {
+	return (loc.Pos <= offset) && (offset <= loc.End)
+}
+
+// helperCalls returns expressions calling a helper, ie. sub-expressions and expressions with params or hash,
+// except expressions of decorators and partial blocks
+func helperCalls(program *ast.Program) []*ast.Expression {
+	var result []*ast.Expression
+
+	notCalls := make(map[*ast.Expression]bool)
+
+	ast.Walk(program, func(node ast.Node) {
+		switch n := node.(type) {
+		case *ast.Decorator:
+			notCalls[n.Expression] = true
+		case *ast.DecoratorBlock:
+			notCalls[n.Expression] = true
+		case *ast.PartialBlock:
+			notCalls[n.Expression] = true
+		case *ast.Expression:
+			if ((len(n.Params) > 0) || (n.Hash != nil)) && !notCalls[n] {
+				result = append(result, n)
+			}
+		case *ast.SubExpression:
+			if (len(n.Expression.Params) == 0) && (n.Expression.Hash == nil) {
+				result = append(result, n.Expression)
+			}
+		}
+	})
+
+	return result
+}
+
+// diagnostics returns the problems found in given document
+func (ws *workspace) diagnostics(doc *document) []Diagnostic {
+	result := []Diagnostic{}
+
+	add := func(severity int, loc ast.Loc, format string, args ...interface{}) {
+		result = append(result, Diagnostic{
+			Range:    doc.rangeOf(loc.Pos, loc.End),
+			Severity: severity,
+			Source:   diagnosticSource,
+			Message:  fmt.Sprintf(format, args...),
+		})
+	}
+
+	program, errs := parser.ParseTolerant(doc.text, false)
+
+	// syntax errors
+	for _, err := range errs {
+		add(severityError, ast.Loc{Pos: err.Pos, End: err.Pos}, "%s", err.Message)
+	}
+
+	// unknown helpers
+	unknown := make(map[int]bool)
+
+	for _, expr := range helperCalls(program) {
+		name := expr.HelperName()
+		if _, ok := ws.helperDoc(name); !ok && (name != "") {
+			add(severityWarning, expr.Path.Location(), "Unknown helper '%s'", name)
+			unknown[expr.Path.Location().Pos] = true
+		}
+	}
+
+	// unknown partials
+	if ws.partials != "" {
+		files := ws.partialFiles()
+
+		ast.Walk(program, func(node ast.Node) {
+			// a missing partial block renders its content, and @partial-block is not a file
+			if partial, ok := node.(*ast.PartialStatement); ok {
+				if name, ok := ast.HelperNameStr(partial.Name); ok && !strings.HasPrefix(name, "@") && (files[name] == "") {
+					add(severityWarning, partial.Name.Location(), "Unknown partial '%s'", name)
+				}
+			}
+		})
+	}
+
+	// unknown variables
+	if (ws.schema != nil) && (len(errs) == 0) {
+		for _, ref := range ws.references(doc) {
+			switch ref.Kind {
+			case raymond.ValueReference, raymond.BlockReference, raymond.ParamReference, raymond.HashReference:
+				if !ref.Resolved || (ref.Path == "") || unknown[ref.Pos] {
+					continue
+				}
+
+				if _, ok := lookupSchema(ws.schema, splitPath(ref.Path)); !ok {
+					add(severityWarning, ref.Loc, "Unknown variable '%s'", ref.Name)
+				}
+			}
+		}
+	}
+
+	sort.SliceStable(result, func(i, j int) bool {
+		a, b := result[i].Range.Start, result[j].Range.Start
+		return (a.Line < b.Line) || ((a.Line == b.Line) && (a.Character < b.Character))
+	})
+
+	return result
+}
+
+// references returns references of given document, or nil if it can't be parsed
+func (ws *workspace) references(doc *document) []raymond.Reference {
+	tpl, err := ws.parseTemplate(doc.text)
+	if err != nil {
+		return nil
+	}
+
+	result, err := tpl.References()
+	if err != nil {
+		return nil
+	}
+
+	return result
+}
+
+// isPathChar returns true if given character can be part of a path expression being typed
+func isPathChar(c byte) bool {
+	return !strings.ContainsRune(" \t\r\n{}()=~>#^/&!|\"'", rune(c))
+}
+
+// completion returns completion proposals at given byte offset in given document
+//
+// Variable paths are completed relatively to the root context.
+func (ws *workspace) completion(doc *document, offset int) []CompletionItem {
+	result := []CompletionItem{}
+
+	before := doc.text[:offset]
+
+	// in a mustache ?
+	open := strings.LastIndex(before, "{{")
+	if (open < 0) || (strings.LastIndex(before, "}}") > open) {
+		return result
+	}
+
+	start := offset
+	for (start > open+2) && isPathChar(before[start-1]) {
+		start--
+	}
+
+	word := before[start:]
+	tag := strings.TrimLeft(before[open+2:start], "{~ \t")
+
+	// partial name
+	if strings.TrimSpace(tag) == ">" {
+		for name, filePath := range ws.partialFiles() {
+			result = append(result, CompletionItem{Label: name, Kind: completionFile, Detail: filePath})
+		}
+
+		sortCompletion(result)
+
+		return result
+	}
+
+	// path children
+	if i := strings.LastIndex(word, "."); i >= 0 {
+		parts := splitPath(strings.TrimPrefix(word[:i], "@root."))
+		if word[:i] == "@root" {
+			parts = nil
+		}
+
+		if val, ok := lookupSchema(ws.schema, parts); ok {
+			result = append(result, fieldItems(val)...)
+		}
+
+		return result
+	}
+
+	// root fields and helpers
+	result = append(result, fieldItems(ws.schema)...)
+
+	for _, name := range ws.helperNames() {
+		help, _ := ws.helperDoc(name)
+		result = append(result, CompletionItem{Label: name, Kind: completionFunction, Detail: "helper", Documentation: help})
+	}
+
+	return result
+}
+
+// fieldItems returns completion proposals for fields of given sample value
+func fieldItems(val interface{}) []CompletionItem {
+	var result []CompletionItem
+
+	if fields, ok := val.(map[string]interface{}); ok {
+		for name, field := range fields {
+			result = append(result, CompletionItem{Label: name, Kind: completionVariable, Detail: sampleType(field)})
+		}
+	}
+
+	sortCompletion(result)
+
+	return result
+}
+
+// sortCompletion sorts completion proposals by label
+func sortCompletion(items []CompletionItem) {
+	sort.Slice(items, func(i, j int) bool { return items[i].Label < items[j].Label })
+}
+
+// sampleType returns the type name of given sample value
+func sampleType(val interface{}) string {
+	switch val.(type) {
+	case map[string]interface{}:
+		return "object"
+	case []interface{}:
+		return "list"
+	case string:
+		return "string"
+	case float64:
+		return "number"
+	case bool:
+		return "boolean"
+	}
+
+	return "any"
+}
+
+// hover returns the documentation of helper at given byte offset in given document
+func (ws *workspace) hover(doc *document, offset int) *Hover {
+	program, _ := parser.ParseTolerant(doc.text, false)
+
+	var result *Hover
+
+	ast.Walk(program, func(node ast.Node) {
+		expr, ok := node.(*ast.Expression)
+		if !ok || !contains(expr.Path.Location(), offset) {
+			return
+		}
+
+		name := expr.HelperName()
+		if help, ok := ws.helperDoc(name); ok && (name != "") {
+			loc := expr.Path.Location()
+			rng := doc.rangeOf(loc.Pos, loc.End)
+
+			result = &Hover{
+				Contents: MarkupContent{Kind: "markdown", Value: fmt.Sprintf("**%s** helper\n\n%s", name, help)},
+				Range:    &rng,
+			}
+		}
+	})
+
+	return result
+}
+
+// definition returns the location of partial file at given byte offset in given document
+func (ws *workspace) definition(doc *document, offset int) []Location {
+	result := []Location{}
+
+	program, _ := parser.ParseTolerant(doc.text, false)
+
+	ast.Walk(program, func(node ast.Node) {
+		var name ast.Node
+
+		switch n := node.(type) {
+		case *ast.PartialStatement:
+			name = n.Name
+		case *ast.PartialBlock:
+			name = n.Expression.Path
+		}
+
+		if (name == nil) || !contains(name.Location(), offset) {
+			return
+		}
+
+		if name, ok := ast.HelperNameStr(name); ok {
+			if filePath := ws.partialFiles()[name]; filePath != "" {
+				result = append(result, Location{URI: fileURI(filePath)})
+			}
+		}
+	})
+
+	return result
+}
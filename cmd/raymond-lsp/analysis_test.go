@@ -0,0 +1,213 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// newTestWorkspace returns a workspace with a schema, custom helpers and partials
+func newTestWorkspace(t *testing.T) *workspace {
+	dir := t.TempDir()
+
+	files := map[string]string{
+		"schema.json":              `{"title": "t", "step1": {"output": {"name": "n", "tags": ["a"]}}, "users": [{"name": "u"}]}`,
+		"helpers.json":             `{"upper": "Uppercases a string."}`,
+		"partials/header.hbs":      "<h1>{{title}}</h1>",
+		"partials/forms/input.hbs": "<input>",
+		"partials/notes.txt":       "not a partial",
+	}
+
+	for name, content := range files {
+		filePath := filepath.Join(dir, name)
+
+		if err := os.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := ioutil.WriteFile(filePath, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	result := &workspace{partials: filepath.Join(dir, "partials")}
+
+	if err := result.loadSchema(filepath.Join(dir, "schema.json")); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := result.loadHelpers(filepath.Join(dir, "helpers.json")); err != nil {
+		t.Fatal(err)
+	}
+
+	return result
+}
+
+// cursor returns a document with given input, and the byte offset of the '|' cursor marker in input
+func cursor(input string) (*document, int) {
+	offset := strings.Index(input, "|")
+
+	return &document{uri: "file:///test.hbs", text: strings.Replace(input, "|", "", 1)}, offset
+}
+
+var diagnosticsTests = []struct {
+	name   string
+	input  string
+	output []string
+}{
+	{
+		"valid template",
+		"{{step1.output.name}} {{#each users}}{{name}}{{/each}} {{> header}} {{{upper title}}}",
+		nil,
+	},
+	{
+		"unknown variables",
+		"{{step1.outptu}}\n{{#each users}}{{name}} {{age}}{{/each}}",
+		[]string{
+			"0:2-0:14 2 Unknown variable 'step1.outptu'",
+			"1:26-1:29 2 Unknown variable 'age'",
+		},
+	},
+	{
+		"unknown helpers",
+		"{{{shout title}}} {{#if (lower title)}}{{/if}}",
+		[]string{
+			"0:3-0:8 2 Unknown helper 'shout'",
+			"0:25-0:30 2 Unknown helper 'lower'",
+		},
+	},
+	{
+		"unknown partials",
+		"{{> header}}{{> footer}}{{> forms/input}}",
+		[]string{
+			"0:16-0:22 2 Unknown partial 'footer'",
+		},
+	},
+	{
+		"partial blocks",
+		"{{#> layout title=title}}{{> @partial-block}}{{/layout}}{{#> footer}}{{/footer}}",
+		nil,
+	},
+	{
+		"syntax errors",
+		"{{#if title}}\n  {{foo}\n{{/each}}",
+		[]string{
+			"1:7-1:7 1 Lexer error",
+			"2:3-2:3 1 if doesn't match each",
+		},
+	},
+}
+
+func TestDiagnostics(t *testing.T) {
+	t.Parallel()
+
+	ws := newTestWorkspace(t)
+
+	for _, test := range diagnosticsTests {
+		doc := &document{uri: "file:///test.hbs", text: test.input}
+
+		var output []string
+		for _, d := range ws.diagnostics(doc) {
+			output = append(output, fmt.Sprintf("%d:%d-%d:%d %d %s", d.Range.Start.Line, d.Range.Start.Character, d.Range.End.Line, d.Range.End.Character, d.Severity, strings.Split(d.Message, "\n")[0]))
+		}
+
+		if fmt.Sprint(output) != fmt.Sprint(test.output) {
+			t.Errorf("Test '%s' failed\ninput:\n\t%q\nexpected\n\t%q\ngot\n\t%q", test.name, test.input, test.output, output)
+		}
+	}
+}
+
+var completionTests = []struct {
+	name   string
+	input  string
+	output string
+}{
+	{"root fields and helpers", "{{st|", "step1 title users each equal if log lookup unless upper with"},
+	{"fields", "{{#if step1.output.|}}", "name tags"},
+	{"root data", "{{@root.|", "step1 title users"},
+	{"unknown field", "{{step1.foo.|", ""},
+	{"partials", "{{> |}}", "forms/input header"},
+	{"content", "{{title}} |", ""},
+}
+
+func TestCompletion(t *testing.T) {
+	t.Parallel()
+
+	ws := newTestWorkspace(t)
+
+	for _, test := range completionTests {
+		var labels []string
+		for _, item := range ws.completion(cursor(test.input)) {
+			labels = append(labels, item.Label)
+		}
+
+		if output := strings.Join(labels, " "); output != test.output {
+			t.Errorf("Test '%s' failed\ninput:\n\t%q\nexpected\n\t%q\ngot\n\t%q", test.name, test.input, test.output, output)
+		}
+	}
+}
+
+func TestHover(t *testing.T) {
+	t.Parallel()
+
+	ws := newTestWorkspace(t)
+
+	hover := ws.hover(cursor("{{#ea|ch users}}{{/each}}"))
+	if (hover == nil) || !strings.HasPrefix(hover.Contents.Value, "**each** helper") || (hover.Range.Start.Character != 3) || (hover.Range.End.Character != 7) {
+		t.Errorf("Erroneous built-in helper hover: %#v", hover)
+	}
+
+	hover = ws.hover(cursor("{{{up|per title}}}"))
+	if (hover == nil) || !strings.HasSuffix(hover.Contents.Value, "Uppercases a string.") {
+		t.Errorf("Erroneous custom helper hover: %#v", hover)
+	}
+
+	if hover = ws.hover(cursor("{{{upper ti|tle}}}")); hover != nil {
+		t.Errorf("Unexpected hover: %#v", hover)
+	}
+}
+
+func TestDefinition(t *testing.T) {
+	t.Parallel()
+
+	ws := newTestWorkspace(t)
+
+	locations := ws.definition(cursor("<div>{{> forms/in|put}}</div>"))
+	if (len(locations) != 1) || !strings.HasPrefix(locations[0].URI, "file:///") || !strings.HasSuffix(locations[0].URI, "/partials/forms/input.hbs") {
+		t.Errorf("Erroneous partial definition: %#v", locations)
+	}
+
+	if locations = ws.definition(cursor("{{> foot|er}}")); len(locations) != 0 {
+		t.Errorf("Unexpected definition: %#v", locations)
+	}
+}
+
+func TestDocumentPositions(t *testing.T) {
+	t.Parallel()
+
+	doc := &document{text: "é😀x\nab"}
+
+	tests := []struct {
+		offset int
+		pos    Position
+	}{
+		{0, Position{0, 0}},
+		{2, Position{0, 1}},
+		{6, Position{0, 3}},
+		{8, Position{1, 0}},
+		{10, Position{1, 2}},
+	}
+
+	for _, test := range tests {
+		if pos := doc.position(test.offset); pos != test.pos {
+			t.Errorf("Erroneous position of offset %d: %v", test.offset, pos)
+		}
+
+		if offset := doc.offset(test.pos); offset != test.offset {
+			t.Errorf("Erroneous offset of position %v: %d", test.pos, offset)
+		}
+	}
+}
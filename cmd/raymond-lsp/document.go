@@ -0,0 +1,83 @@
+package main
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// document is an opened text document
+type document struct {
+	uri     string
+	version int
+	text    string
+}
+
+// position returns the LSP position of given byte offset
+func (doc *document) position(offset int) Position {
+	if offset > len(doc.text) {
+		offset = len(doc.text)
+	}
+
+	if offset < 0 {
+		offset = 0
+	}
+
+	before := doc.text[:offset]
+	lineStart := strings.LastIndex(before, "\n") + 1
+
+	return Position{
+		Line:      strings.Count(before, "\n"),
+		Character: utf16Len(before[lineStart:]),
+	}
+}
+
+// offset returns the byte offset of given LSP position
+func (doc *document) offset(pos Position) int {
+	result := 0
+
+	for line := 0; line < pos.Line; line++ {
+		i := strings.IndexByte(doc.text[result:], '\n')
+		if i < 0 {
+			return len(doc.text)
+		}
+
+		result += i + 1
+	}
+
+	for units := 0; (units < pos.Character) && (result < len(doc.text)); {
+		r, w := utf8.DecodeRuneInString(doc.text[result:])
+		if r == '\n' {
+			break
+		}
+
+		units += utf16RuneLen(r)
+		result += w
+	}
+
+	return result
+}
+
+// rangeOf returns the LSP range between given byte offsets
+func (doc *document) rangeOf(from int, to int) Range {
+	return Range{Start: doc.position(from), End: doc.position(to)}
+}
+
+// utf16Len returns the number of UTF-16 code units of given string
+func utf16Len(str string) int {
+	result := 0
+
+	for _, r := range str {
+		result += utf16RuneLen(r)
+	}
+
+	return result
+}
+
+// utf16RuneLen returns the number of UTF-16 code units of given rune
+func utf16RuneLen(r rune) int {
+	if r >= 0x10000 {
+		return 2
+	}
+
+	return 1
+}
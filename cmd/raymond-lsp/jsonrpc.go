@@ -0,0 +1,130 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/textproto"
+	"strconv"
+)
+
+// JSON-RPC error codes
+const (
+	errParse          = -32700
+	errMethodNotFound = -32601
+	errInvalidParams  = -32602
+)
+
+// message is a JSON-RPC request, notification or response
+type message struct {
+	JSONRPC string           `json:"jsonrpc"`
+	ID      *json.RawMessage `json:"id,omitempty"`
+	Method  string           `json:"method,omitempty"`
+	Params  json.RawMessage  `json:"params,omitempty"`
+	Result  interface{}      `json:"result,omitempty"`
+	Error   *responseError   `json:"error,omitempty"`
+}
+
+// responseError is a JSON-RPC error
+type responseError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// conn reads and writes JSON-RPC messages with LSP base protocol framing, ie. prefixed by a Content-Length header
+type conn struct {
+	reader *textproto.Reader
+	writer io.Writer
+}
+
+func newConn(r io.Reader, w io.Writer) *conn {
+	return &conn{
+		reader: textproto.NewReader(bufio.NewReader(r)),
+		writer: w,
+	}
+}
+
+// readBody reads next message content
+func (c *conn) readBody() ([]byte, error) {
+	header, err := c.reader.ReadMIMEHeader()
+	if err != nil {
+		return nil, err
+	}
+
+	length, err := strconv.Atoi(header.Get("Content-Length"))
+	if err != nil {
+		return nil, fmt.Errorf("Invalid Content-Length header: %q", header.Get("Content-Length"))
+	}
+
+	result := make([]byte, length)
+	if _, err := io.ReadFull(c.reader.R, result); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// read reads next message
+func (c *conn) read() (*message, error) {
+	body, err := c.readBody()
+	if err != nil {
+		return nil, err
+	}
+
+	result := &message{}
+	if err := json.Unmarshal(body, result); err != nil {
+		return nil, &responseError{Code: errParse, Message: err.Error()}
+	}
+
+	return result, nil
+}
+
+// write writes given message
+func (c *conn) write(msg *message) error {
+	msg.JSONRPC = "2.0"
+
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	if _, err := fmt.Fprintf(c.writer, "Content-Length: %d\r\n\r\n", len(body)); err != nil {
+		return err
+	}
+
+	_, err = c.writer.Write(body)
+
+	return err
+}
+
+// reply sends a response to given request
+func (c *conn) reply(id *json.RawMessage, result interface{}, err *responseError) error {
+	if (result == nil) && (err == nil) {
+		// result is mandatory on success
+		result = json.RawMessage("null")
+	}
+
+	if id == nil {
+		// id is mandatory too, and null when it could not be read from request
+		null := json.RawMessage("null")
+		id = &null
+	}
+
+	return c.write(&message{ID: id, Result: result, Error: err})
+}
+
+// notify sends a notification
+func (c *conn) notify(method string, params interface{}) error {
+	data, err := json.Marshal(params)
+	if err != nil {
+		return err
+	}
+
+	return c.write(&message{Method: method, Params: data})
+}
+
+// Error implements the error interface.
+func (err *responseError) Error() string {
+	return err.Message
+}
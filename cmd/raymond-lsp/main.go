@@ -0,0 +1,49 @@
+// Command raymond-lsp is a language server for handlebars templates, speaking the Language Server Protocol over stdio.
+//
+// It publishes syntax errors, unknown helpers, unknown partials and unknown variables as diagnostics, completes
+// variable paths, helper and partial names, shows helpers documentation on hover, and resolves partials definitions.
+//
+// Usage:
+//
+//	raymond-lsp [-schema context.json] [-helpers helpers.json] [-partials dir]
+//
+// The schema file is a sample context, as JSON, that variable paths are checked against. The helpers file is a JSON
+// object mapping custom helper names to their markdown documentation. Partials are the .hbs, .handlebars and .mustache
+// files found in the partials directory, named by their path relative to that directory, without extension.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+)
+
+func main() {
+	schema := flag.String("schema", "", "JSON file holding a sample context")
+	helpers := flag.String("helpers", "", "JSON file mapping custom helper names to their documentation")
+	partials := flag.String("partials", "", "directory holding partial files")
+	flag.Parse()
+
+	ws := &workspace{partials: *partials}
+
+	if *schema != "" {
+		if err := ws.loadSchema(*schema); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to load schema: %s\n", err)
+			os.Exit(2)
+		}
+	}
+
+	if *helpers != "" {
+		if err := ws.loadHelpers(*helpers); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to load helpers: %s\n", err)
+			os.Exit(2)
+		}
+	}
+
+	code, err := newServer(os.Stdin, os.Stdout, ws).run()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+	}
+
+	os.Exit(code)
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+)
+
+// server is a language server for handlebars templates
+type server struct {
+	conn *conn
+	ws   *workspace
+
+	// opened documents, by URI
+	docs map[string]*document
+
+	// shutdown request received
+	shutdown bool
+}
+
+func newServer(r io.Reader, w io.Writer, ws *workspace) *server {
+	return &server{
+		conn: newConn(r, w),
+		ws:   ws,
+		docs: make(map[string]*document),
+	}
+}
+
+// run serves requests until the exit notification, and returns the process exit code
+func (s *server) run() (int, error) {
+	for {
+		msg, err := s.conn.read()
+		if err != nil {
+			if rerr, ok := err.(*responseError); ok {
+				// invalid JSON
+				if err := s.conn.reply(nil, nil, rerr); err != nil {
+					return 1, err
+				}
+
+				continue
+			}
+
+			if err == io.EOF {
+				return 1, nil
+			}
+
+			return 1, err
+		}
+
+		if msg.Method == "exit" {
+			if s.shutdown {
+				return 0, nil
+			}
+
+			return 1, nil
+		}
+
+		if err := s.handle(msg); err != nil {
+			return 1, err
+		}
+	}
+}
+
+// handle handles given request or notification
+func (s *server) handle(msg *message) error {
+	var result interface{}
+	var rerr *responseError
+
+	switch msg.Method {
+	case "initialize":
+		result = &InitializeResult{
+			Capabilities: ServerCapabilities{
+				TextDocumentSync:   syncFull,
+				CompletionProvider: CompletionOptions{TriggerCharacters: []string{"{", ".", ">", " "}},
+				HoverProvider:      true,
+				DefinitionProvider: true,
+			},
+			ServerInfo: ServerInfo{Name: "raymond-lsp"},
+		}
+	case "shutdown":
+		s.shutdown = true
+	case "textDocument/didOpen":
+		var params DidOpenTextDocumentParams
+		if rerr = unmarshalParams(msg, &params); rerr == nil {
+			item := params.TextDocument
+			s.docs[item.URI] = &document{uri: item.URI, version: item.Version, text: item.Text}
+
+			return s.publishDiagnostics(s.docs[item.URI])
+		}
+	case "textDocument/didChange":
+		var params DidChangeTextDocumentParams
+		if rerr = unmarshalParams(msg, &params); rerr == nil {
+			doc := s.docs[params.TextDocument.URI]
+			if (doc == nil) || (len(params.ContentChanges) == 0) {
+				return nil
+			}
+
+			// full sync: last change holds the whole text
+			doc.text = params.ContentChanges[len(params.ContentChanges)-1].Text
+			doc.version = params.TextDocument.Version
+
+			return s.publishDiagnostics(doc)
+		}
+	case "textDocument/didClose":
+		var params DidCloseTextDocumentParams
+		if rerr = unmarshalParams(msg, &params); rerr == nil {
+			delete(s.docs, params.TextDocument.URI)
+
+			// clear diagnostics
+			return s.conn.notify("textDocument/publishDiagnostics", &PublishDiagnosticsParams{
+				URI:         params.TextDocument.URI,
+				Diagnostics: []Diagnostic{},
+			})
+		}
+	case "textDocument/completion":
+		var doc *document
+		var offset int
+
+		if doc, offset, rerr = s.position(msg); rerr == nil {
+			result = s.ws.completion(doc, offset)
+		}
+	case "textDocument/hover":
+		var doc *document
+		var offset int
+
+		if doc, offset, rerr = s.position(msg); rerr == nil {
+			if hover := s.ws.hover(doc, offset); hover != nil {
+				result = hover
+			}
+		}
+	case "textDocument/definition":
+		var doc *document
+		var offset int
+
+		if doc, offset, rerr = s.position(msg); rerr == nil {
+			result = s.ws.definition(doc, offset)
+		}
+	default:
+		if msg.ID != nil {
+			rerr = &responseError{Code: errMethodNotFound, Message: "Method not found: " + msg.Method}
+		}
+	}
+
+	if msg.ID == nil {
+		// notification
+		return nil
+	}
+
+	return s.conn.reply(msg.ID, result, rerr)
+}
+
+// position returns the document and byte offset targeted by a text document position request
+func (s *server) position(msg *message) (*document, int, *responseError) {
+	var params TextDocumentPositionParams
+	if err := unmarshalParams(msg, &params); err != nil {
+		return nil, 0, err
+	}
+
+	doc := s.docs[params.TextDocument.URI]
+	if doc == nil {
+		return nil, 0, &responseError{Code: errInvalidParams, Message: "Document not opened: " + params.TextDocument.URI}
+	}
+
+	return doc, doc.offset(params.Position), nil
+}
+
+// publishDiagnostics sends diagnostics of given document
+func (s *server) publishDiagnostics(doc *document) error {
+	return s.conn.notify("textDocument/publishDiagnostics", &PublishDiagnosticsParams{
+		URI:         doc.uri,
+		Diagnostics: s.ws.diagnostics(doc),
+	})
+}
+
+// unmarshalParams decodes params of given message
+func unmarshalParams(msg *message, params interface{}) *responseError {
+	if err := json.Unmarshal(msg.Params, params); err != nil {
+		return &responseError{Code: errInvalidParams, Message: err.Error()}
+	}
+
+	return nil
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"testing"
+)
+
+func TestServer(t *testing.T) {
+	t.Parallel()
+
+	requests := []string{
+		`{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"capabilities":{}}}`,
+		`{"jsonrpc":"2.0","method":"initialized","params":{}}`,
+		`{"jsonrpc":"2.0","method":"textDocument/didOpen","params":{"textDocument":{"uri":"file:///a.hbs","languageId":"handlebars","version":1,"text":"{{title}} {{{shout title}}}"}}}`,
+		`{"jsonrpc":"2.0","method":"textDocument/didChange","params":{"textDocument":{"uri":"file:///a.hbs","version":2},"contentChanges":[{"text":"{{#each}}"}]}}`,
+		`{"jsonrpc":"2.0","id":2,"method":"textDocument/completion","params":{"textDocument":{"uri":"file:///a.hbs"},"position":{"line":0,"character":3}}}`,
+		`{"jsonrpc":"2.0","id":3,"method":"textDocument/hover","params":{"textDocument":{"uri":"file:///a.hbs"},"position":{"line":0,"character":4}}}`,
+		`{"jsonrpc":"2.0","id":4,"method":"textDocument/hover","params":{"textDocument":{"uri":"file:///b.hbs"},"position":{"line":0,"character":4}}}`,
+		`{"jsonrpc":"2.0","id":"five","method":"workspace/symbol","params":{}}`,
+		`{"jsonrpc":"2.0","method":"textDocument/didClose","params":{"textDocument":{"uri":"file:///a.hbs"}}}`,
+		`{"jsonrpc":"2.0","id":6,"method":"shutdown"}`,
+		`{"jsonrpc":"2.0","method":"exit"}`,
+	}
+
+	input := &bytes.Buffer{}
+	for _, req := range requests {
+		fmt.Fprintf(input, "Content-Length: %d\r\n\r\n%s", len(req), req)
+	}
+
+	output := &bytes.Buffer{}
+
+	code, err := newServer(input, output, &workspace{}).run()
+	if (code != 0) || (err != nil) {
+		t.Fatalf("Erroneous exit: %d, %v", code, err)
+	}
+
+	expected := []string{
+		`{"jsonrpc":"2.0","id":1,"result":{"capabilities":{"textDocumentSync":1,"completionProvider":{"triggerCharacters":["{",".","\u003e"," "]},"hoverProvider":true,"definitionProvider":true},"serverInfo":{"name":"raymond-lsp"}}}`,
+		`{"jsonrpc":"2.0","method":"textDocument/publishDiagnostics","params":{"uri":"file:///a.hbs","diagnostics":[{"range":{"start":{"line":0,"character":13},"end":{"line":0,"character":18}},"severity":2,"source":"raymond","message":"Unknown helper 'shout'"}]}}`,
+		`{"jsonrpc":"2.0","method":"textDocument/publishDiagnostics","params":{"uri":"file:///a.hbs","diagnostics":[{"range":{"start":{"line":0,"character":0},"end":{"line":0,"character":0}},"severity":1,"source":"raymond","message":"each block is not closed\nNode: Block{Pos: 0}"}]}}`,
+		`{"jsonrpc":"2.0","id":2,"result":[{"label":"each","kind":3,"detail":"helper","documentation":` + jsonStr(builtinHelpers["each"]) + `},{"label":"equal","kind":3,"detail":"helper","documentation":` + jsonStr(builtinHelpers["equal"]) + `},{"label":"if","kind":3,"detail":"helper","documentation":` + jsonStr(builtinHelpers["if"]) + `},{"label":"log","kind":3,"detail":"helper","documentation":` + jsonStr(builtinHelpers["log"]) + `},{"label":"lookup","kind":3,"detail":"helper","documentation":` + jsonStr(builtinHelpers["lookup"]) + `},{"label":"unless","kind":3,"detail":"helper","documentation":` + jsonStr(builtinHelpers["unless"]) + `},{"label":"with","kind":3,"detail":"helper","documentation":` + jsonStr(builtinHelpers["with"]) + `}]}`,
+		`{"jsonrpc":"2.0","id":3,"result":{"contents":{"kind":"markdown","value":` + jsonStr("**each** helper\n\n"+builtinHelpers["each"]) + `},"range":{"start":{"line":0,"character":3},"end":{"line":0,"character":7}}}}`,
+		`{"jsonrpc":"2.0","id":4,"error":{"code":-32602,"message":"Document not opened: file:///b.hbs"}}`,
+		`{"jsonrpc":"2.0","id":"five","error":{"code":-32601,"message":"Method not found: workspace/symbol"}}`,
+		`{"jsonrpc":"2.0","method":"textDocument/publishDiagnostics","params":{"uri":"file:///a.hbs","diagnostics":[]}}`,
+		`{"jsonrpc":"2.0","id":6,"result":null}`,
+	}
+
+	c := newConn(output, nil)
+
+	for i, exp := range expected {
+		got, err := c.readBody()
+		if err != nil {
+			t.Fatalf("Failed to read message %d: %s", i, err)
+		}
+
+		if string(got) != exp {
+			t.Errorf("Erroneous message %d\nexpected\n\t%s\ngot\n\t%s", i, exp, got)
+		}
+	}
+
+	if _, err := c.readBody(); err != io.EOF {
+		t.Errorf("Unexpected message: %v", err)
+	}
+}
+
+func TestServerExitWithoutShutdown(t *testing.T) {
+	t.Parallel()
+
+	req := `{"jsonrpc":"2.0","method":"exit"}`
+	input := bytes.NewBufferString(fmt.Sprintf("Content-Length: %d\r\n\r\n%s", len(req), req))
+
+	if code, err := newServer(input, &bytes.Buffer{}, &workspace{}).run(); (code != 1) || (err != nil) {
+		t.Errorf("Erroneous exit: %d, %v", code, err)
+	}
+}
+
+// jsonStr returns given string encoded as JSON
+func jsonStr(str string) string {
+	result, _ := json.Marshal(str)
+	return string(result)
+}
+
+func TestServerParseError(t *testing.T) {
+	t.Parallel()
+
+	req := `{"jsonrpc":"2.0","id":1,"method":`
+	input := bytes.NewBufferString(fmt.Sprintf("Content-Length: %d\r\n\r\n%s", len(req), req))
+	output := &bytes.Buffer{}
+
+	if code, err := newServer(input, output, &workspace{}).run(); (code != 1) || (err != nil) {
+		t.Errorf("Erroneous exit: %d, %v", code, err)
+	}
+
+	got, err := newConn(output, nil).readBody()
+	if err != nil {
+		t.Fatalf("Failed to read response: %s", err)
+	}
+
+	expected := `{"jsonrpc":"2.0","id":null,"error":{"code":-32700,"message":"unexpected end of JSON input"}}`
+	if string(got) != expected {
+		t.Errorf("Erroneous response\nexpected\n\t%s\ngot\n\t%s", expected, got)
+	}
+}
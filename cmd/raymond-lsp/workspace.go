@@ -0,0 +1,202 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/komand/raymond"
+)
+
+// partialExts are the extensions of partial files
+var partialExts = []string{".hbs", ".handlebars", ".mustache"}
+
+// builtinHelpers are the documentations of raymond built-in helpers
+var builtinHelpers = map[string]string{
+	"if":     "`{{#if value}}...{{else}}...{{/if}}`\n\nRenders the block if value is truthy, and the inverse block otherwise.",
+	"unless": "`{{#unless value}}...{{else}}...{{/unless}}`\n\nRenders the block if value is falsy, and the inverse block otherwise.",
+	"with":   "`{{#with value as |alias|}}...{{else}}...{{/with}}`\n\nRenders the block with value as context, or the inverse block if value is falsy.",
+	"each":   "`{{#each list as |item key|}}...{{else}}...{{/each}}`\n\nRenders the block for each item of a list or map, with `@index`, `@key`, `@first` and `@last` data, or the inverse block if there is nothing to iterate.",
+	"lookup": "`{{lookup object field}}`\n\nReturns the value of a field that can't be accessed with a path, eg. a dynamic field name.",
+	"log":    "`{{log message... level=\"info\"}}`\n\nLogs messages at given level, or `@level`, and renders nothing.",
+	"equal":  "`{{#equal a b}}...{{/equal}}`\n\nRenders the block if both values are equal once converted to strings.",
+}
+
+// workspace holds the configuration used to analyze templates
+type workspace struct {
+	// schema is a sample context, nil if unknown
+	schema interface{}
+
+	// helpers are custom helpers documentations
+	helpers map[string]string
+
+	// partials is the directory holding partial files, empty if unknown
+	partials string
+}
+
+// loadSchema loads the sample context from given JSON file
+func (ws *workspace) loadSchema(filePath string) error {
+	data, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(data, &ws.schema)
+}
+
+// loadHelpers loads custom helpers documentations from given JSON file, mapping helper names to markdown documentations
+func (ws *workspace) loadHelpers(filePath string) error {
+	data, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(data, &ws.helpers)
+}
+
+// helperDoc returns the documentation of given helper, with a boolean set to false if this is not a known helper
+func (ws *workspace) helperDoc(name string) (string, bool) {
+	if doc, ok := ws.helpers[name]; ok {
+		return doc, true
+	}
+
+	doc, ok := builtinHelpers[name]
+
+	return doc, ok
+}
+
+// helperNames returns all known helper names, sorted
+func (ws *workspace) helperNames() []string {
+	var result []string
+
+	for name := range builtinHelpers {
+		result = append(result, name)
+	}
+
+	for name := range ws.helpers {
+		if _, ok := builtinHelpers[name]; !ok {
+			result = append(result, name)
+		}
+	}
+
+	sort.Strings(result)
+
+	return result
+}
+
+// parseTemplate parses given source, with custom helpers registered
+func (ws *workspace) parseTemplate(source string) (*raymond.Template, error) {
+	result, err := raymond.Parse(source)
+	if err != nil {
+		return nil, err
+	}
+
+	for name := range ws.helpers {
+		if _, ok := builtinHelpers[name]; !ok {
+			result.RegisterHelper(name, func(options *raymond.Options) string { return "" })
+		}
+	}
+
+	return result, nil
+}
+
+// partialFiles returns the paths of files found in partials directory, by partial name
+func (ws *workspace) partialFiles() map[string]string {
+	result := make(map[string]string)
+
+	if ws.partials == "" {
+		return result
+	}
+
+	filepath.Walk(ws.partials, func(filePath string, info os.FileInfo, err error) error {
+		if (err != nil) || info.IsDir() {
+			return nil
+		}
+
+		ext := filepath.Ext(filePath)
+		for _, partialExt := range partialExts {
+			if ext == partialExt {
+				if rel, err := filepath.Rel(ws.partials, filePath); err == nil {
+					result[filepath.ToSlash(strings.TrimSuffix(rel, ext))] = filePath
+				}
+			}
+		}
+
+		return nil
+	})
+
+	return result
+}
+
+// lookupSchema returns the sample value at given context path parts, with a boolean set to false if the path does not exist
+//
+// Paths through values which shape is unknown, like null or an empty list, always exist.
+func lookupSchema(schema interface{}, parts []string) (interface{}, bool) {
+	result := schema
+
+	for _, part := range parts {
+		switch val := result.(type) {
+		case map[string]interface{}:
+			if part == raymond.EachItem {
+				// iterating over map values
+				return nil, true
+			}
+
+			field, ok := val[strings.TrimSuffix(strings.TrimPrefix(part, "["), "]")]
+			if !ok {
+				return nil, false
+			}
+
+			result = field
+		case []interface{}:
+			if (part != raymond.EachItem) || (len(val) == 0) {
+				return nil, true
+			}
+
+			result = val[0]
+		default:
+			return nil, true
+		}
+	}
+
+	return result, true
+}
+
+// splitPath splits given context path in parts, keeping dots in square brackets
+func splitPath(path string) []string {
+	var result []string
+
+	start := 0
+	escaped := false
+
+	for i, r := range path {
+		switch {
+		case r == '[':
+			escaped = true
+		case r == ']':
+			escaped = false
+		case (r == '.') && !escaped:
+			result = append(result, path[start:i])
+			start = i + 1
+		}
+	}
+
+	if path != "" {
+		result = append(result, path[start:])
+	}
+
+	return result
+}
+
+// fileURI returns the URI of given file path
+func fileURI(filePath string) string {
+	if abs, err := filepath.Abs(filePath); err == nil {
+		filePath = abs
+	}
+
+	return (&url.URL{Scheme: "file", Path: filepath.ToSlash(filePath)}).String()
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/komand/raymond"
+	"github.com/komand/raymond/ast"
+)
+
+// runFmt formats templates with given command line arguments, and returns the process exit code
+func runFmt(args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) int {
+	flags := flag.NewFlagSet("raymond fmt", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+
+	list := flags.Bool("l", false, "list files whose formatting differs, instead of printing them")
+	write := flags.Bool("w", false, "write formatted templates to their files, instead of printing them")
+	indent := flags.Int("indent", 2, "number of spaces indenting nested block tags")
+	quote := flags.String("quote", "double", "string literals delimiter: double or single")
+
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: raymond fmt [flags] [templates...]")
+		flags.PrintDefaults()
+	}
+
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	if (*indent < 0) || ((*quote != "double") && (*quote != "single")) || ((flags.NArg() == 0) && (*list || *write)) {
+		flags.Usage()
+		return 2
+	}
+
+	opts := &ast.FormatOptions{
+		Indent: strings.Repeat(" ", *indent),
+		Quote:  '"',
+	}
+
+	if *quote == "single" {
+		opts.Quote = '\''
+	}
+
+	if flags.NArg() == 0 {
+		b, err := ioutil.ReadAll(stdin)
+		if err == nil {
+			var result string
+			if result, err = raymond.Format(string(b), opts); err == nil {
+				_, err = io.WriteString(stdout, result)
+			}
+		}
+
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			return 1
+		}
+
+		return 0
+	}
+
+	code := 0
+
+	for _, filePath := range flags.Args() {
+		if err := formatFile(filePath, opts, *list, *write, stdout); err != nil {
+			fmt.Fprintln(stderr, err)
+			code = 1
+		}
+	}
+
+	return code
+}
+
+// formatFile formats given template file. The formatted template is printed, unless the file path is listed or the
+// file is written, if its formatting differs.
+func formatFile(filePath string, opts *ast.FormatOptions, list bool, write bool, stdout io.Writer) error {
+	info, err := os.Stat(filePath)
+	if err != nil {
+		return err
+	}
+
+	b, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		return err
+	}
+
+	result, err := raymond.Format(string(b), opts)
+	if err != nil {
+		return fmt.Errorf("%s: %s", filePath, err)
+	}
+
+	if !list && !write {
+		_, err = io.WriteString(stdout, result)
+		return err
+	}
+
+	if result == string(b) {
+		return nil
+	}
+
+	if list {
+		fmt.Fprintln(stdout, filePath)
+	}
+
+	if write {
+		return ioutil.WriteFile(filePath, []byte(result), info.Mode().Perm())
+	}
+
+	return nil
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var fmtFiles = map[string]string{
+	"ugly.hbs":   "{{#if  ok }}\n{{#each items}}\n<p>{{  title }} {{foo 'a'}}</p>\n{{/each}}\n{{/if}}\n",
+	"clean.hbs":  "{{#if ok}}\n  <p>{{title}}</p>\n{{/if}}\n",
+	"broken.hbs": "ok\n{{foo}",
+}
+
+var fmtTests = []struct {
+	name   string
+	args   []string
+	stdin  string
+	code   int
+	stdout string
+	files  map[string]string
+}{
+	{
+		"stdin",
+		nil,
+		"{{#if  ok }}{{  title }}{{/if}}",
+		0,
+		"{{#if ok}}{{title}}{{/if}}",
+		nil,
+	},
+	{
+		"print files",
+		[]string{"ugly.hbs", "clean.hbs"},
+		"",
+		0,
+		"{{#if ok}}\n  {{#each items}}\n<p>{{title}} {{foo \"a\"}}</p>\n  {{/each}}\n{{/if}}\n" + fmtFiles["clean.hbs"],
+		nil,
+	},
+	{
+		"indent and quote",
+		[]string{"-indent", "4", "-quote", "single", "ugly.hbs"},
+		"",
+		0,
+		"{{#if ok}}\n    {{#each items}}\n<p>{{title}} {{foo 'a'}}</p>\n    {{/each}}\n{{/if}}\n",
+		nil,
+	},
+	{
+		"list files",
+		[]string{"-l", "ugly.hbs", "clean.hbs"},
+		"",
+		0,
+		"ugly.hbs\n",
+		map[string]string{"ugly.hbs": fmtFiles["ugly.hbs"]},
+	},
+	{
+		"write files",
+		[]string{"-w", "ugly.hbs", "clean.hbs"},
+		"",
+		0,
+		"",
+		map[string]string{
+			"ugly.hbs":  "{{#if ok}}\n  {{#each items}}\n<p>{{title}} {{foo \"a\"}}</p>\n  {{/each}}\n{{/if}}\n",
+			"clean.hbs": fmtFiles["clean.hbs"],
+		},
+	},
+	{
+		"syntax error",
+		[]string{"-l", "broken.hbs", "ugly.hbs"},
+		"",
+		1,
+		"ugly.hbs\n",
+		map[string]string{"broken.hbs": fmtFiles["broken.hbs"]},
+	},
+	{
+		"write without files",
+		[]string{"-w"},
+		"{{title}}",
+		2,
+		"",
+		nil,
+	},
+	{
+		"invalid quote",
+		[]string{"-quote", "backtick", "ugly.hbs"},
+		"",
+		2,
+		"",
+		nil,
+	},
+}
+
+func TestFmt(t *testing.T) {
+	t.Parallel()
+
+	for _, test := range fmtTests {
+		dir := writeFiles(t, fmtFiles)
+		stdout := &bytes.Buffer{}
+
+		// make paths relative to test directory in output
+		var args []string
+		for _, arg := range test.args {
+			if strings.HasSuffix(arg, ".hbs") {
+				arg = filepath.Join(dir, arg)
+			}
+			args = append(args, arg)
+		}
+
+		code := run(append([]string{"fmt"}, args...), strings.NewReader(test.stdin), stdout, &bytes.Buffer{})
+
+		output := strings.Replace(stdout.String(), dir+string(filepath.Separator), "", -1)
+		if (code != test.code) || (output != test.stdout) {
+			t.Errorf("Test '%s' failed\nexpected\n\t%d %q\ngot\n\t%d %q", test.name, test.code, test.stdout, code, output)
+		}
+
+		for name, expected := range test.files {
+			b, err := ioutil.ReadFile(filepath.Join(dir, name))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if string(b) != expected {
+				t.Errorf("Test '%s' failed - Unexpected %s content\nexpected\n\t%q\ngot\n\t%q", test.name, name, expected, string(b))
+			}
+		}
+	}
+}
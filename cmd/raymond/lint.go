@@ -0,0 +1,195 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/komand/raymond"
+	"github.com/komand/raymond/parser"
+)
+
+// syntaxRule is the rule name of findings reporting syntax errors
+const syntaxRule = "syntax"
+
+// finding is a lint finding, as output in JSON format
+type finding struct {
+	File     string `json:"file"`
+	Line     int    `json:"line"`
+	Column   int    `json:"column"`
+	Offset   int    `json:"offset"`
+	Severity string `json:"severity"`
+	Rule     string `json:"rule"`
+	Message  string `json:"message"`
+}
+
+// listFlag is a flag holding a comma separated list of values, that can be repeated
+type listFlag []string
+
+// runLint lints templates with given command line arguments, and returns the process exit code
+func runLint(args []string, stdout io.Writer, stderr io.Writer) int {
+	flags := flag.NewFlagSet("raymond lint", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+
+	var helpers, deprecated, disabled listFlag
+
+	format := flags.String("format", "text", "output format: text or json")
+	flags.Var(&helpers, "helpers", "comma separated names of custom helpers")
+	flags.Var(&deprecated, "deprecated", "comma separated names of deprecated helpers, each one optionally followed by a hint: name=hint")
+	flags.Var(&disabled, "disable", "comma separated names of disabled rules")
+	failOn := flags.String("fail-on", "error", "minimum severity of findings that make the command fail: error or warning")
+
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: raymond lint [flags] templates...")
+		flags.PrintDefaults()
+	}
+
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	if ((*format != "text") && (*format != "json")) || ((*failOn != "error") && (*failOn != "warning")) || (flags.NArg() == 0) {
+		flags.Usage()
+		return 2
+	}
+
+	rules := lintRules(deprecated, disabled)
+
+	code := 0
+	findings := []finding{}
+
+	for _, filePath := range flags.Args() {
+		fileFindings, err := lintFile(filePath, helpers, rules)
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			code = 1
+			continue
+		}
+
+		for _, f := range fileFindings {
+			if (f.Severity == raymond.LintError) || (*failOn == "warning") {
+				code = 1
+			}
+
+			findings = append(findings, finding{
+				File:     filePath,
+				Line:     f.Line,
+				Column:   f.Column,
+				Offset:   f.Offset,
+				Severity: f.Severity.String(),
+				Rule:     f.Rule,
+				Message:  f.Message,
+			})
+		}
+	}
+
+	if *format == "json" {
+		enc := json.NewEncoder(stdout)
+		enc.SetIndent("", "  ")
+		enc.Encode(findings)
+	} else {
+		for _, f := range findings {
+			fmt.Fprintf(stdout, "%s:%d:%d: %s: %s (%s)\n", f.File, f.Line, f.Column, f.Severity, f.Message, f.Rule)
+		}
+	}
+
+	return code
+}
+
+// lintRules returns default lint rules, with given deprecated helpers and without given disabled rules
+func lintRules(deprecated []string, disabled []string) []raymond.LintRule {
+	rules := raymond.DefaultLintRules()
+
+	if len(deprecated) > 0 {
+		hints := make(map[string]string)
+		for _, value := range deprecated {
+			parts := strings.SplitN(value, "=", 2)
+			if len(parts) == 2 {
+				hints[parts[0]] = parts[1]
+			} else {
+				hints[parts[0]] = ""
+			}
+		}
+
+		rules = append(rules, raymond.DeprecatedHelperRule(hints))
+	}
+
+	var result []raymond.LintRule
+
+	for _, rule := range rules {
+		if !contains(disabled, rule.Name()) {
+			result = append(result, rule)
+		}
+	}
+
+	return result
+}
+
+// lintFile lints given template file, and returns its findings. A syntax error is returned as a finding.
+func lintFile(filePath string, helpers []string, rules []raymond.LintRule) ([]raymond.LintFinding, error) {
+	tpl, err := raymond.ParseFile(filePath)
+	if err != nil {
+		var perr *raymond.ParseError
+		if !errors.As(err, &perr) {
+			return nil, err
+		}
+
+		msg := perr.Err.Error()
+		if e, ok := perr.Err.(*parser.Error); ok {
+			msg = e.Message
+		}
+
+		// one finding per line
+		msg = strings.Replace(msg, "\n", " - ", -1)
+
+		return []raymond.LintFinding{{
+			ErrorLocation: perr.ErrorLocation,
+			Rule:          syntaxRule,
+			Severity:      raymond.LintError,
+			Message:       msg,
+		}}, nil
+	}
+
+	for i, name := range helpers {
+		if !contains(helpers[:i], name) {
+			tpl.RegisterHelper(name, func(options *raymond.Options) string { return "" })
+		}
+	}
+
+	if len(rules) == 0 {
+		// all rules disabled
+		return nil, nil
+	}
+
+	return tpl.Lint(rules...)
+}
+
+// contains returns true if given list contains given value
+func contains(list []string, value string) bool {
+	for _, v := range list {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
+
+// String implements the flag.Value interface.
+func (l *listFlag) String() string {
+	return strings.Join(*l, ",")
+}
+
+// Set implements the flag.Value interface.
+func (l *listFlag) Set(value string) error {
+	for _, v := range strings.Split(value, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			*l = append(*l, v)
+		}
+	}
+
+	return nil
+}
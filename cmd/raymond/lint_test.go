@@ -0,0 +1,114 @@
+package main
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var lintTests = []struct {
+	name   string
+	args   []string
+	code   int
+	stdout string
+}{
+	{
+		"valid templates",
+		[]string{"valid.hbs"},
+		0,
+		"",
+	},
+	{
+		"text format",
+		[]string{"page.hbs", "broken.hbs"},
+		1,
+		"page.hbs:1:4: warning: Empty 'if' block (empty-block)\n" +
+			"page.hbs:2:9: error: Unescaped mustache in HTML attribute value (attribute-triple-stash)\n" +
+			"page.hbs:3:8: error: Unknown helper 'shout' (unknown-helper)\n" +
+			"broken.hbs:2:6: error: Lexer error - Token: Error{\"Unexpected character in expression: '}'\"} (syntax)\n",
+	},
+	{
+		"custom helpers and disabled rules",
+		[]string{"-helpers", "shout,upcase", "-helpers", "shout", "-disable", "empty-block", "page.hbs"},
+		1,
+		"page.hbs:2:9: error: Unescaped mustache in HTML attribute value (attribute-triple-stash)\n",
+	},
+	{
+		"deprecated helpers",
+		[]string{"-deprecated", "shout=use upcase,old", "-disable", "attribute-triple-stash,empty-block,unknown-helper", "page.hbs"},
+		0,
+		"page.hbs:3:8: warning: Helper 'shout' is deprecated: use upcase (deprecated-helper)\n",
+	},
+	{
+		"fail on warnings",
+		[]string{"-fail-on", "warning", "-disable", "attribute-triple-stash,unknown-helper", "page.hbs"},
+		1,
+		"page.hbs:1:4: warning: Empty 'if' block (empty-block)\n",
+	},
+	{
+		"json format",
+		[]string{"-format", "json", "-disable", "attribute-triple-stash,unknown-helper", "page.hbs"},
+		0,
+		`[
+  {
+    "file": "page.hbs",
+    "line": 1,
+    "column": 4,
+    "offset": 3,
+    "severity": "warning",
+    "rule": "empty-block",
+    "message": "Empty 'if' block"
+  }
+]
+`,
+	},
+	{
+		"json format without findings",
+		[]string{"-format", "json", "valid.hbs"},
+		0,
+		"[]\n",
+	},
+	{
+		"missing file",
+		[]string{"missing.hbs"},
+		1,
+		"",
+	},
+	{
+		"invalid format",
+		[]string{"-format", "xml", "valid.hbs"},
+		2,
+		"",
+	},
+}
+
+func TestLint(t *testing.T) {
+	t.Parallel()
+
+	dir := writeFiles(t, map[string]string{
+		"valid.hbs":  "<p>{{title}}</p>",
+		"page.hbs":   "<p>{{#if title}}{{/if}}</p>\n<a href={{{url}}}>link</a>\n{{#if (shout title)}}x{{/if}}",
+		"broken.hbs": "ok\n{{foo}",
+	})
+
+	for _, test := range lintTests {
+		stdout := &bytes.Buffer{}
+
+		// make paths relative to test directory in output
+		var args []string
+		for _, arg := range test.args {
+			if strings.HasSuffix(arg, ".hbs") {
+				arg = filepath.Join(dir, arg)
+			}
+			args = append(args, arg)
+		}
+
+		code := run(append([]string{"lint"}, args...), nil, stdout, &bytes.Buffer{})
+
+		output := strings.Replace(stdout.String(), dir+string(filepath.Separator), "", -1)
+		if (code != test.code) || (output != test.stdout) {
+			t.Errorf("Test '%s' failed\nexpected\n\t%d %q\ngot\n\t%d %q", test.name, test.code, test.stdout, code, output)
+		}
+	}
+}
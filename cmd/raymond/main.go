@@ -0,0 +1,45 @@
+// Command raymond renders, lints and formats handlebars template files.
+//
+// Usage:
+//
+//	raymond [-data context.json] [-partials dir] [-escape html|none|json] [-strict] [-o output] template.hbs
+//	raymond lint [-format text|json] [-helpers names] [-deprecated names] [-disable rules] [-fail-on error|warning] templates...
+//	raymond fmt [-l] [-w] [-indent spaces] [-quote double|single] [templates...]
+//
+// The context is read from a JSON or YAML file, or from stdin when the data file is "-". Files with a .yaml or .yml
+// extension are decoded as YAML, other ones are decoded as JSON, falling back to YAML if that fails. Partials are the
+// .hbs, .handlebars and .mustache files found in the partials directory, named by their base name without extension.
+//
+// On error, the positioned error is printed on stderr and the exit code is 1. The exit code is 2 on invalid usage.
+//
+// The lint command reports syntax errors and the findings of raymond.DefaultLintRules(), with their positions, as
+// text or JSON. Custom helpers are declared with a comma separated list of names, and deprecated helpers with a
+// comma separated list of names, each one optionally followed by a hint: "name=hint". The exit code is 1 if a
+// finding has at least the severity given by the -fail-on flag.
+//
+// The fmt command formats templates with raymond.Format(), the way gofmt formats Go files: formatted templates are
+// printed, or written back to their files with the -w flag, and the -l flag lists the files whose formatting differs.
+// Without template files, the template is read from stdin and printed formatted.
+package main
+
+import (
+	"io"
+	"os"
+)
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+// run runs the command with given command line arguments, and returns the process exit code
+func run(args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) int {
+	if (len(args) > 0) && (args[0] == "lint") {
+		return runLint(args[1:], stdout, stderr)
+	}
+
+	if (len(args) > 0) && (args[0] == "fmt") {
+		return runFmt(args[1:], stdin, stdout, stderr)
+	}
+
+	return runRender(args, stdin, stdout, stderr)
+}
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"github.com/komand/raymond"
+	"gopkg.in/yaml.v2"
+)
+
+// partialExts are the extensions of partial files
+var partialExts = map[string]bool{
+	".hbs":        true,
+	".handlebars": true,
+	".mustache":   true,
+}
+
+// escapers are the escapers selectable with the -escape flag
+var escapers = map[string]func(string) string{
+	"html": raymond.Escape,
+	"none": func(s string) string { return s },
+	"json": raymond.EscapeJSON,
+}
+
+// runRender renders the template with given command line arguments, and returns the process exit code
+func runRender(args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) int {
+	flags := flag.NewFlagSet("raymond", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+
+	data := flags.String("data", "", "JSON or YAML file holding the context, - to read it from stdin")
+	partials := flags.String("partials", "", "directory holding partial files")
+	escape := flags.String("escape", "html", "escaper of mustaches output: html, none or json")
+	strict := flags.Bool("strict", false, "fail on paths missing from context")
+	output := flags.String("o", "", "output file, instead of stdout")
+
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: raymond [flags] template")
+		flags.PrintDefaults()
+	}
+
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	escaper, ok := escapers[*escape]
+	if !ok || (flags.NArg() != 1) {
+		flags.Usage()
+		return 2
+	}
+
+	result, err := render(flags.Arg(0), *data, *partials, escaper, *strict, stdin)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+
+	if *output != "" {
+		err = ioutil.WriteFile(*output, []byte(result), 0644)
+	} else {
+		_, err = io.WriteString(stdout, result)
+	}
+
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+
+	return 0
+}
+
+// render evaluates template file with context loaded from data file
+func render(filePath string, dataPath string, partialsDir string, escaper func(string) string, strict bool, stdin io.Reader) (string, error) {
+	ctx, err := loadContext(dataPath, stdin)
+	if err != nil {
+		return "", err
+	}
+
+	tpl, err := raymond.ParseFile(filePath)
+	if err != nil {
+		return "", err
+	}
+
+	tpl.SetEscaper(escaper)
+	tpl.SetStrict(strict)
+
+	if partialsDir != "" {
+		files, err := partialFiles(partialsDir)
+		if err != nil {
+			return "", err
+		}
+
+		if err := tpl.RegisterPartialFiles(files...); err != nil {
+			return "", err
+		}
+	}
+
+	return tpl.ExecWith(ctx, nil)
+}
+
+// loadContext reads context from given JSON or YAML file, or from stdin if file path is "-"
+func loadContext(filePath string, stdin io.Reader) (interface{}, error) {
+	if filePath == "" {
+		return nil, nil
+	}
+
+	var b []byte
+	var err error
+
+	if filePath == "-" {
+		b, err = ioutil.ReadAll(stdin)
+	} else {
+		b, err = ioutil.ReadFile(filePath)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	switch strings.ToLower(filepath.Ext(filePath)) {
+	case ".json":
+		return decodeJSON(b)
+	case ".yaml", ".yml":
+		return decodeYAML(b)
+	}
+
+	if result, err := decodeJSON(b); err == nil {
+		return result, nil
+	}
+
+	return decodeYAML(b)
+}
+
+// decodeJSON decodes given JSON context
+func decodeJSON(b []byte) (interface{}, error) {
+	var result interface{}
+
+	if err := json.Unmarshal(b, &result); err != nil {
+		return nil, fmt.Errorf("Failed to decode JSON context: %s", err)
+	}
+
+	return result, nil
+}
+
+// decodeYAML decodes given YAML context
+func decodeYAML(b []byte) (interface{}, error) {
+	var result interface{}
+
+	if err := yaml.Unmarshal(b, &result); err != nil {
+		return nil, fmt.Errorf("Failed to decode YAML context: %s", err)
+	}
+
+	return stringKeys(result), nil
+}
+
+// stringKeys converts maps decoded from YAML to maps with string keys, like the ones decoded from JSON
+func stringKeys(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[interface{}]interface{}:
+		result := make(map[string]interface{}, len(v))
+		for key, val := range v {
+			result[fmt.Sprint(key)] = stringKeys(val)
+		}
+
+		return result
+	case []interface{}:
+		for i, val := range v {
+			v[i] = stringKeys(val)
+		}
+	}
+
+	return value
+}
+
+// partialFiles returns partial files found in given directory
+func partialFiles(dir string) ([]string, error) {
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var result []string
+
+	for _, info := range infos {
+		if !info.IsDir() && partialExts[filepath.Ext(info.Name())] {
+			result = append(result, filepath.Join(dir, info.Name()))
+		}
+	}
+
+	return result, nil
+}
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeFiles writes given files in a temporary directory, and returns that directory
+func writeFiles(t *testing.T, files map[string]string) string {
+	dir := t.TempDir()
+
+	for name, content := range files {
+		filePath := filepath.Join(dir, name)
+
+		if err := os.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := ioutil.WriteFile(filePath, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return dir
+}
+
+var runTests = []struct {
+	name   string
+	args   []string
+	stdin  string
+	code   int
+	stdout string
+	stderr string
+}{
+	{
+		"json context",
+		[]string{"-data", "ctx.json", "page.hbs"},
+		"",
+		0,
+		"<h1>Hi &amp; bye</h1>- a\n- b\n",
+		"",
+	},
+	{
+		"yaml context",
+		[]string{"-data", "ctx.yml", "page.hbs"},
+		"",
+		0,
+		"<h1>Hi &amp; bye</h1>- a\n- b\n",
+		"",
+	},
+	{
+		"stdin context",
+		[]string{"-data", "-", "-escape", "none", "page.hbs"},
+		"title: Hi & bye\ntags: [a]",
+		0,
+		"<h1>Hi & bye</h1>- a\n",
+		"",
+	},
+	{
+		"json escaper",
+		[]string{"-data", "-", "-escape", "json", "title.hbs"},
+		`{"title": "\"Hi\"\n"}`,
+		0,
+		`{"title": "\"Hi\"\n"}`,
+		"",
+	},
+	{
+		"no context",
+		[]string{"title.hbs"},
+		"",
+		0,
+		`{"title": ""}`,
+		"",
+	},
+	{
+		"strict mode",
+		[]string{"-data", "ctx.json", "-strict", "title.hbs"},
+		"",
+		0,
+		`{"title": "Hi &amp; bye"}`,
+		"",
+	},
+	{
+		"strict mode failure",
+		[]string{"-data", "-", "-strict", "page.hbs"},
+		`{"tags": []}`,
+		1,
+		"",
+		"Evaluation error on line 1, column 7 of template 'header': Missing field 'title'\n",
+	},
+	{
+		"strict mode with empty context",
+		[]string{"-data", "-", "-strict", "title.hbs"},
+		`{}`,
+		1,
+		"",
+		"Evaluation error on line 1, column 14 of template 'title.hbs': Missing field 'title'\n",
+	},
+	{
+		"strict mode with null context",
+		[]string{"-data", "-", "-strict", "title.hbs"},
+		`null`,
+		1,
+		"",
+		"Evaluation error on line 1, column 14 of template 'title.hbs': Missing field 'title'\n",
+	},
+	{
+		"strict mode without context",
+		[]string{"-strict", "title.hbs"},
+		"",
+		1,
+		"",
+		"Evaluation error on line 1, column 14 of template 'title.hbs': Missing field 'title'\n",
+	},
+	{
+		"parse error",
+		[]string{"broken.hbs"},
+		"",
+		1,
+		"",
+		"Parse error on line 2, column 6 of template 'broken.hbs':\nLexer error",
+	},
+	{
+		"invalid context",
+		[]string{"-data", "ctx.txt", "page.hbs"},
+		"",
+		1,
+		"",
+		"Failed to decode YAML context: ",
+	},
+	{
+		"unknown escaper",
+		[]string{"-escape", "xml", "page.hbs"},
+		"",
+		2,
+		"",
+		"Usage: raymond [flags] template\n",
+	},
+	{
+		"missing template",
+		[]string{"-strict"},
+		"",
+		2,
+		"",
+		"Usage: raymond [flags] template\n",
+	},
+}
+
+func TestRun(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"page.hbs":             "{{> header}}\n{{#each tags}}- {{.}}\n{{/each}}",
+		"title.hbs":            `{"title": "{{title}}"}`,
+		"broken.hbs":           "ok\n{{foo}",
+		"ctx.json":             `{"title": "Hi & bye", "tags": ["a", "b"]}`,
+		"ctx.yml":              "title: Hi & bye\ntags:\n  - a\n  - b\n",
+		"ctx.txt":              "title: [",
+		"partials/header.hbs":  "<h1>{{title}}</h1>",
+		"partials/footer.txt":  "not a partial",
+		"partials/sub/foo.hbs": "not registered",
+	})
+
+	// all paths are relative to test directory
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+
+	for _, test := range runTests {
+		stdout := &bytes.Buffer{}
+		stderr := &bytes.Buffer{}
+
+		args := append([]string{"-partials", "partials"}, test.args...)
+
+		code := run(args, strings.NewReader(test.stdin), stdout, stderr)
+		if (code != test.code) || (stdout.String() != test.stdout) || !strings.HasPrefix(stderr.String(), test.stderr) {
+			t.Errorf("Test '%s' failed\nexpected\n\t%d %q %q\ngot\n\t%d %q %q", test.name, test.code, test.stdout, test.stderr, code, stdout, stderr)
+		}
+	}
+}
+
+func TestRunOutputFile(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"title.hbs": "{{title}}",
+		"ctx.json":  `{"title": "Hi"}`,
+	})
+
+	output := filepath.Join(dir, "out.txt")
+	args := []string{"-data", filepath.Join(dir, "ctx.json"), "-o", output, filepath.Join(dir, "title.hbs")}
+
+	stdout := &bytes.Buffer{}
+	if code := run(args, nil, stdout, stdout); (code != 0) || (stdout.Len() != 0) {
+		t.Fatalf("Erroneous run: %d %q", code, stdout)
+	}
+
+	if b, err := ioutil.ReadFile(output); (err != nil) || (string(b) != "Hi") {
+		t.Errorf("Erroneous output file: %q %v", b, err)
+	}
+}
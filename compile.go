@@ -0,0 +1,535 @@
+package raymond
+
+import (
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/komand/raymond/ast"
+)
+
+// compiledProgram is a program compiled to a tree of closures
+type compiledProgram func(v *evalVisitor) string
+
+// compiledStatement evaluates a compiled statement
+type compiledStatement func(v *evalVisitor) string
+
+// compiledExpression evaluates a compiled expression, and returns a boolean set to true if it was a helper call
+type compiledExpression func(v *evalVisitor) (interface{}, bool)
+
+// compiledValue evaluates a compiled helper param or hash value
+type compiledValue func(v *evalVisitor) interface{}
+
+// helperCaller calls a helper with given params without reflection, options are nil if the helper does not take them
+type helperCaller func(v *evalVisitor, params []interface{}, options *Options) interface{}
+
+// compiledBlock holds the compiled programs of a block statement
+type compiledBlock struct {
+	program compiledProgram
+	inverse compiledProgram
+}
+
+// helperBinding is the helper bound to a helper call, with the template and helpers versions it was looked up with
+type helperBinding struct {
+	tpl        *Template
+	tplVersion uint64
+	version    uint64
+	helper     reflect.Value
+	caller     helperCaller
+	options    bool // caller takes options
+}
+
+// compiler compiles programs, binding helper calls to the helpers of a template
+type compiler struct {
+	tpl *Template
+}
+
+// programCache stores the compiled programs of a template
+//
+// Compiled programs do not depend on the template evaluating them, so they are shared with the templates created
+// for inline partials and partial blocks.
+type programCache struct {
+	programs sync.Map // *ast.Program => compiledProgram
+}
+
+// helpersVersion is incremented each time a global helper is registered, so that helper calls are bound again
+var helpersVersion uint64
+
+// newProgramCache instanciates a new programCache
+func newProgramCache() *programCache {
+	return &programCache{}
+}
+
+// program returns given program compiled, compiling it with helpers of given template if necessary
+func (cache *programCache) program(node *ast.Program, tpl *Template) compiledProgram {
+	if cached, ok := cache.programs.Load(node); ok {
+		return cached.(compiledProgram)
+	}
+
+	c := &compiler{tpl: tpl}
+	eval := c.compileProgram(node)
+	cache.programs.Store(node, eval)
+
+	return eval
+}
+
+// reset drops all compiled programs, it must be called when the AST is modified
+func (cache *programCache) reset() {
+	cache.programs.Range(func(key, _ interface{}) bool {
+		cache.programs.Delete(key)
+		return true
+	})
+}
+
+//
+// Statements
+//
+
+// compileProgram compiles given program
+//
+// The programs of nested blocks are compiled too, and evaluated with VisitProgram() while their block is evaluated.
+// Other nested programs (inline partials, partial blocks) are fetched from the programs cache.
+func (c *compiler) compileProgram(node *ast.Program) compiledProgram {
+	var statements []compiledStatement
+	var nodes []ast.Node // statement nodes, for tracer and source map
+
+	decorated := false
+
+	for _, n := range node.Body {
+		switch stmt := n.(type) {
+		case *ast.ContentStatement:
+			value := stmt.Value
+			statements = append(statements, func(v *evalVisitor) string { return value })
+		case *ast.CommentStatement:
+			// ignore comments
+		case *ast.MustacheStatement:
+			statements = append(statements, c.compileMustache(stmt))
+		case *ast.BlockStatement:
+			statements = append(statements, c.compileBlock(stmt))
+		case *ast.Decorator, *ast.DecoratorBlock:
+			// applied before evaluating program statements
+			decorated = true
+		default:
+			statements = append(statements, c.compileNode(n))
+		}
+
+		if len(statements) > len(nodes) {
+			nodes = append(nodes, n)
+		}
+	}
+
+	// greatest output length, so that output is allocated only once
+	var size int64
+
+	return func(v *evalVisitor) string {
+		v.at(node)
+
+		if decorated && v.pushDecorators(node) {
+			defer v.popDecorators()
+		}
+
+		if (v.tracer != nil) || (v.sourceMap != nil) {
+			return v.evalInstrumented(node, statements, nodes)
+		}
+
+		switch len(statements) {
+		case 0:
+			return ""
+		case 1:
+			return statements[0](v)
+		}
+
+		var buf strings.Builder
+		buf.Grow(int(atomic.LoadInt64(&size)))
+
+		for _, stmt := range statements {
+			buf.WriteString(stmt(v))
+		}
+
+		if int64(buf.Len()) > atomic.LoadInt64(&size) {
+			atomic.StoreInt64(&size, int64(buf.Len()))
+		}
+
+		return buf.String()
+	}
+}
+
+// evalInstrumented evaluates given compiled statements of given program, notifies tracer and records source map
+func (v *evalVisitor) evalInstrumented(node *ast.Program, statements []compiledStatement, nodes []ast.Node) string {
+	if v.tracer != nil {
+		v.tracer.EnterNode(node)
+		defer v.tracer.ExitNode(node)
+	}
+
+	var buf strings.Builder
+	var spans []Span
+
+	for i, stmt := range statements {
+		start := buf.Len()
+		output := v.evalInstrumentedStatement(nodes[i], stmt)
+		buf.WriteString(output)
+
+		if v.sourceMap != nil {
+			spans = v.sourceMap.exitStatement(spans, v.curTpl, nodes[i], output, start)
+		}
+	}
+
+	result := buf.String()
+
+	if v.sourceMap != nil {
+		v.sourceMap.addProgram(result, spans)
+	}
+
+	return result
+}
+
+// evalInstrumentedStatement evaluates given compiled statement of given node, and notifies tracer
+func (v *evalVisitor) evalInstrumentedStatement(node ast.Node, stmt compiledStatement) string {
+	if v.tracer != nil {
+		v.tracer.EnterNode(node)
+		defer v.tracer.ExitNode(node)
+	}
+
+	if v.sourceMap != nil {
+		v.sourceMap.enterStatement()
+	}
+
+	return stmt(v)
+}
+
+// compileNode compiles a statement that is evaluated by visiting it
+func (c *compiler) compileNode(node ast.Node) compiledStatement {
+	return func(v *evalVisitor) string {
+		return v.str(node.Accept(v))
+	}
+}
+
+// compileMustache compiles given mustache statement
+func (c *compiler) compileMustache(node *ast.MustacheStatement) compiledStatement {
+	expr := c.compileExpression(node.Expression)
+
+	return func(v *evalVisitor) string {
+		v.at(node)
+
+		result, _ := expr(v)
+
+		return v.mustacheOutput(node, result)
+	}
+}
+
+// compileBlock compiles given block statement
+func (c *compiler) compileBlock(node *ast.BlockStatement) compiledStatement {
+	expr := c.compileExpression(node.Expression)
+
+	programs := &compiledBlock{}
+	if node.Program != nil {
+		programs.program = c.compileProgram(node.Program)
+	}
+	if node.Inverse != nil {
+		programs.inverse = c.compileProgram(node.Inverse)
+	}
+
+	return func(v *evalVisitor) string {
+		v.at(node)
+
+		v.pushBlock(node, programs)
+
+		result, helperCall := expr(v)
+		result = v.blockOutput(node, result, helperCall)
+
+		v.popBlock()
+
+		return v.str(result)
+	}
+}
+
+//
+// Expressions
+//
+
+// compileExpression compiles given expression
+func (c *compiler) compileExpression(node *ast.Expression) compiledExpression {
+	helper := c.compileHelperCall(node)
+	literal, isLiteral := node.LiteralStr()
+	path := node.FieldPath()
+
+	return func(v *evalVisitor) (interface{}, bool) {
+		v.at(node)
+
+		var result interface{}
+		helperCall := false
+
+		v.pushExpr(node)
+
+		// helper call
+		if helper != nil {
+			result, helperCall = helper(v)
+		}
+
+		done := helperCall
+
+		if !done && isLiteral {
+			// literal
+			if val := v.evalField(v.curCtx(), literal, true); val.IsValid() {
+				result = val.Interface()
+				done = true
+			}
+		}
+
+		if !done && (path != nil) {
+			// field path, at root of current expression
+			if val := v.evalPathExpression(path, true); val != nil {
+				result = val
+			}
+		}
+
+		v.popExpr()
+
+		return result, helperCall
+	}
+}
+
+// compileHelperCall compiles the helper call of given expression, and returns nil if that expression can't be a
+// helper call. The returned function returns false if no helper was found at evaluation time.
+//
+// The helper is bound at compile time, and bound again only when the call is evaluated with another template or
+// when a helper was registered since then, so that no lock is taken on evaluation. Helpers registered by decorators
+// are still resolved at evaluation time, when there are some.
+func (c *compiler) compileHelperCall(node *ast.Expression) compiledExpression {
+	name := node.HelperName()
+	if name == "" {
+		return nil
+	}
+
+	nbParams := len(node.Params)
+
+	var binding atomic.Value // *helperBinding
+	binding.Store(bindHelper(c.tpl, name, nbParams))
+
+	params := c.compileValues(node.Params)
+	hash := c.compileHash(node.Hash)
+
+	return func(v *evalVisitor) (interface{}, bool) {
+		bound := binding.Load().(*helperBinding)
+		if !bound.valid(v.tpl) {
+			bound = bindHelper(v.tpl, name, nbParams)
+			binding.Store(bound)
+		}
+
+		helper, caller := bound.helper, bound.caller
+
+		if len(v.scopes) > 0 {
+			if h := v.findDecoratorHelper(name); h != zero {
+				helper, caller = h, nil
+			}
+		}
+
+		if helper == zero {
+			return nil, false
+		}
+
+		if (caller != nil) && !bound.options {
+			// helper can't retain params without options, so they are evaluated on the args stack
+			start := len(v.args)
+			for _, param := range params {
+				v.args = append(v.args, param(v))
+			}
+			hash(v)
+
+			result := v.callDirect(name, caller, v.args[start:], nil)
+			v.args = v.args[:start]
+
+			return result, true
+		}
+
+		args := evalValues(v, params)
+		options := newOptions(v, args, hash(v))
+
+		if caller != nil {
+			return v.callDirect(name, caller, args, options), true
+		}
+
+		result := v.callFunc(name, helper, options)
+		if !result.IsValid() {
+			return nil, true
+		}
+
+		return result.Interface(), true
+	}
+}
+
+// bindHelper returns the helper with given name for given template, called with given number of params
+func bindHelper(tpl *Template, name string, nbParams int) *helperBinding {
+	result := &helperBinding{
+		tpl:     tpl,
+		version: atomic.LoadUint64(&helpersVersion),
+	}
+
+	if tpl != nil {
+		result.tplVersion = atomic.LoadUint64(&tpl.helpersVersion)
+		result.helper = tpl.findHelper(name)
+	}
+
+	if result.helper == zero {
+		result.helper = findHelper(name)
+	}
+
+	if result.helper != zero {
+		result.caller, result.options = directCaller(result.helper, nbParams)
+	}
+
+	return result
+}
+
+// valid returns true if binding can be used to evaluate a helper call with given template
+func (b *helperBinding) valid(tpl *Template) bool {
+	return (b.tpl == tpl) &&
+		(b.tplVersion == atomic.LoadUint64(&tpl.helpersVersion)) &&
+		(b.version == atomic.LoadUint64(&helpersVersion))
+}
+
+// directCaller returns a function that calls given helper without reflection, or nil if the helper signature is
+// not supported, and a boolean set to true if that function takes options. Only the most common signatures are
+// supported, when called with the expected number of params.
+func directCaller(helper reflect.Value, nbParams int) (helperCaller, bool) {
+	switch fn := helper.Interface().(type) {
+	case func() string:
+		if nbParams == 0 {
+			return func(v *evalVisitor, params []interface{}, options *Options) interface{} { return fn() }, false
+		}
+	case func(string) string:
+		if nbParams == 1 {
+			// param is converted to string, as with reflection
+			return func(v *evalVisitor, params []interface{}, options *Options) interface{} { return fn(v.str(params[0])) }, false
+		}
+	case func(*Options) interface{}:
+		if nbParams == 0 {
+			return func(v *evalVisitor, params []interface{}, options *Options) interface{} { return fn(options) }, true
+		}
+	case func(*Options) string:
+		if nbParams == 0 {
+			return func(v *evalVisitor, params []interface{}, options *Options) interface{} { return fn(options) }, true
+		}
+	case func(*Options) SafeString:
+		if nbParams == 0 {
+			return func(v *evalVisitor, params []interface{}, options *Options) interface{} { return fn(options) }, true
+		}
+	case func(interface{}, *Options) interface{}:
+		if nbParams == 1 {
+			return func(v *evalVisitor, params []interface{}, options *Options) interface{} {
+				return fn(params[0], options)
+			}, true
+		}
+	case func(interface{}, *Options) string:
+		if nbParams == 1 {
+			return func(v *evalVisitor, params []interface{}, options *Options) interface{} {
+				return fn(params[0], options)
+			}, true
+		}
+	case func(interface{}, *Options) SafeString:
+		if nbParams == 1 {
+			return func(v *evalVisitor, params []interface{}, options *Options) interface{} {
+				return fn(params[0], options)
+			}, true
+		}
+	case func(interface{}, interface{}, *Options) interface{}:
+		if nbParams == 2 {
+			return func(v *evalVisitor, params []interface{}, options *Options) interface{} {
+				return fn(params[0], params[1], options)
+			}, true
+		}
+	}
+
+	return nil, false
+}
+
+// compileValues compiles given helper params
+func (c *compiler) compileValues(nodes []ast.Node) []compiledValue {
+	var result []compiledValue
+
+	for _, n := range nodes {
+		result = append(result, c.compileValue(n))
+	}
+
+	return result
+}
+
+// evalValues evaluates given compiled params
+func evalValues(v *evalVisitor, values []compiledValue) []interface{} {
+	if len(values) == 0 {
+		return nil
+	}
+
+	result := make([]interface{}, len(values))
+	for i, value := range values {
+		result[i] = value(v)
+	}
+
+	return result
+}
+
+// compileValue compiles given helper param or hash value
+func (c *compiler) compileValue(node ast.Node) compiledValue {
+	switch n := node.(type) {
+	case *ast.StringLiteral:
+		value := n.Value
+		return func(v *evalVisitor) interface{} {
+			v.at(n)
+			return value
+		}
+	case *ast.BooleanLiteral:
+		value := n.Value
+		return func(v *evalVisitor) interface{} {
+			v.at(n)
+			return value
+		}
+	case *ast.NumberLiteral:
+		value := n.Number()
+		return func(v *evalVisitor) interface{} {
+			v.at(n)
+			return value
+		}
+	case *ast.PathExpression:
+		return func(v *evalVisitor) interface{} { return v.evalPathExpression(n, false) }
+	case *ast.SubExpression:
+		expr := c.compileExpression(n.Expression)
+		return func(v *evalVisitor) interface{} {
+			v.at(n)
+
+			result, _ := expr(v)
+			return result
+		}
+	}
+
+	return func(v *evalVisitor) interface{} { return node.Accept(v) }
+}
+
+// compileHash compiles given hash, the returned function returns nil if there is no hash
+func (c *compiler) compileHash(node *ast.Hash) func(v *evalVisitor) map[string]interface{} {
+	if node == nil {
+		return func(v *evalVisitor) map[string]interface{} { return nil }
+	}
+
+	values := make([]compiledValue, len(node.Pairs))
+
+	for i, pair := range node.Pairs {
+		values[i] = c.compileValue(pair.Val)
+	}
+
+	return func(v *evalVisitor) map[string]interface{} {
+		v.at(node)
+
+		result := make(map[string]interface{}, len(values))
+
+		for i, pair := range node.Pairs {
+			v.at(pair)
+
+			if val := values[i](v); val != nil {
+				result[pair.Key] = val
+			}
+		}
+
+		return result
+	}
+}
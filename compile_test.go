@@ -0,0 +1,137 @@
+package raymond
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestCompiledHelpers(t *testing.T) {
+	t.Parallel()
+
+	tpl := MustParse(`{{#if ok}}{{compiledHi}}{{/if}}`)
+	ctx := map[string]interface{}{"ok": true, "compiledHi": "ctx"}
+
+	// not a helper: evaluated as a context field
+	if output := tpl.MustExec(ctx); output != "ctx" {
+		t.Errorf("Failed to evaluate compiled template, got: %q", output)
+	}
+
+	// template helper registered after compilation
+	tpl.RegisterHelper("compiledHi", func(options *Options) string { return "hi" })
+
+	if output := tpl.MustExec(ctx); output != "hi" {
+		t.Errorf("Compiled template ignored new template helper, got: %q", output)
+	}
+}
+
+func TestCompiledBoundHelpers(t *testing.T) {
+	t.Parallel()
+
+	tpl := MustParse(`{{#if ok}}{{lookup . "name"}}{{/if}}`)
+	ctx := map[string]interface{}{"ok": true, "name": "ctx"}
+
+	// bound to global helper at compile time
+	if output := tpl.MustExec(ctx); output != "ctx" {
+		t.Errorf("Failed to evaluate compiled template, got: %q", output)
+	}
+
+	// template helper takes precedence over bound global helper
+	tpl.RegisterHelper("lookup", func(obj interface{}, field string) string { return "hi" })
+
+	if output := tpl.MustExec(ctx); output != "hi" {
+		t.Errorf("Compiled template ignored new template helper, got: %q", output)
+	}
+}
+
+func TestCompiledClonesHelpers(t *testing.T) {
+	t.Parallel()
+
+	tpl := MustParse(`{{#each items}}{{hi (name)}}{{/each}}`)
+	tpl.RegisterHelper("name", func() string { return "foo" })
+
+	clone := tpl.Clone()
+
+	tpl.RegisterHelper("hi", func(name string) string { return "hi " + name + " " })
+	clone.RegisterHelper("hi", func(name string) string { return "hello " + name + " " })
+
+	ctx := map[string]interface{}{"items": []int{1, 2}}
+
+	// clone shares compiled programs, but helper calls are bound to the helpers of the evaluated template
+	for i := 0; i < 2; i++ {
+		if output := tpl.MustExec(ctx); output != "hi foo hi foo " {
+			t.Errorf("Failed to evaluate template helpers, got: %q", output)
+		}
+
+		if output := clone.MustExec(ctx); output != "hello foo hello foo " {
+			t.Errorf("Failed to evaluate clone helpers, got: %q", output)
+		}
+	}
+}
+
+func TestCompiledExecAfterError(t *testing.T) {
+	t.Parallel()
+
+	tpl := MustParse(`{{#each items}}{{@index}}{{check .}}{{/each}}`)
+	tpl.RegisterHelper("check", func(item string) string {
+		if item == "" {
+			panic(errors.New("empty item"))
+		}
+		return item
+	})
+
+	if _, err := tpl.Exec(map[string]interface{}{"items": []string{"a", ""}}); err == nil {
+		t.Errorf("Expected an error")
+	}
+
+	if output := tpl.MustExec(map[string]interface{}{"items": []string{"a", "b"}}); output != "0a1b" {
+		t.Errorf("Failed to evaluate template after an error, got: %q", output)
+	}
+}
+
+func TestCompiledNestedPrograms(t *testing.T) {
+	t.Parallel()
+
+	tpl := MustParse(`{{#if ok}}{{#each items}}{{.}}{{else}}none{{/each}}{{else if ko}}ko{{/if}}`)
+
+	for _, ctx := range []map[string]interface{}{
+		{"ok": true, "items": []int{1, 2}},
+		{"ok": true},
+		{"ko": true},
+	} {
+		tpl.MustExec(ctx)
+	}
+
+	// nested programs are compiled with root program
+	count := 0
+	tpl.compiled.programs.Range(func(key, _ interface{}) bool {
+		count++
+		return true
+	})
+
+	if count != 1 {
+		t.Errorf("Expected only root program in cache, got %d programs", count)
+	}
+}
+
+func TestCompiledRename(t *testing.T) {
+	t.Parallel()
+
+	tpl := MustParse(`{{#each items}}{{foo}}{{/each}}`)
+	clone := tpl.Clone()
+	ctx := map[string]interface{}{"items": []map[string]string{{"foo": "a", "bar": "b"}}}
+
+	if output := tpl.MustExec(ctx); output != "a" {
+		t.Errorf("Failed to evaluate compiled template, got: %q", output)
+	}
+
+	if err := tpl.Rename(map[string]string{"foo": "bar"}); err != nil {
+		t.Fatalf("Failed to rename: %s", err)
+	}
+
+	// clone shares the renamed AST
+	for _, tpl := range []*Template{tpl, clone} {
+		if output := tpl.MustExec(ctx); output != "b" {
+			t.Errorf("Compiled template not invalidated by rename, got: %q", output)
+		}
+	}
+}
@@ -8,6 +8,16 @@ import "reflect"
 type DataFrame struct {
 	parent *DataFrame
 	data   map[string]interface{}
+
+	// iteration data (@index, @key, @first, @last), kept out of the data map so that a frame is allocated
+	// only once per iteration
+	iter   bool
+	index  int
+	length int
+	key    interface{}
+
+	// logger of the log helper, for the evaluation started with that frame
+	logger Logger
 }
 
 // NewDataFrame instanciates a new private data frame.
@@ -19,13 +29,21 @@ func NewDataFrame() *DataFrame {
 
 // Copy instanciates a new private data frame with receiver as parent.
 func (p *DataFrame) Copy() *DataFrame {
-	result := NewDataFrame()
-
-	for k, v := range p.data {
-		result.data[k] = v
+	result := &DataFrame{
+		parent: p,
+		iter:   p.iter,
+		index:  p.index,
+		length: p.length,
+		key:    p.key,
 	}
 
-	result.parent = p
+	if len(p.data) > 0 {
+		result.data = make(map[string]interface{}, len(p.data))
+
+		for k, v := range p.data {
+			result.data[k] = v
+		}
+	}
 
 	return result
 }
@@ -34,16 +52,27 @@ func (p *DataFrame) Copy() *DataFrame {
 func (p *DataFrame) newIterDataFrame(length int, i int, key interface{}) *DataFrame {
 	result := p.Copy()
 
-	result.Set("index", i)
-	result.Set("key", key)
-	result.Set("first", i == 0)
-	result.Set("last", i == length-1)
+	result.iter = true
+	result.index = i
+	result.length = length
+	result.key = key
 
 	return result
 }
 
+// SetLogger sets the logger receiving the messages of the log helper, when that frame is the initial private data
+// frame given to Template.ExecWith(). It takes precedence over the logger set with Template.SetLogger(), so that a
+// logger can be set for a single execution.
+func (p *DataFrame) SetLogger(logger Logger) {
+	p.logger = logger
+}
+
 // Set sets a data value.
 func (p *DataFrame) Set(key string, val interface{}) {
+	if p.data == nil {
+		p.data = make(map[string]interface{})
+	}
+
 	p.data[key] = val
 }
 
@@ -52,14 +81,42 @@ func (p *DataFrame) Get(key string) interface{} {
 	return p.find([]string{key})
 }
 
+// get gets a data value, and a boolean to indicate if it is set
+func (p *DataFrame) get(key string) (interface{}, bool) {
+	if val, ok := p.data[key]; ok {
+		return val, true
+	}
+
+	if p.iter {
+		switch key {
+		case "index":
+			return p.index, true
+		case "key":
+			return p.key, true
+		case "first":
+			return p.index == 0, true
+		case "last":
+			return p.index == p.length-1, true
+		}
+	}
+
+	return nil, false
+}
+
 // find gets a deep data value
 //
 // @todo This is NOT consistent with the way we resolve data in template (cf. `evalDataPathExpression()`) ! FIX THAT !
 func (p *DataFrame) find(parts []string) interface{} {
-	data := p.data
+	var data map[string]interface{}
 
 	for i, part := range parts {
-		val := data[part]
+		var val interface{}
+		if i == 0 {
+			val, _ = p.get(part)
+		} else {
+			val = data[part]
+		}
+
 		if val == nil {
 			return nil
 		}
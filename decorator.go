@@ -0,0 +1,226 @@
+package raymond
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+
+	"github.com/komand/raymond/ast"
+)
+
+// Decorator is a function applied to a program before it is evaluated.
+//
+// A decorator is called with a decorator statement: {{* name params... hash}}, or with a decorator block:
+// {{#* name params... hash}}...{{/name}}. It can change the evaluation environment of the program holding that
+// statement: its private data frame, and the helpers and partials available to it.
+type Decorator func(options *DecoratorOptions) error
+
+// DecoratorOptions represents the argument provided to decorators.
+type DecoratorOptions struct {
+	// evaluation visitor
+	eval *evalVisitor
+
+	name string
+
+	// decorated program
+	program *ast.Program
+
+	// content of decorator block
+	block *ast.Program
+
+	// params
+	params []interface{}
+	hash   map[string]interface{}
+
+	// helpers and partials registered by decorators of decorated program
+	scope *decoratorScope
+}
+
+// decoratorScope holds the helpers and partials registered by the decorators of a program
+type decoratorScope struct {
+	program  *ast.Program
+	helpers  map[string]reflect.Value
+	partials map[string]*partial
+
+	// data frame to restore when leaving scope
+	prevFrame *DataFrame
+}
+
+// decorators stores all globally registered decorators
+var decorators = make(map[string]Decorator)
+
+// protects global decorators
+var decoratorsMutex sync.RWMutex
+
+func init() {
+	// register builtin decorators
+	RegisterDecorator("inline", inlineDecorator)
+}
+
+// RegisterDecorator registers a global decorator. That decorator will be available to all templates.
+func RegisterDecorator(name string, decorator Decorator) {
+	decoratorsMutex.Lock()
+	defer decoratorsMutex.Unlock()
+
+	if decorators[name] != nil {
+		panic(fmt.Errorf("Decorator already registered: %s", name))
+	}
+
+	decorators[name] = decorator
+}
+
+// RegisterDecorators registers several global decorators. Those decorators will be available to all templates.
+func RegisterDecorators(decorators map[string]Decorator) {
+	for name, decorator := range decorators {
+		RegisterDecorator(name, decorator)
+	}
+}
+
+// findDecorator finds a globally registered decorator
+func findDecorator(name string) Decorator {
+	decoratorsMutex.RLock()
+	defer decoratorsMutex.RUnlock()
+
+	return decorators[name]
+}
+
+// newDecoratorScope instanciates a new decoratorScope
+func newDecoratorScope(program *ast.Program, prevFrame *DataFrame) *decoratorScope {
+	return &decoratorScope{
+		program:   program,
+		helpers:   make(map[string]reflect.Value),
+		partials:  make(map[string]*partial),
+		prevFrame: prevFrame,
+	}
+}
+
+//
+// Decorator
+//
+
+// Name returns the decorator name.
+func (options *DecoratorOptions) Name() string {
+	return options.name
+}
+
+// Program returns the decorated program, ie. the program holding the decorator statement.
+func (options *DecoratorOptions) Program() *ast.Program {
+	return options.program
+}
+
+// Block returns the content of a decorator block, or nil for a decorator statement.
+func (options *DecoratorOptions) Block() *ast.Program {
+	return options.block
+}
+
+// Ctx returns current evaluation context.
+func (options *DecoratorOptions) Ctx() interface{} {
+	return options.eval.curCtx().Interface()
+}
+
+//
+// Hash Arguments
+//
+
+// HashProp returns hash property.
+func (options *DecoratorOptions) HashProp(name string) interface{} {
+	return options.hash[name]
+}
+
+// HashStr returns string representation of hash property.
+func (options *DecoratorOptions) HashStr(name string) string {
+	return Str(options.hash[name])
+}
+
+// Hash returns entire hash.
+func (options *DecoratorOptions) Hash() map[string]interface{} {
+	return options.hash
+}
+
+//
+// Parameters
+//
+
+// Param returns parameter at given position.
+func (options *DecoratorOptions) Param(pos int) interface{} {
+	if len(options.params) > pos {
+		return options.params[pos]
+	}
+
+	return nil
+}
+
+// ParamStr returns string representation of parameter at given position.
+func (options *DecoratorOptions) ParamStr(pos int) string {
+	return Str(options.Param(pos))
+}
+
+// Params returns all parameters.
+func (options *DecoratorOptions) Params() []interface{} {
+	return options.params
+}
+
+//
+// Environment
+//
+
+// DataFrame returns the private data frame used to evaluate decorated program. Values set in that frame are
+// only visible inside decorated program.
+func (options *DecoratorOptions) DataFrame() *DataFrame {
+	return options.eval.dataFrame
+}
+
+// RegisterHelper registers a helper that is only available inside decorated program. It takes precedence over
+// template and global helpers.
+func (options *DecoratorOptions) RegisterHelper(name string, helper interface{}) {
+	val := reflect.ValueOf(helper)
+	ensureValidHelper(name, val)
+
+	options.scope.helpers[name] = val
+}
+
+// RegisterPartial registers a partial that is only available inside decorated program, including in partials
+// called from that program. It takes precedence over template and global partials.
+func (options *DecoratorOptions) RegisterPartial(name string, source string) {
+	p := newPartial(name, source, nil)
+	p.unescaped = options.eval.curTpl.unescaped
+
+	options.scope.partials[name] = p
+}
+
+// RegisterPartialTemplate registers a partial with given parsed template, that is only available inside
+// decorated program.
+func (options *DecoratorOptions) RegisterPartialTemplate(name string, tpl *Template) {
+	options.scope.partials[name] = newPartial(name, "", tpl)
+}
+
+// RegisterBlockPartial registers the content of decorator block as a partial, that is only available inside
+// decorated program.
+func (options *DecoratorOptions) RegisterBlockPartial(name string) error {
+	if options.block == nil {
+		return errors.New("Decorator has no block")
+	}
+
+	options.scope.partials[name] = options.eval.programPartial(name, options.block)
+
+	return nil
+}
+
+//
+// Builtin decorators
+//
+
+// #*inline decorator block
+func inlineDecorator(options *DecoratorOptions) error {
+	if len(options.params) != 1 {
+		return fmt.Errorf("Inline partial needs one name parameter, got %d", len(options.params))
+	}
+
+	name := options.ParamStr(0)
+	if name == "" {
+		return fmt.Errorf("Invalid inline partial name: %v", options.Param(0))
+	}
+
+	return options.RegisterBlockPartial(name)
+}
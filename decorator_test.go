@@ -0,0 +1,167 @@
+package raymond
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+//
+// Decorators
+//
+
+func localeDecorator(options *DecoratorOptions) error {
+	options.DataFrame().Set("locale", options.ParamStr(0))
+	return nil
+}
+
+func shoutDecorator(options *DecoratorOptions) error {
+	suffix := options.HashStr("suffix")
+
+	options.RegisterHelper("shout", func(str string) string {
+		return strings.ToUpper(str) + suffix
+	})
+
+	return nil
+}
+
+func defineDecorator(options *DecoratorOptions) error {
+	return options.RegisterBlockPartial(options.ParamStr(0))
+}
+
+func failDecorator(options *DecoratorOptions) error {
+	return errors.New("boom")
+}
+
+var testDecorators = map[string]Decorator{
+	"locale": localeDecorator,
+	"shout":  shoutDecorator,
+	"define": defineDecorator,
+	"fail":   failDecorator,
+}
+
+//
+// Tests
+//
+
+var decoratorTests = []struct {
+	name     string
+	input    string
+	data     interface{}
+	partials map[string]string
+	output   string
+}{
+	{
+		"decorator sets private data in its program",
+		`[{{@locale}}] {{#if ok}}{{* locale "fr"}}[{{@locale}}]{{/if}} [{{@locale}}]`,
+		map[string]bool{"ok": true},
+		nil,
+		"[] [fr] []",
+	},
+	{
+		"decorator with evaluated params",
+		`{{* locale lang}}{{#each items}}{{@locale}}:{{.}} {{/each}}`,
+		map[string]interface{}{"lang": "de", "items": []string{"a", "b"}},
+		nil,
+		"de:a de:b ",
+	},
+	{
+		"decorator registers a helper",
+		`{{#each items}}{{* shout suffix="!"}}{{{shout .}}} {{/each}}`,
+		map[string][]string{"items": {"a", "b"}},
+		nil,
+		"A! B! ",
+	},
+	{
+		"decorator helpers are visible to partials",
+		`{{* shout}}{{> item}}`,
+		map[string]string{"name": "foo"},
+		map[string]string{"item": `{{{shout name}}}`},
+		"FOO",
+	},
+	{
+		"decorator block",
+		`{{#* define "title"}}<h1>{{title}}</h1>{{/define}}{{> title}}`,
+		map[string]string{"title": "foo"},
+		nil,
+		"<h1>foo</h1>",
+	},
+	{
+		"decorator statements output nothing",
+		"a\n{{* locale \"fr\"}}\nb",
+		nil,
+		nil,
+		"a\n\nb",
+	},
+}
+
+var decoratorErrors = []struct {
+	name  string
+	input string
+	err   string
+}{
+	{
+		"unknown decorator",
+		`{{* foo}}`,
+		"Evaluation error on line 1, column 1: Unknown decorator: foo",
+	},
+	{
+		"failing decorator",
+		`ok {{#if true}}{{* fail}}{{/if}}`,
+		"Evaluation error on line 1, column 16: Decorator 'fail' failed: boom",
+	},
+	{
+		"decorator statement without block",
+		`{{* define "foo"}}`,
+		"Evaluation error on line 1, column 1: Decorator 'define' failed: Decorator has no block",
+	},
+}
+
+func TestDecorators(t *testing.T) {
+	t.Parallel()
+
+	for _, test := range decoratorTests {
+		tpl := MustParse(test.input)
+		tpl.RegisterDecorators(testDecorators)
+		tpl.RegisterPartials(test.partials)
+
+		output, err := tpl.Exec(test.data)
+		if err != nil {
+			t.Errorf("Test '%s' failed: %s", test.name, err)
+		} else if output != test.output {
+			t.Errorf("Test '%s' failed\ninput:\n\t%q\nexpected\n\t%q\ngot\n\t%q", test.name, test.input, test.output, output)
+		}
+	}
+}
+
+func TestDecoratorErrors(t *testing.T) {
+	t.Parallel()
+
+	for _, test := range decoratorErrors {
+		tpl := MustParse(test.input)
+		tpl.RegisterDecorators(testDecorators)
+
+		_, err := tpl.Exec(nil)
+		if (err == nil) || (err.Error() != test.err) {
+			t.Errorf("Test '%s' failed\ninput:\n\t%q\nexpected\n\t%q\ngot\n\t%v", test.name, test.input, test.err, err)
+		}
+	}
+}
+
+func ExampleRegisterDecorator() {
+	RegisterDecorator("currency", func(options *DecoratorOptions) error {
+		symbol := options.ParamStr(0)
+
+		options.RegisterHelper("price", func(amount float64) string {
+			return fmt.Sprintf("%s%.2f", symbol, amount)
+		})
+
+		return nil
+	})
+
+	tpl := MustParse(`{{#each prices}}{{* currency "$"}}{{{price .}}} {{/each}}`)
+
+	fmt.Print(tpl.MustExec(map[string][]float64{"prices": {12, 3.5}}))
+	// Output: $12.00 $3.50
+}
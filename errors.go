@@ -0,0 +1,152 @@
+package raymond
+
+import (
+	"fmt"
+
+	"github.com/komand/raymond/ast"
+	"github.com/komand/raymond/parser"
+)
+
+// ErrorLocation represents the location of an error in a template.
+type ErrorLocation struct {
+	// Template is the template name, empty if unknown
+	Template string
+
+	// Line is the line number, starting at 1
+	Line int
+
+	// Column is the column number, in runes, starting at 1
+	Column int
+
+	// Offset is the byte offset in template source
+	Offset int
+}
+
+// ParseError is returned when a template can't be parsed.
+type ParseError struct {
+	ErrorLocation
+
+	// Err is the underlying parser error
+	Err error
+}
+
+// ExecError is returned when a template evaluation fails.
+type ExecError struct {
+	ErrorLocation
+
+	// Err is the underlying error
+	Err error
+}
+
+// HelperError is returned when a helper, or a context function, can't be called or fails.
+type HelperError struct {
+	ErrorLocation
+
+	// Helper is the helper name
+	Helper string
+
+	// Err is the underlying error
+	Err error
+}
+
+// PartialNotFoundError is returned when an evaluated partial is not registered.
+type PartialNotFoundError struct {
+	ErrorLocation
+
+	// Partial is the partial name
+	Partial string
+}
+
+// newErrorLocation returns location of given node in given template
+func newErrorLocation(tpl *Template, node ast.Node) ErrorLocation {
+	result := ErrorLocation{Template: tpl.name}
+
+	if node != nil {
+		loc := node.Location()
+
+		result.Line = loc.Line
+		result.Column = loc.Column
+		result.Offset = loc.Pos
+	}
+
+	return result
+}
+
+// newParseError returns a parse error for given template, from given parser error
+func newParseError(tpl *Template, err error) error {
+	perr, ok := err.(*parser.Error)
+	if !ok {
+		return err
+	}
+
+	return &ParseError{
+		ErrorLocation: ErrorLocation{
+			Template: tpl.name,
+			Line:     perr.Line,
+			Column:   perr.Column,
+			Offset:   perr.Pos,
+		},
+		Err: perr,
+	}
+}
+
+// isTemplateError returns true if given error already holds a location in a template
+func isTemplateError(err error) bool {
+	switch err.(type) {
+	case *ParseError, *ExecError, *HelperError, *PartialNotFoundError:
+		return true
+	}
+
+	return false
+}
+
+// String returns the string representation of location.
+func (loc ErrorLocation) String() string {
+	result := fmt.Sprintf("line %d, column %d", loc.Line, loc.Column)
+
+	if loc.Template != "" {
+		result += fmt.Sprintf(" of template '%s'", loc.Template)
+	}
+
+	return result
+}
+
+// Error implements the error interface.
+func (err *ParseError) Error() string {
+	msg := err.Err.Error()
+	if perr, ok := err.Err.(*parser.Error); ok {
+		msg = perr.Message
+	}
+
+	return fmt.Sprintf("Parse error on %s:\n%s", err.ErrorLocation, msg)
+}
+
+// Unwrap returns the underlying parser error.
+func (err *ParseError) Unwrap() error {
+	return err.Err
+}
+
+// Error implements the error interface.
+func (err *ExecError) Error() string {
+	return fmt.Sprintf("Evaluation error on %s: %s", err.ErrorLocation, err.Err)
+}
+
+// Unwrap returns the underlying error.
+func (err *ExecError) Unwrap() error {
+	return err.Err
+}
+
+// Error implements the error interface.
+func (err *HelperError) Error() string {
+	return fmt.Sprintf("Helper '%s' failed on %s: %s", err.Helper, err.ErrorLocation, err.Err)
+}
+
+// Unwrap returns the underlying error.
+func (err *HelperError) Unwrap() error {
+	return err.Err
+}
+
+// Error implements the error interface.
+func (err *PartialNotFoundError) Error() string {
+	return fmt.Sprintf("Partial '%s' not found on %s", err.Partial, err.ErrorLocation)
+}
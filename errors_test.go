@@ -0,0 +1,151 @@
+package raymond
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/komand/raymond/parser"
+)
+
+func TestParseError(t *testing.T) {
+	t.Parallel()
+
+	_, err := Parse("hello\n  {{foo}")
+
+	var perr *ParseError
+	if !errors.As(err, &perr) {
+		t.Fatalf("ParseError expected, got: %#v", err)
+	}
+
+	if (perr.Line != 2) || (perr.Column != 8) || (perr.Offset != 13) || (perr.Template != "") {
+		t.Errorf("Erroneous error location: %#v", perr.ErrorLocation)
+	}
+
+	var cause *parser.Error
+	if !errors.As(err, &cause) || (cause.Line != 2) || (cause.Column != 8) {
+		t.Errorf("Parser error expected as cause, got: %#v", perr.Err)
+	}
+
+	expected := "Parse error on line 2, column 8:\n"
+	if msg := err.Error(); msg[:len(expected)] != expected {
+		t.Errorf("Erroneous error message: %q", msg)
+	}
+}
+
+func TestParseFileError(t *testing.T) {
+	t.Parallel()
+
+	dir, err := ioutil.TempDir("", "raymond")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	filePath := path.Join(dir, "tpl.hbs")
+	if err := ioutil.WriteFile(filePath, []byte("{{#foo}}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = ParseFile(filePath)
+
+	var perr *ParseError
+	if !errors.As(err, &perr) || (perr.Template != filePath) {
+		t.Errorf("ParseError with template name expected, got: %#v", err)
+	}
+}
+
+func TestExecErrors(t *testing.T) {
+	t.Parallel()
+
+	errHelper := errors.New("helper failure")
+
+	tpl := MustParse("{{#if ok}}\n  {{{fail}}} {{{add 1}}}{{/if}}\n{{> missing}}\n{{> broken}}")
+	tpl.RegisterHelper("fail", func() string { panic(errHelper) })
+	tpl.RegisterHelper("add", func(a, b int) string { return "" })
+	tpl.RegisterPartial("broken", "\n{{> missing}}")
+
+	// helper failure
+	_, err := tpl.Exec(map[string]interface{}{"ok": true})
+
+	var herr *HelperError
+	if !errors.As(err, &herr) {
+		t.Fatalf("HelperError expected, got: %#v", err)
+	}
+
+	if (herr.Helper != "fail") || (herr.Line != 2) || (herr.Column != 6) || (herr.Offset != 16) {
+		t.Errorf("Erroneous helper error: %#v", herr)
+	}
+
+	if !errors.Is(err, errHelper) {
+		t.Errorf("Helper error must wrap its cause: %#v", herr.Err)
+	}
+
+	// helper called with wrong number of arguments
+	tpl = MustParse("{{{add 1}}}")
+	tpl.RegisterHelper("add", func(a, b int) string { return "" })
+
+	_, err = tpl.Exec(nil)
+	if !errors.As(err, &herr) || (herr.Helper != "add") || (herr.Column != 4) {
+		t.Errorf("HelperError expected, got: %#v", err)
+	}
+
+	// missing partial
+	_, err = MustParse("\n{{> missing}}").Exec(nil)
+
+	var pnferr *PartialNotFoundError
+	if !errors.As(err, &pnferr) {
+		t.Fatalf("PartialNotFoundError expected, got: %#v", err)
+	}
+
+	if (pnferr.Partial != "missing") || (pnferr.Line != 2) || (pnferr.Column != 1) || (pnferr.Template != "") {
+		t.Errorf("Erroneous partial error: %#v", pnferr)
+	}
+
+	// missing partial in a partial
+	tpl = MustParse("{{> broken}}")
+	tpl.RegisterPartial("broken", "\n  {{> missing}}")
+
+	_, err = tpl.Exec(nil)
+	if !errors.As(err, &pnferr) || (pnferr.Template != "broken") || (pnferr.Line != 2) || (pnferr.Column != 3) {
+		t.Errorf("PartialNotFoundError in partial expected, got: %#v", err)
+	}
+
+	// partial parse error
+	tpl = MustParse("{{> broken}}")
+	tpl.RegisterPartial("broken", "{{#foo}}")
+
+	_, err = tpl.Exec(nil)
+
+	var perr *ParseError
+	if !errors.As(err, &perr) || (perr.Template != "broken") {
+		t.Errorf("ParseError in partial expected, got: %#v", err)
+	}
+
+	// other evaluation error
+	_, err = MustParse("{{> (foo)}}").Exec(nil)
+
+	var eerr *ExecError
+	if !errors.As(err, &eerr) || (eerr.Line != 1) || (eerr.Column != 6) {
+		t.Errorf("ExecError expected, got: %#v", err)
+	}
+}
+
+func ExampleHelperError() {
+	tpl := MustParse("<p>\n  {{{add 1}}}\n</p>")
+	tpl.RegisterHelper("add", func(a, b int) string { return fmt.Sprint(a + b) })
+
+	_, err := tpl.Exec(nil)
+
+	var herr *HelperError
+	if errors.As(err, &herr) {
+		fmt.Println(herr.Helper, herr.Line, herr.Column)
+	}
+
+	fmt.Print(err)
+	// Output: add 2 6
+	// Helper 'add' failed on line 2, column 6: called with wrong number of arguments, needed 2 but got 1
+}
@@ -2,6 +2,7 @@ package raymond
 
 import (
 	"bytes"
+	"encoding/json"
 	"strings"
 )
 
@@ -63,3 +64,17 @@ func Escape(s string) string {
 	escape(&buf, s)
 	return buf.String()
 }
+
+// EscapeJSON escapes given string so that it can be embedded in a JSON string literal.
+//
+// It can be set as a template escaper with SetEscaper(), to render JSON documents.
+func EscapeJSON(s string) string {
+	var buf bytes.Buffer
+
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.Encode(s)
+
+	// remove quotes and trailing newline
+	return string(buf.Bytes()[1 : buf.Len()-2])
+}
@@ -18,3 +18,17 @@ func ExampleEscape() {
 	fmt.Print(result)
 	// Output: <a href='http://www.komand.com/'>This is a &lt;em&gt;cool&lt;/em&gt; website</a>
 }
+
+func ExampleTemplate_SetEscaper() {
+	tpl := MustParse(`{"title": "{{title}}", "body": "{{body}}"}`)
+	tpl.SetEscaper(EscapeJSON)
+
+	ctx := map[string]string{
+		"title": `A "quoted" <title>`,
+		"body":  "line 1\nline 2",
+	}
+
+	result := tpl.MustExec(ctx)
+	fmt.Print(result)
+	// Output: {"title": "A \"quoted\" <title>", "body": "line 1\nline 2"}
+}
@@ -1,11 +1,13 @@
 package raymond
 
 import (
-	"bytes"
 	"fmt"
 	"reflect"
+	"runtime"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/komand/raymond/ast"
 )
@@ -15,6 +17,9 @@ var (
 	errorType       = reflect.TypeOf((*error)(nil)).Elem()
 	fmtStringerType = reflect.TypeOf((*fmt.Stringer)(nil)).Elem()
 
+	strType  = reflect.TypeOf("")
+	boolType = reflect.TypeOf(true)
+
 	zero reflect.Value
 )
 
@@ -22,6 +27,9 @@ var (
 type evalVisitor struct {
 	tpl *Template
 
+	// template being evaluated: tpl or a partial template
+	curTpl *Template
+
 	// contexts stack
 	ctx []reflect.Value
 
@@ -32,33 +40,83 @@ type evalVisitor struct {
 	blockParams []map[string]interface{}
 
 	// block statements stack
-	blocks []*ast.BlockStatement
+	blocks []evalBlock
 
 	// expressions stack
 	exprs []*ast.Expression
 
-	// memoize expressions that were function calls
+	// memoize expressions that were function calls, allocated on first function call
 	exprFunc map[*ast.Expression]bool
 
 	// used for info on panic
 	curNode ast.Node
+
+	// last evaluated path was missing from context
+	missing bool
+
+	// decorators scopes stack, one scope per program holding decorators
+	scopes []*decoratorScope
+
+	// partial blocks stack, the last one being rendered by {{> @partial-block}}
+	partialBlocks []*partial
+
+	// evaluation tracer, nil if disabled
+	tracer Tracer
+
+	// log helper logger, nil for default
+	logger Logger
+
+	// output spans recorder, nil if disabled
+	sourceMap *sourceMapper
+
+	// params of helpers called without options
+	args []interface{}
+
+	// initial storage of stacks and default data frame, so that most evaluations do not allocate them
+	ctxBuf    [4]reflect.Value
+	exprsBuf  [4]*ast.Expression
+	blocksBuf [4]evalBlock
+	argsBuf   [4]interface{}
+	rootFrame DataFrame
+}
+
+// evalVisitors recycles evaluation visitors, as allocating them is most of the cost of evaluating a small template
+var evalVisitors = sync.Pool{
+	New: func() interface{} { return new(evalVisitor) },
 }
 
 // NewEvalVisitor instanciate a new evaluation visitor with given context and initial private data frame
 //
 // If privData is nil, then a default data frame is created
 func newEvalVisitor(tpl *Template, ctx interface{}, privData *DataFrame) *evalVisitor {
-	frame := privData
-	if frame == nil {
-		frame = NewDataFrame()
+	v := evalVisitors.Get().(*evalVisitor)
+
+	v.tpl = tpl
+	v.curTpl = tpl
+	v.dataFrame = privData
+	v.tracer = tpl.tracer
+
+	v.ctx = append(v.ctxBuf[:0], reflect.ValueOf(ctx))
+	v.exprs = v.exprsBuf[:0]
+	v.blocks = v.blocksBuf[:0]
+	v.args = v.argsBuf[:0]
+
+	if v.dataFrame == nil {
+		v.dataFrame = &v.rootFrame
 	}
 
-	return &evalVisitor{
-		tpl:       tpl,
-		ctx:       []reflect.Value{reflect.ValueOf(ctx)},
-		dataFrame: frame,
-		exprFunc:  make(map[*ast.Expression]bool),
+	v.logger = tpl.logger
+	if v.dataFrame.logger != nil {
+		v.logger = v.dataFrame.logger
 	}
+
+	return v
+}
+
+// release resets visitor and puts it back in the pool, it must not be used anymore
+func (v *evalVisitor) release() {
+	*v = evalVisitor{}
+	evalVisitors.Put(v)
 }
 
 // at sets current node
@@ -159,9 +217,15 @@ func (v *evalVisitor) blockParam(name string) interface{} {
 // Blocks stack
 //
 
-// pushBlock pushes new block statement to stack
-func (v *evalVisitor) pushBlock(block *ast.BlockStatement) {
-	v.blocks = append(v.blocks, block)
+// evalBlock is a block statement being evaluated, with its compiled programs if it was compiled
+type evalBlock struct {
+	node     *ast.BlockStatement
+	programs *compiledBlock
+}
+
+// pushBlock pushes new block statement to stack, with its compiled programs if any
+func (v *evalVisitor) pushBlock(block *ast.BlockStatement, programs *compiledBlock) {
+	v.blocks = append(v.blocks, evalBlock{node: block, programs: programs})
 }
 
 // popBlock pops last block statement from stack
@@ -170,10 +234,10 @@ func (v *evalVisitor) popBlock() *ast.BlockStatement {
 		return nil
 	}
 
-	var result *ast.BlockStatement
+	var result evalBlock
 	result, v.blocks = v.blocks[len(v.blocks)-1], v.blocks[:len(v.blocks)-1]
 
-	return result
+	return result.node
 }
 
 // curBlock returns current block statement
@@ -182,7 +246,30 @@ func (v *evalVisitor) curBlock() *ast.BlockStatement {
 		return nil
 	}
 
-	return v.blocks[len(v.blocks)-1]
+	return v.blocks[len(v.blocks)-1].node
+}
+
+// blockProgram returns given program compiled, if this is a program of current block that was compiled with it
+func (v *evalVisitor) blockProgram(program *ast.Program) compiledProgram {
+	if len(v.blocks) == 0 {
+		return nil
+	}
+
+	block := v.blocks[len(v.blocks)-1]
+
+	programs := block.programs
+	if programs == nil {
+		return nil
+	}
+
+	switch program {
+	case block.node.Program:
+		return programs.program
+	case block.node.Inverse:
+		return programs.inverse
+	}
+
+	return nil
 }
 
 //
@@ -219,9 +306,16 @@ func (v *evalVisitor) curExpr() *ast.Expression {
 // Error functions
 //
 
-// errPanic panics
+// errPanic panics with an evaluation error at current node
 func (v *evalVisitor) errPanic(err error) {
-	panic(fmt.Errorf("Evaluation error: %s\nCurrent node:\n\t%s", err, v.curNode))
+	if isTemplateError(err) {
+		panic(err)
+	}
+
+	panic(&ExecError{
+		ErrorLocation: newErrorLocation(v.curTpl, v.curNode),
+		Err:           err,
+	})
 }
 
 // errorf panics with a custom message
@@ -229,17 +323,36 @@ func (v *evalVisitor) errorf(format string, args ...interface{}) {
 	v.errPanic(fmt.Errorf(format, args...))
 }
 
+// helperErrorf panics with an error of helper with given name, at current expression
+func (v *evalVisitor) helperErrorf(name string, format string, args ...interface{}) {
+	v.helperErrPanic(name, fmt.Errorf(format, args...))
+}
+
+// helperErrPanic panics with given error of helper with given name, at current expression
+func (v *evalVisitor) helperErrPanic(name string, err error) {
+	var node ast.Node = v.curNode
+	if expr := v.curExpr(); expr != nil {
+		node = expr
+	}
+
+	panic(&HelperError{
+		ErrorLocation: newErrorLocation(v.curTpl, node),
+		Helper:        name,
+		Err:           err,
+	})
+}
+
 //
 // Evaluation
 //
 
 // evalProgram eEvaluates program with given context and returns string result
 func (v *evalVisitor) evalProgram(program *ast.Program, ctx interface{}, data *DataFrame, key interface{}) string {
-	blockParams := make(map[string]interface{})
+	var blockParams map[string]interface{}
 
 	// compute block params
 	if len(program.BlockParams) > 0 {
-		blockParams[program.BlockParams[0]] = ctx
+		blockParams = map[string]interface{}{program.BlockParams[0]: ctx}
 	}
 
 	if (len(program.BlockParams) > 1) && (key != nil) {
@@ -261,7 +374,7 @@ func (v *evalVisitor) evalProgram(program *ast.Program, ctx interface{}, data *D
 	}
 
 	// evaluate program
-	result, _ := program.Accept(v).(string)
+	result := v.program(program)(v)
 
 	// pop contexts
 	if data != nil {
@@ -282,6 +395,7 @@ func (v *evalVisitor) evalProgram(program *ast.Program, ctx interface{}, data *D
 // evalPath evaluates all path parts with given context
 func (v *evalVisitor) evalPath(ctx reflect.Value, parts []string, exprRoot bool) (reflect.Value, bool) {
 	partResolved := false
+	missing := false
 
 	for i := 0; i < len(parts); i++ {
 		part := parts[i]
@@ -291,7 +405,11 @@ func (v *evalVisitor) evalPath(ctx reflect.Value, parts []string, exprRoot bool)
 			part = part[1 : len(part)-1]
 		}
 
-		ctx = v.evalField(ctx, part, exprRoot)
+		var found bool
+
+		ctx, found = v.lookupField(ctx, part, exprRoot)
+		missing = !found
+
 		if !ctx.IsValid() {
 			break
 		}
@@ -300,79 +418,190 @@ func (v *evalVisitor) evalPath(ctx reflect.Value, parts []string, exprRoot bool)
 		partResolved = true
 	}
 
+	v.missing = missing
+
 	return ctx, partResolved
 }
 
 // evalField evaluates field with given context
 func (v *evalVisitor) evalField(ctx reflect.Value, fieldName string, exprRoot bool) reflect.Value {
+	result, _ := v.lookupField(ctx, fieldName, exprRoot)
+	return result
+}
+
+// lookupField evaluates field with given context, and a boolean to indicate if that field exists in context
+func (v *evalVisitor) lookupField(ctx reflect.Value, fieldName string, exprRoot bool) (reflect.Value, bool) {
 	result := zero
+	found := false
 
 	ctx, _ = indirect(ctx)
 	if !ctx.IsValid() {
-		return result
+		return result, found
+	}
+
+	// fast path for the most common contexts, that have no methods
+	if ctx.Kind() == reflect.Map && ctx.CanInterface() {
+		switch m := ctx.Interface().(type) {
+		case map[string]interface{}:
+			// a nil value is handled by reflection, so that it is still found
+			if val := m[fieldName]; val != nil {
+				return v.fieldValue(ctx, fieldName, reflect.ValueOf(val), exprRoot), true
+			}
+		case map[string]string:
+			if val, ok := m[fieldName]; ok {
+				return reflect.ValueOf(val), true
+			}
+		}
 	}
 
-	// check if this is a method call
-	result, isMeth := v.evalMethod(ctx, fieldName, exprRoot)
-	if !isMeth {
+	// check if context resolves that field itself
+	result, found = resolve(ctx, fieldName)
+
+	if !found {
+		// check if this is a method call
+		result, found = v.evalMethod(ctx, fieldName, exprRoot)
+	}
+
+	if !found {
 		switch ctx.Kind() {
 		case reflect.Struct:
-			// example: firstName => FirstName
-			expFieldName := strings.Title(fieldName)
-
-			// check if struct have this field and that it is exported
-			if tField, ok := ctx.Type().FieldByName(expFieldName); ok && (tField.PkgPath == "") {
-				// struct field
-				result = ctx.FieldByIndex(tField.Index)
-				break
+			// struct field, by name (example: firstName => FirstName) or by struct tag
+			if index := v.fieldIndex(ctx.Type(), fieldName); index != nil {
+				if field, err := ctx.FieldByIndexErr(index); err == nil {
+					result = field
+					found = true
+				}
 			}
-
-			// attempts to find template variable name as a struct tag
-			result = v.evalStructTag(ctx, fieldName)
 		case reflect.Map:
 			nameVal := reflect.ValueOf(fieldName)
 			if nameVal.Type().AssignableTo(ctx.Type().Key()) {
 				// map key
 				result = ctx.MapIndex(nameVal)
+				found = result.IsValid()
 			}
 		case reflect.Array, reflect.Slice:
 			if i, err := strconv.Atoi(fieldName); (err == nil) && (i < ctx.Len()) {
 				result = ctx.Index(i)
+				found = true
 			}
 		}
 	}
 
-	// check if result is a function
-	result, _ = indirect(result)
+	return v.fieldValue(ctx, fieldName, result, exprRoot), found
+}
+
+// fieldValue returns the value of given field of given context, evaluating it if this is a function
+func (v *evalVisitor) fieldValue(ctx reflect.Value, fieldName string, result reflect.Value, exprRoot bool) reflect.Value {
+	// pointers to resolvers are kept so that they still resolve in nested contexts
+	if ptr, ok := resolverPtr(result); ok {
+		result = ptr
+	} else {
+		result, _ = indirect(result)
+	}
+
 	if result.Kind() == reflect.Func {
+		v.checkFieldCall(ctx, fieldName)
+
 		result = v.evalFieldFunc(fieldName, result, exprRoot)
 	}
 
 	return result
 }
 
+// fieldIndex returns the index of the struct field referenced by given template name, or nil if not found
+func (v *evalVisitor) fieldIndex(typ reflect.Type, name string) []int {
+	if v.tpl.fieldNames != nil {
+		return typeInfoFor(typ).resolvedFieldIndex(v.tpl.fieldNames, name)
+	}
+
+	return typeInfoFor(typ).fieldIndex(name)
+}
+
 // evalFieldFunc tries to evaluate given method name, and a boolean to indicate if this was a method call
 func (v *evalVisitor) evalMethod(ctx reflect.Value, name string, exprRoot bool) (reflect.Value, bool) {
 	if ctx.Kind() != reflect.Interface && ctx.CanAddr() {
 		ctx = ctx.Addr()
 	}
 
-	method := ctx.MethodByName(name)
-	if !method.IsValid() {
-		// example: subject() => Subject()
-		method = ctx.MethodByName(strings.Title(name))
+	if ctx.NumMethod() == 0 {
+		return zero, false
 	}
 
-	if !method.IsValid() {
+	// example: subject() => Subject()
+	index := typeInfoFor(ctx.Type()).methodIndex(name)
+	if index < 0 {
 		return zero, false
 	}
 
+	v.checkCall(ctx, ctx.Type().Method(index).Name)
+
+	method := ctx.Method(index)
+
 	return v.evalFieldFunc(name, method, exprRoot), true
 }
 
+// checkCall panics if the sandbox does not allow calling the method or function with given name of given context
+func (v *evalVisitor) checkCall(ctx reflect.Value, name string) {
+	if v.tpl.sandbox == nil {
+		return
+	}
+
+	typ := ctx.Type()
+	if ctx.Kind() == reflect.Interface {
+		typ = ctx.Elem().Type()
+	}
+
+	if !v.tpl.sandbox.allows(typ, name) {
+		v.errPanic(&CallNotAllowedError{Type: typ, Name: name})
+	}
+}
+
+// checkFieldCall panics if the sandbox does not allow calling the function stored in given field of given context
+func (v *evalVisitor) checkFieldCall(ctx reflect.Value, fieldName string) {
+	if v.tpl.sandbox == nil {
+		return
+	}
+
+	// struct fields are allowed by their Go name
+	name := fieldName
+	if ctx.Kind() == reflect.Struct {
+		if index := v.fieldIndex(ctx.Type(), fieldName); index != nil {
+			name = ctx.Type().FieldByIndex(index).Name
+		}
+	}
+
+	v.checkCall(ctx, name)
+}
+
+// checkStr panics if the sandbox does not allow a method called to convert given value to a string
+func (v *evalVisitor) checkStr(value interface{}) {
+	if v.tpl.sandbox == nil {
+		return
+	}
+
+	if err := v.tpl.sandbox.strCall(value); err != nil {
+		v.errPanic(err)
+	}
+}
+
+// str returns the string representation of given value, checked against the sandbox
+func (v *evalVisitor) str(value interface{}) string {
+	v.checkStr(value)
+
+	return Str(value)
+}
+
 // evalFieldFunc evaluates given function
 func (v *evalVisitor) evalFieldFunc(name string, funcVal reflect.Value, exprRoot bool) reflect.Value {
-	ensureValidHelper(name, funcVal)
+	if exprRoot && v.tpl.mustache {
+		if result, ok := v.evalLambda(name, funcVal); ok {
+			return result
+		}
+	}
+
+	if err := validateHelper(funcVal); err != nil {
+		v.helperErrPanic(name, err)
+	}
 
 	var options *Options
 	if exprRoot {
@@ -381,7 +610,7 @@ func (v *evalVisitor) evalFieldFunc(name string, funcVal reflect.Value, exprRoot
 		options = v.helperOptions(expr)
 
 		// ok, that expression was a function call
-		v.exprFunc[expr] = true
+		v.setFuncCall(expr)
 	} else {
 		// we are not at root of expression, so we are a parameter... and we don't like
 		// infinite loops caused by trying to parse ourself forever
@@ -391,23 +620,6 @@ func (v *evalVisitor) evalFieldFunc(name string, funcVal reflect.Value, exprRoot
 	return v.callFunc(name, funcVal, options)
 }
 
-// evalStructTag checks for the existence of a struct tag containing the
-// name of the variable in the template. This allows for a template variable to
-// be separated from the field in the struct.
-func (v *evalVisitor) evalStructTag(ctx reflect.Value, name string) reflect.Value {
-	val := reflect.ValueOf(ctx.Interface())
-
-	for i := 0; i < val.NumField(); i++ {
-		field := val.Type().Field(i)
-		tag := field.Tag.Get("handlebars")
-		if tag == name {
-			return val.Field(i)
-		}
-	}
-
-	return zero
-}
-
 // findBlockParam returns node's block parameter
 func (v *evalVisitor) findBlockParam(node *ast.PathExpression) (string, interface{}) {
 	if len(node.Parts) > 0 {
@@ -424,6 +636,8 @@ func (v *evalVisitor) findBlockParam(node *ast.PathExpression) (string, interfac
 func (v *evalVisitor) evalPathExpression(node *ast.PathExpression, exprRoot bool) interface{} {
 	var result interface{}
 
+	v.missing = false
+
 	if name, value := v.findBlockParam(node); value != nil {
 		// block parameter value
 
@@ -465,6 +679,15 @@ func (v *evalVisitor) evalPathExpression(node *ast.PathExpression, exprRoot bool
 		}
 	}
 
+	if (result == nil) && v.missing && v.tpl.strict {
+		v.at(node)
+		v.errorf("Missing field '%s'", node.Original)
+	}
+
+	if v.tracer != nil {
+		v.tracer.ResolvePath(node.Original, result != nil, reflect.TypeOf(result))
+	}
+
 	return result
 }
 
@@ -474,15 +697,34 @@ func (v *evalVisitor) evalDataPathExpression(node *ast.PathExpression, exprRoot
 	frame := v.dataFrame
 	for i := node.Depth; i > 0; i-- {
 		if frame.parent == nil {
+			v.missing = true
 			return nil
 		}
 		frame = frame.parent
 	}
 
 	// resolve data
-	// @note Can be changed to v.evalCtx() as context can't be an array
-	result, _ := v.evalCtxPath(reflect.ValueOf(frame.data), node.Parts, exprRoot)
-	return result
+	data, found := frame.get(node.Parts[0])
+	if !found {
+		if (len(node.Parts) == 1) && (node.Parts[0] == "level") {
+			// default log level
+			return LogLevelInfo
+		}
+
+		v.missing = true
+		return nil
+	}
+
+	value := v.fieldValue(reflect.ValueOf(frame.data), node.Parts[0], reflect.ValueOf(data), exprRoot)
+	if len(node.Parts) > 1 {
+		value, _ = v.evalPath(value, node.Parts[1:], exprRoot)
+	}
+
+	if !value.IsValid() {
+		return nil
+	}
+
+	return value.Interface()
 }
 
 // evalCtxPathExpression evaluates a context path expression
@@ -506,6 +748,10 @@ func (v *evalVisitor) evalDepthPath(depth int, parts []string, exprRoot bool) in
 	partResolved := false
 
 	ctx := v.ancestorCtx(depth)
+	if !ctx.IsValid() {
+		// no context to resolve path with
+		v.missing = true
+	}
 
 	for (result == nil) && ctx.IsValid() && (depth <= len(v.ctx) && !partResolved) {
 		// try with context
@@ -568,6 +814,10 @@ func (v *evalVisitor) isHelperCall(node *ast.Expression) bool {
 
 // findHelper finds given helper
 func (v *evalVisitor) findHelper(name string) reflect.Value {
+	if h := v.findDecoratorHelper(name); h != zero {
+		return h
+	}
+
 	// check template helpers
 	if h := v.tpl.findHelper(name); h != zero {
 		return h
@@ -577,15 +827,26 @@ func (v *evalVisitor) findHelper(name string) reflect.Value {
 	return findHelper(name)
 }
 
+// findDecoratorHelper finds given helper in helpers registered by decorators, from innermost scope
+func (v *evalVisitor) findDecoratorHelper(name string) reflect.Value {
+	for i := len(v.scopes) - 1; i >= 0; i-- {
+		if h := v.scopes[i].helpers[name]; h != zero {
+			return h
+		}
+	}
+
+	return zero
+}
+
 // callFunc calls function with given options
 func (v *evalVisitor) callFunc(name string, funcVal reflect.Value, options *Options) reflect.Value {
 	params := options.Params()
 
 	funcType := funcVal.Type()
 
-	// @todo Is there a better way to do that ?
-	strType := reflect.TypeOf("")
-	boolType := reflect.TypeOf(true)
+	if funcType.IsVariadic() {
+		return v.callVariadic(name, funcVal, options)
+	}
 
 	// check parameters number
 	addOptions := false
@@ -599,37 +860,15 @@ func (v *evalVisitor) callFunc(name string, funcVal reflect.Value, options *Opti
 	}
 
 	if !addOptions && (len(params) != numIn) {
-		v.errorf("Helper '%s' called with wrong number of arguments, needed %d but got %d", name, numIn, len(params))
+		v.helperErrorf(name, "called with wrong number of arguments, needed %d but got %d", numIn, len(params))
 	}
 
 	// check and collect arguments
 	args := make([]reflect.Value, numIn)
 	for i, param := range params {
-		arg := reflect.ValueOf(param)
-		argType := funcType.In(i)
-
-		if !arg.IsValid() {
-			if canBeNil(argType) {
-				arg = reflect.Zero(argType)
-			} else if argType.Kind() == reflect.String {
-				arg = reflect.ValueOf("")
-			} else {
-				// @todo Maybe we can panic on that
-				return reflect.Zero(strType)
-			}
-		}
-
-		if !arg.Type().AssignableTo(argType) {
-			if strType.AssignableTo(argType) {
-				// convert parameter to string
-				arg = reflect.ValueOf(strValue(arg))
-			} else if boolType.AssignableTo(argType) {
-				// convert parameter to bool
-				val, _ := isTrueValue(arg)
-				arg = reflect.ValueOf(val)
-			} else {
-				v.errorf("Helper %s called with argument %d with type %s but it should be %s", name, i, arg.Type(), argType)
-			}
+		arg, ok := v.funcArg(name, i, param, funcType.In(i))
+		if !ok {
+			return reflect.Zero(strType)
 		}
 
 		args[i] = arg
@@ -639,9 +878,127 @@ func (v *evalVisitor) callFunc(name string, funcVal reflect.Value, options *Opti
 		args[numIn-1] = reflect.ValueOf(options)
 	}
 
-	result := funcVal.Call(args)
+	return v.callTraced(name, funcVal, args, options)
+}
+
+// callVariadic calls variadic function with given options. Options are passed as the last variadic argument if
+// the variadic arguments type allows it, eg. func(args ...interface{}).
+func (v *evalVisitor) callVariadic(name string, funcVal reflect.Value, options *Options) reflect.Value {
+	params := options.Params()
+
+	funcType := funcVal.Type()
+
+	// check parameters number
+	numFixed := funcType.NumIn() - 1
+	if len(params) < numFixed {
+		v.helperErrorf(name, "called with wrong number of arguments, needed at least %d but got %d", numFixed, len(params))
+	}
+
+	variadicType := funcType.In(numFixed).Elem()
+
+	// check and collect arguments
+	args := make([]reflect.Value, len(params), len(params)+1)
+	for i, param := range params {
+		argType := variadicType
+		if i < numFixed {
+			argType = funcType.In(i)
+		}
+
+		arg, ok := v.funcArg(name, i, param, argType)
+		if !ok {
+			return reflect.Zero(strType)
+		}
+
+		args[i] = arg
+	}
+
+	if reflect.TypeOf(options).AssignableTo(variadicType) {
+		args = append(args, reflect.ValueOf(options))
+	}
+
+	return v.callTraced(name, funcVal, args, options)
+}
+
+// funcArg converts given param to argument of given type, for function with given name. It returns false if the
+// param is nil and can't be converted.
+func (v *evalVisitor) funcArg(name string, i int, param interface{}, argType reflect.Type) (reflect.Value, bool) {
+	arg := reflect.ValueOf(param)
+
+	if !arg.IsValid() {
+		if canBeNil(argType) {
+			arg = reflect.Zero(argType)
+		} else if argType.Kind() == reflect.String {
+			arg = reflect.ValueOf("")
+		} else {
+			// @todo Maybe we can panic on that
+			return zero, false
+		}
+	}
+
+	if !arg.Type().AssignableTo(argType) {
+		if strType.AssignableTo(argType) {
+			// convert parameter to string
+			v.checkStr(param)
+			arg = reflect.ValueOf(strValue(arg))
+		} else if boolType.AssignableTo(argType) {
+			// convert parameter to bool
+			val, _ := isTrueValue(arg)
+			arg = reflect.ValueOf(val)
+		} else {
+			v.helperErrorf(name, "called with argument %d with type %s but it should be %s", i, arg.Type(), argType)
+		}
+	}
+
+	return arg, true
+}
+
+// callTraced calls function with given arguments, and notifies tracer
+func (v *evalVisitor) callTraced(name string, funcVal reflect.Value, args []reflect.Value, options *Options) reflect.Value {
+	if v.tracer == nil {
+		return v.call(name, funcVal, args)[0]
+	}
+
+	start := time.Now()
+	result := v.call(name, funcVal, args)[0]
+
+	v.traceHelper(name, options.params, options, result.Interface(), start)
+
+	return result
+}
+
+// call calls function with given arguments, a panic with an error is converted to an helper error
+func (v *evalVisitor) call(name string, funcVal reflect.Value, args []reflect.Value) []reflect.Value {
+	defer v.recoverHelper(name)
+
+	return funcVal.Call(args)
+}
 
-	return result[0]
+// callDirect calls helper with given caller, params and options, a panic with an error is converted to an helper error
+func (v *evalVisitor) callDirect(name string, caller helperCaller, params []interface{}, options *Options) interface{} {
+	defer v.recoverHelper(name)
+
+	if v.tracer == nil {
+		return caller(v, params, options)
+	}
+
+	start := time.Now()
+	result := caller(v, params, options)
+
+	v.traceHelper(name, params, options, result, start)
+
+	return result
+}
+
+// recoverHelper converts a panic with an error in helper with given name to an helper error, it must be deferred
+func (v *evalVisitor) recoverHelper(name string) {
+	if e := recover(); e != nil {
+		err, ok := e.(error)
+		if _, isRuntime := e.(runtime.Error); !ok || isRuntime || isTemplateError(err) {
+			panic(e)
+		}
+
+		v.helperErrPanic(name, err)
+	}
 }
 
 // callHelper invoqs helper function for given expression node
@@ -672,12 +1029,111 @@ func (v *evalVisitor) helperOptions(node *ast.Expression) *Options {
 	return newOptions(v, params, hash)
 }
 
+//
+// Decorators
+//
+
+// findDecorator finds given decorator
+func (v *evalVisitor) findDecorator(name string) Decorator {
+	// check template decorators
+	if d := v.tpl.findDecorator(name); d != nil {
+		return d
+	}
+
+	// check global decorators
+	return findDecorator(name)
+}
+
+// isDecorated returns true if decorators of given program were already applied
+func (v *evalVisitor) isDecorated(program *ast.Program) bool {
+	for _, scope := range v.scopes {
+		if scope.program == program {
+			return true
+		}
+	}
+
+	return false
+}
+
+// pushDecorators applies decorators of given program, and returns false if there is none. If true is
+// returned, popDecorators() must be called once program is evaluated.
+func (v *evalVisitor) pushDecorators(program *ast.Program) bool {
+	if v.isDecorated(program) {
+		return false
+	}
+
+	var scope *decoratorScope
+
+	for _, n := range program.Body {
+		var expr *ast.Expression
+		var block *ast.Program
+
+		switch node := n.(type) {
+		case *ast.Decorator:
+			expr = node.Expression
+		case *ast.DecoratorBlock:
+			expr, block = node.Expression, node.Program
+		default:
+			continue
+		}
+
+		v.at(n)
+
+		name := expr.HelperName()
+
+		decorator := v.findDecorator(name)
+		if decorator == nil {
+			v.errorf("Unknown decorator: %s", name)
+		}
+
+		if scope == nil {
+			// decorated program gets its own data frame
+			scope = newDecoratorScope(program, v.dataFrame)
+			v.scopes = append(v.scopes, scope)
+			v.dataFrame = v.dataFrame.Copy()
+		}
+
+		options := v.helperOptions(expr)
+
+		err := decorator(&DecoratorOptions{
+			eval:    v,
+			name:    name,
+			program: program,
+			block:   block,
+			params:  options.params,
+			hash:    options.hash,
+			scope:   scope,
+		})
+		if err != nil {
+			v.at(n)
+			v.errPanic(fmt.Errorf("Decorator '%s' failed: %w", name, err))
+		}
+	}
+
+	return scope != nil
+}
+
+// popDecorators drops the helpers, partials and data frame set by the decorators of last decorated program
+func (v *evalVisitor) popDecorators() {
+	scope := v.scopes[len(v.scopes)-1]
+
+	v.scopes = v.scopes[:len(v.scopes)-1]
+	v.dataFrame = scope.prevFrame
+}
+
 //
 // Partials
 //
 
 // findPartial finds given partial
 func (v *evalVisitor) findPartial(name string) *partial {
+	// check partials registered by decorators, from innermost scope
+	for i := len(v.scopes) - 1; i >= 0; i-- {
+		if p := v.scopes[i].partials[name]; p != nil {
+			return p
+		}
+	}
+
 	// check template partials
 	if p := v.tpl.findPartial(name); p != nil {
 		return p
@@ -687,47 +1143,84 @@ func (v *evalVisitor) findPartial(name string) *partial {
 	return findPartial(name)
 }
 
-// partialContext computes partial context
-func (v *evalVisitor) partialContext(node *ast.PartialStatement) reflect.Value {
-	if nb := len(node.Params); nb > 1 {
+// programPartial returns a partial with given name, that evaluates given program of current template
+func (v *evalVisitor) programPartial(name string, program *ast.Program) *partial {
+	// partial template shares the source of the template declaring it
+	tpl := newTemplate(v.curTpl.source, v.curTpl.unescaped)
+	tpl.name = v.curTpl.name
+	tpl.program = program
+	tpl.compiled = v.curTpl.compiled
+
+	return newPartial(name, "", tpl)
+}
+
+// partialName evaluates given partial name node
+func (v *evalVisitor) partialName(node ast.Node) string {
+	// partialName: helperName | sexpr
+	name, ok := ast.HelperNameStr(node)
+	if !ok {
+		if subExpr, ok := node.(*ast.SubExpression); ok {
+			name, _ = subExpr.Accept(v).(string)
+		}
+	}
+
+	if name == "" {
+		v.errorf("Unexpected partial name: %q", node)
+	}
+
+	return name
+}
+
+// partialContext computes partial context from given params and hash
+func (v *evalVisitor) partialContext(params []ast.Node, hash *ast.Hash) reflect.Value {
+	if nb := len(params); nb > 1 {
 		v.errorf("Unsupported number of partial arguments: %d", nb)
 	}
 
-	if (len(node.Params) > 0) && (node.Hash != nil) {
+	if (len(params) > 0) && (hash != nil) {
 		v.errorf("Passing both context and named parameters to a partial is not allowed")
 	}
 
-	if len(node.Params) == 1 {
-		return reflect.ValueOf(node.Params[0].Accept(v))
+	if len(params) == 1 {
+		return reflect.ValueOf(params[0].Accept(v))
 	}
 
-	if node.Hash != nil {
-		hash, _ := node.Hash.Accept(v).(map[string]interface{})
-		return reflect.ValueOf(hash)
+	if hash != nil {
+		h, _ := hash.Accept(v).(map[string]interface{})
+		return reflect.ValueOf(h)
 	}
 
 	return zero
 }
 
-// evalPartial evaluates a partial
-func (v *evalVisitor) evalPartial(p *partial, node *ast.PartialStatement) string {
+// evalPartial evaluates a partial with given params and hash, and indents its lines with given indentation
+func (v *evalVisitor) evalPartial(p *partial, params []ast.Node, hash *ast.Hash, indent string) string {
 	// get partial template
 	partialTpl, err := p.template()
 	if err != nil {
 		v.errPanic(err)
 	}
 
+	if v.tracer != nil {
+		v.tracer.EnterPartial(p.name)
+	}
+
 	// push partial context
-	ctx := v.partialContext(node)
+	ctx := v.partialContext(params, hash)
 	if ctx.IsValid() {
 		v.pushCtx(ctx)
 	}
 
 	// evaluate partial template
-	result, _ := partialTpl.program.Accept(v).(string)
+	tpl := v.curTpl
+	v.curTpl = partialTpl
+
+	result := v.program(partialTpl.program)(v)
+
+	v.curTpl = tpl
 
 	// ident partial
-	result = indentLines(result, node.Indent)
+	result = indentLines(result, indent)
 
 	if ctx.IsValid() {
 		v.popCtx()
@@ -761,12 +1254,106 @@ func indentLines(str string, indent string) string {
 // Functions
 //
 
+// setFuncCall tags given expression as a function call
+func (v *evalVisitor) setFuncCall(node *ast.Expression) {
+	if v.exprFunc == nil {
+		v.exprFunc = make(map[*ast.Expression]bool)
+	}
+
+	v.exprFunc[node] = true
+}
+
 // wasFuncCall returns true if given expression was a function call
 func (v *evalVisitor) wasFuncCall(node *ast.Expression) bool {
 	// check if expression was tagged as a function call
 	return v.exprFunc[node]
 }
 
+//
+// Mustache lambdas
+//
+
+// evalLambda evaluates given function as a mustache lambda, and returns false if that function is not a lambda
+func (v *evalVisitor) evalLambda(name string, funcVal reflect.Value) (reflect.Value, bool) {
+	expr := v.curExpr()
+	funcType := funcVal.Type()
+
+	if (funcType.NumOut() != 1) || (funcType.Out(0).Kind() != reflect.String) {
+		return zero, false
+	}
+
+	if block := v.curBlock(); (block != nil) && (block.Expression == expr) {
+		// section lambda: func(text string) string
+		if (funcType.NumIn() != 1) || (funcType.In(0).Kind() != reflect.String) {
+			return zero, false
+		}
+
+		// the lambda outputs the block
+		v.setFuncCall(expr)
+
+		if block.Program == nil {
+			// inverted section: a lambda is truthy
+			return reflect.ValueOf(""), true
+		}
+
+		source := v.curTpl.source
+		text := source[block.Program.Loc.Pos:block.Program.Loc.End]
+		open, close := sectionDelimiters(source, block)
+
+		result := v.call(name, funcVal, []reflect.Value{reflect.ValueOf(text).Convert(funcType.In(0))})
+
+		return reflect.ValueOf(v.evalLambdaTemplate(result[0].String(), open, close)), true
+	}
+
+	// interpolation lambda: func() string
+	if funcType.NumIn() != 0 {
+		return zero, false
+	}
+
+	result := v.call(name, funcVal, nil)
+
+	return reflect.ValueOf(v.evalLambdaTemplate(result[0].String(), defaultOpenDelim, defaultCloseDelim)), true
+}
+
+// evalLambdaTemplate renders given template returned by a lambda, with given delimiters, against current context
+func (v *evalVisitor) evalLambdaTemplate(source string, open string, close string) string {
+	tpl := newTemplate(source, v.curTpl.unescaped)
+	tpl.name = v.curTpl.name
+
+	if (open != defaultOpenDelim) || (close != defaultCloseDelim) {
+		tpl.openDelim = open
+		tpl.closeDelim = close
+	}
+
+	if err := tpl.parse(); err != nil {
+		v.errPanic(err)
+	}
+
+	prevTpl := v.curTpl
+	v.curTpl = tpl
+
+	result := v.program(tpl.program)(v)
+
+	v.curTpl = prevTpl
+
+	return result
+}
+
+// sectionDelimiters returns the delimiters of given block tags in given source, eg. "<%" and "%>" for <%#foo%>
+func sectionDelimiters(source string, block *ast.BlockStatement) (string, string) {
+	open := strings.TrimSpace(source[block.Loc.Pos:block.Expression.Loc.Pos])
+	open = strings.TrimSuffix(strings.TrimSuffix(open, "#"), "~")
+
+	close := strings.TrimSpace(source[block.Expression.Loc.End:block.Program.Loc.Pos])
+	close = strings.TrimPrefix(close, "~")
+
+	if (open == "") || (close == "") {
+		return defaultOpenDelim, defaultCloseDelim
+	}
+
+	return open, close
+}
+
 //
 // Visitor interface
 //
@@ -775,19 +1362,28 @@ func (v *evalVisitor) wasFuncCall(node *ast.Expression) bool {
 
 // VisitProgram implements corresponding Visitor interface method
 func (v *evalVisitor) VisitProgram(node *ast.Program) interface{} {
-	v.at(node)
+	return v.program(node)(v)
+}
 
-	buf := new(bytes.Buffer)
+// program returns given program compiled
+//
+// Programs of current block were compiled with it, other programs are compiled on first evaluation.
+func (v *evalVisitor) program(node *ast.Program) compiledProgram {
+	if compiled := v.blockProgram(node); compiled != nil {
+		return compiled
+	}
 
-	for _, n := range node.Body {
-		if str := Str(n.Accept(v)); str != "" {
-			if _, err := buf.Write([]byte(str)); err != nil {
-				v.errPanic(err)
-			}
-		}
+	return v.curTpl.compiled.program(node, v.tpl)
+}
+
+// escape escapes given mustache output with template escaper
+func (v *evalVisitor) escape(str string) string {
+	if v.tpl.escaper != nil {
+		return v.tpl.escaper(str)
 	}
 
-	return buf.String()
+	// escape html
+	return Escape(str)
 }
 
 // VisitMustache implements corresponding Visitor interface method
@@ -795,16 +1391,18 @@ func (v *evalVisitor) VisitMustache(node *ast.MustacheStatement) interface{} {
 	v.at(node)
 
 	// evaluate expression
-	expr := node.Expression.Accept(v)
+	return v.mustacheOutput(node, node.Expression.Accept(v))
+}
 
+// mustacheOutput returns the output of given mustache statement, with given expression result
+func (v *evalVisitor) mustacheOutput(node *ast.MustacheStatement, expr interface{}) string {
 	// check if this is a safe string
 	isSafe := isSafeString(expr)
 
 	// get string value
-	str := Str(expr)
+	str := v.str(expr)
 	if !isSafe && !node.Unescaped {
-		// escape html
-		str = Escape(str)
+		str = v.escape(str)
 	}
 
 	return str
@@ -814,14 +1412,24 @@ func (v *evalVisitor) VisitMustache(node *ast.MustacheStatement) interface{} {
 func (v *evalVisitor) VisitBlock(node *ast.BlockStatement) interface{} {
 	v.at(node)
 
-	v.pushBlock(node)
-
-	var result interface{}
+	v.pushBlock(node, nil)
 
 	// evaluate expression
 	expr := node.Expression.Accept(v)
 
-	if v.isHelperCall(node.Expression) || v.wasFuncCall(node.Expression) {
+	result := v.blockOutput(node, expr, v.isHelperCall(node.Expression))
+
+	v.popBlock()
+
+	return result
+}
+
+// blockOutput returns the output of given block statement, with given expression result and a boolean set to
+// true if that expression was a helper call
+func (v *evalVisitor) blockOutput(node *ast.BlockStatement, expr interface{}, helperCall bool) interface{} {
+	var result interface{}
+
+	if helperCall || v.wasFuncCall(node.Expression) {
 		// it is the responsability of the helper/function to evaluate block
 		result = expr
 	} else {
@@ -850,37 +1458,81 @@ func (v *evalVisitor) VisitBlock(node *ast.BlockStatement) interface{} {
 				}
 			}
 		} else if node.Inverse != nil {
-			result, _ = node.Inverse.Accept(v).(string)
+			result = v.program(node.Inverse)(v)
 		}
 	}
 
-	v.popBlock()
-
 	return result
 }
 
+// VisitDecorator implements corresponding Visitor interface method
+func (v *evalVisitor) VisitDecorator(node *ast.Decorator) interface{} {
+	v.at(node)
+
+	// already applied by parent program
+	return ""
+}
+
+// VisitDecoratorBlock implements corresponding Visitor interface method
+func (v *evalVisitor) VisitDecoratorBlock(node *ast.DecoratorBlock) interface{} {
+	v.at(node)
+
+	// already applied by parent program
+	return ""
+}
+
 // VisitPartial implements corresponding Visitor interface method
 func (v *evalVisitor) VisitPartial(node *ast.PartialStatement) interface{} {
 	v.at(node)
 
-	// partialName: helperName | sexpr
-	name, ok := ast.HelperNameStr(node.Name)
-	if !ok {
-		if subExpr, ok := node.Name.(*ast.SubExpression); ok {
-			name, _ = subExpr.Accept(v).(string)
+	name := v.partialName(node.Name)
+
+	var partial *partial
+
+	if name == partialBlockName {
+		if nb := len(v.partialBlocks); nb > 0 {
+			partial = v.partialBlocks[nb-1]
+
+			// partial block is evaluated with the partial blocks stack of the template declaring it
+			v.partialBlocks = v.partialBlocks[:nb-1]
+			defer func() { v.partialBlocks = append(v.partialBlocks, partial) }()
 		}
+	} else {
+		partial = v.findPartial(name)
 	}
 
-	if name == "" {
-		v.errorf("Unexpected partial name: %q", node.Name)
+	if partial == nil {
+		panic(&PartialNotFoundError{
+			ErrorLocation: newErrorLocation(v.curTpl, node),
+			Partial:       name,
+		})
 	}
 
-	partial := v.findPartial(name)
+	return v.evalPartial(partial, node.Params, node.Hash, node.Indent)
+}
+
+// VisitPartialBlock implements corresponding Visitor interface method
+func (v *evalVisitor) VisitPartialBlock(node *ast.PartialBlock) interface{} {
+	v.at(node)
+
+	expr := node.Expression
+	block := v.programPartial(partialBlockName, node.Program)
+
+	partial := v.findPartial(v.partialName(expr.Path))
 	if partial == nil {
-		v.errorf("Partial not found: %s", name)
+		// block is rendered in place of missing partial
+		return v.evalPartial(block, expr.Params, expr.Hash, "")
 	}
 
-	return v.evalPartial(partial, node)
+	// decorators of block are applied to partial, so inline partials declared in block are available to it
+	if v.pushDecorators(node.Program) {
+		defer v.popDecorators()
+	}
+
+	v.partialBlocks = append(v.partialBlocks, block)
+	defer func() { v.partialBlocks = v.partialBlocks[:len(v.partialBlocks)-1] }()
+
+	return v.evalPartial(partial, expr.Params, expr.Hash, "")
 }
 
 // VisitContent implements corresponding Visitor interface method
@@ -72,6 +72,118 @@ var evalTests = []Test{
 		nil, nil, nil,
 		"C",
 	},
+	{
+		"whitespaces ending a path",
+		"{{ foo }} {{foo bar }}{{ step 1.name }}",
+		map[string]interface{}{"foo": "baz", "foo ": "bad", "foo bar": "bat", "step 1": map[string]string{"name": "!"}},
+		nil, nil, nil,
+		"baz bat!",
+	},
+	{
+		"whitespaces ending a helper name",
+		"{{#if foo }}{{echo 'bar' }}{{/if}}",
+		map[string]interface{}{"foo": true},
+		nil,
+		map[string]interface{}{"echo": func(str string) string { return str }},
+		nil,
+		"bar",
+	},
+	{
+		"inline partial",
+		`{{#*inline "row"}}<td>{{.}}</td>{{/inline}}{{#each cells}}{{> row}}{{/each}}`,
+		map[string][]string{"cells": {"a", "b"}},
+		nil, nil, nil,
+		"<td>a</td><td>b</td>",
+	},
+	{
+		"inline partial takes precedence over template partials",
+		`{{#*inline "name"}}inline {{name}}{{/inline}}{{> name}}`,
+		map[string]string{"name": "foo"},
+		nil, nil,
+		map[string]string{"name": "registered {{name}}"},
+		"inline foo",
+	},
+	{
+		"inline partial is scoped to its block",
+		`{{#each items}}{{#*inline "item"}}[{{.}}]{{/inline}}{{> item}}{{/each}} {{> item}}`,
+		map[string][]string{"items": {"a", "b"}},
+		nil, nil,
+		map[string]string{"item": "registered"},
+		"[a][b] registered",
+	},
+	{
+		"inline partial in nested block",
+		`{{#*inline "item"}}outer{{/inline}}{{#if ok}}{{#*inline "item"}}inner{{/inline}}{{> item}}{{/if}} {{> item}}`,
+		map[string]bool{"ok": true},
+		nil, nil, nil,
+		"inner outer",
+	},
+	{
+		"inline partial is visible to nested partials",
+		`{{#*inline "cell"}}<td>{{.}}</td>{{/inline}}{{> row}}`,
+		map[string][]string{"cells": {"a", "b"}},
+		nil, nil,
+		map[string]string{"row": "<tr>{{#each cells}}{{> cell}}{{/each}}</tr>"},
+		"<tr><td>a</td><td>b</td></tr>",
+	},
+	{
+		"standalone inline partial",
+		"{{#*inline \"row\"}}\n  <li>{{.}}</li>\n{{/inline}}\n<ul>\n{{#each items}}\n{{> row}}\n{{/each}}\n</ul>",
+		map[string][]string{"items": {"a", "b"}},
+		nil, nil, nil,
+		"<ul>\n  <li>a</li>\n  <li>b</li>\n</ul>",
+	},
+	{
+		"partial block",
+		`{{#> layout}}<p>{{body}}</p>{{/layout}}`,
+		map[string]string{"title": "foo", "body": "bar"},
+		nil, nil,
+		map[string]string{"layout": "<h1>{{title}}</h1>{{> @partial-block}}"},
+		"<h1>foo</h1><p>bar</p>",
+	},
+	{
+		"partial block renders its content when partial is missing",
+		`{{#> missing}}default {{name}}{{/missing}}`,
+		map[string]string{"name": "foo"},
+		nil, nil, nil,
+		"default foo",
+	},
+	{
+		"partial block with context",
+		`{{#> layout user}}{{name}}{{/layout}} {{#> missing user}}{{name}}{{/missing}}`,
+		map[string]interface{}{"user": map[string]string{"name": "foo"}},
+		nil, nil,
+		map[string]string{"layout": "[{{> @partial-block}}]"},
+		"[foo] foo",
+	},
+	{
+		"partial block is visible to nested partials",
+		`{{#> layout}}content{{/layout}}`,
+		nil, nil, nil,
+		map[string]string{"layout": "<main>{{> body}}</main>", "body": "{{> @partial-block}}"},
+		"<main>content</main>",
+	},
+	{
+		"nested partial blocks",
+		`{{#> outer}}content{{/outer}}`,
+		nil, nil, nil,
+		map[string]string{"outer": "<o>{{#> inner}}<b>{{> @partial-block}}</b>{{/inner}}</o>", "inner": "<i>{{> @partial-block}}</i>"},
+		"<o><i><b>content</b></i></o>",
+	},
+	{
+		"partial block with inline partials",
+		`{{#> layout}}{{#*inline "title"}}My Title{{/inline}}body{{/layout}}`,
+		nil, nil, nil,
+		map[string]string{"layout": "<h1>{{> title}}</h1>{{> @partial-block}}"},
+		"<h1>My Title</h1>body",
+	},
+	{
+		"standalone partial block",
+		"{{#> layout}}\n  content\n{{/layout}}\n",
+		nil, nil, nil,
+		map[string]string{"layout": "<div>\n{{> @partial-block}}\n</div>"},
+		"<div>\n  content\n</div>",
+	},
 
 	// @todo Test with a "../../path" (depth 2 path) while context is only depth 1
 }
@@ -88,7 +200,7 @@ var evalErrors = []Test{
 		`{{foo "bar"}}`,
 		map[string]interface{}{"foo": func(a string, b string) string { return "foo" }},
 		nil, nil, nil,
-		"Helper 'foo' called with wrong number of arguments, needed 2 but got 1",
+		"Helper 'foo' failed on line 1, column 3: called with wrong number of arguments, needed 2 but got 1",
 	},
 	{
 		"functions with wrong number of returned values (1)",
@@ -104,6 +216,24 @@ var evalErrors = []Test{
 		nil, nil, nil,
 		"Helper function must return a string or a SafeString",
 	},
+	{
+		"unknown decorator",
+		`{{#*foo "bar"}}baz{{/foo}}`,
+		nil, nil, nil, nil,
+		"Unknown decorator: foo",
+	},
+	{
+		"inline partial without name",
+		`{{#*inline}}baz{{/inline}}`,
+		nil, nil, nil, nil,
+		"Inline partial needs one name parameter, got 0",
+	},
+	{
+		"partial block outside of a partial block",
+		`{{> @partial-block}}`,
+		nil, nil, nil, nil,
+		"Partial '@partial-block' not found on line 1, column 1",
+	},
 }
 
 func TestEvalErrors(t *testing.T) {
@@ -0,0 +1,148 @@
+package raymond
+
+import (
+	"reflect"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// FieldNameResolver maps template names to exported struct fields, see Template.SetFieldNameResolver().
+//
+// Resolvers are used as cache keys, so they must be comparable.
+type FieldNameResolver interface {
+	// FieldName returns the name of given struct field, used as @key when iterating over a struct with #each.
+	// An empty string hides that field.
+	FieldName(field reflect.StructField) string
+
+	// Match returns true if given template name references given struct field.
+	Match(field reflect.StructField, name string) bool
+}
+
+var (
+	// HandlebarsTagFieldNames resolves fields by their `handlebars` struct tag, or by their Go name if they have no
+	// such tag.
+	HandlebarsTagFieldNames FieldNameResolver = TagFieldNames("handlebars")
+
+	// JSONTagFieldNames resolves fields by their `json` struct tag, or by their Go name if they have no such tag.
+	// Fields tagged with `json:"-"` are hidden.
+	JSONTagFieldNames FieldNameResolver = TagFieldNames("json")
+
+	// SnakeCaseFieldNames resolves fields by the snake_case version of their Go name (eg: FirstName => first_name).
+	SnakeCaseFieldNames FieldNameResolver = snakeCaseFieldNames{}
+
+	// CaseInsensitiveFieldNames resolves fields by their Go name, ignoring case.
+	CaseInsensitiveFieldNames FieldNameResolver = caseInsensitiveFieldNames{}
+)
+
+// TagFieldNames returns a resolver that resolves fields by given struct tag, or by their Go name if they have no
+// such tag. As with encoding/json, only the part of the tag before the first comma is used, and fields tagged with
+// "-" are hidden.
+func TagFieldNames(tag string) FieldNameResolver {
+	return tagFieldNames(tag)
+}
+
+// tagFieldNames resolves fields by struct tag
+type tagFieldNames string
+
+// FieldName implements FieldNameResolver
+func (tag tagFieldNames) FieldName(field reflect.StructField) string {
+	name := field.Tag.Get(string(tag))
+	if i := strings.IndexByte(name, ','); i >= 0 {
+		name = name[:i]
+	}
+
+	switch name {
+	case "-":
+		return ""
+	case "":
+		return field.Name
+	}
+
+	return name
+}
+
+// Match implements FieldNameResolver
+func (tag tagFieldNames) Match(field reflect.StructField, name string) bool {
+	return tag.FieldName(field) == name
+}
+
+// snakeCaseFieldNames resolves fields by their snake_case Go name
+type snakeCaseFieldNames struct{}
+
+// FieldName implements FieldNameResolver
+func (snakeCaseFieldNames) FieldName(field reflect.StructField) string {
+	return snakeCase(field.Name)
+}
+
+// Match implements FieldNameResolver
+func (r snakeCaseFieldNames) Match(field reflect.StructField, name string) bool {
+	return r.FieldName(field) == name
+}
+
+// caseInsensitiveFieldNames resolves fields by their Go name, ignoring case
+type caseInsensitiveFieldNames struct{}
+
+// FieldName implements FieldNameResolver
+func (caseInsensitiveFieldNames) FieldName(field reflect.StructField) string {
+	return field.Name
+}
+
+// Match implements FieldNameResolver
+func (caseInsensitiveFieldNames) Match(field reflect.StructField, name string) bool {
+	return strings.EqualFold(field.Name, name)
+}
+
+// snakeCase converts given CamelCase name to snake_case (eg: UserID => user_id, HTTPServer => http_server)
+func snakeCase(name string) string {
+	var buf strings.Builder
+
+	var prev rune
+
+	for i, r := range name {
+		if unicode.IsUpper(r) {
+			if i > 0 && (prev != '_') {
+				next, _ := utf8.DecodeRuneInString(name[i+utf8.RuneLen(r):])
+
+				if unicode.IsLower(prev) || unicode.IsDigit(prev) || (unicode.IsUpper(prev) && unicode.IsLower(next)) {
+					buf.WriteByte('_')
+				}
+			}
+
+			buf.WriteRune(unicode.ToLower(r))
+		} else {
+			buf.WriteRune(r)
+		}
+
+		prev = r
+	}
+
+	return buf.String()
+}
+
+// structFields returns the exported fields of given struct type that are visible with given resolver, in
+// declaration order. The fields of embedded structs are flattened, as with encoding/json.
+func structFields(typ reflect.Type, resolver FieldNameResolver) []reflect.StructField {
+	var result []reflect.StructField
+
+	for _, field := range reflect.VisibleFields(typ) {
+		if (field.PkgPath != "") || (field.Anonymous && indirectType(field.Type).Kind() == reflect.Struct) {
+			continue
+		}
+
+		if resolver.FieldName(field) != "" {
+			result = append(result, field)
+		}
+	}
+
+	return result
+}
+
+// indirectType returns the type pointed to by given type, if it is a pointer
+func indirectType(typ reflect.Type) reflect.Type {
+	if typ.Kind() == reflect.Ptr {
+		return typ.Elem()
+	}
+
+	return typ
+}
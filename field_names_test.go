@@ -0,0 +1,137 @@
+package raymond
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type fieldNamesAudit struct {
+	CreatedBy string `json:"created_by"`
+}
+
+type fieldNamesUser struct {
+	UserID    int    `json:"user_id"`
+	FirstName string `json:"first_name,omitempty" handlebars:"first"`
+	Password  string `json:"-"`
+	HTTPProxy string
+	fieldNamesAudit
+}
+
+var fieldNamesTests = []struct {
+	name     string
+	resolver FieldNameResolver
+	source   string
+	output   string
+}{
+	{
+		"default",
+		nil,
+		`{{userID}} {{first}} {{firstName}} {{first_name}}|{{#each .}}{{@key}},{{/each}}`,
+		`42 Jean Jean |UserID,FirstName,Password,HTTPProxy,`,
+	},
+	{
+		"json tags",
+		JSONTagFieldNames,
+		`{{user_id}} {{first_name}} {{firstName}} [{{password}}{{Password}}] {{HTTPProxy}} {{created_by}}|{{#each .}}{{@key}}={{.}},{{/each}}`,
+		`42 Jean  [] proxy admin|user_id=42,first_name=Jean,HTTPProxy=proxy,created_by=admin,`,
+	},
+	{
+		"handlebars tags",
+		HandlebarsTagFieldNames,
+		`{{UserID}} {{first}} {{FirstName}}|{{#each .}}{{@key}},{{/each}}`,
+		`42 Jean |UserID,first,Password,HTTPProxy,CreatedBy,`,
+	},
+	{
+		"snake case",
+		SnakeCaseFieldNames,
+		`{{user_id}} {{first_name}} {{http_proxy}} {{UserID}}|{{#each .}}{{@key}},{{/each}}`,
+		`42 Jean proxy |user_id,first_name,password,http_proxy,created_by,`,
+	},
+	{
+		"case insensitive",
+		CaseInsensitiveFieldNames,
+		`{{USERID}} {{firstname}} {{httpProxy}}|{{#each .}}{{@last}}{{/each}}`,
+		`42 Jean proxy|falsefalsefalsefalsetrue`,
+	},
+}
+
+func TestFieldNameResolver(t *testing.T) {
+	t.Parallel()
+
+	ctx := fieldNamesUser{42, "Jean", "secret", "proxy", fieldNamesAudit{"admin"}}
+
+	for _, test := range fieldNamesTests {
+		tpl := MustParse(test.source)
+		tpl.SetFieldNameResolver(test.resolver)
+
+		if output := tpl.MustExec(ctx); output != test.output {
+			t.Errorf("Test '%s' failed\nexpected:\n\t%q\ngot:\n\t%q", test.name, test.output, output)
+		}
+
+		// partials and clones use the same resolver
+		tpl = MustParse(`{{> user}}`).Clone()
+		tpl.RegisterPartial("user", test.source)
+		tpl.SetFieldNameResolver(test.resolver)
+
+		if output := tpl.Clone().MustExec(ctx); output != test.output {
+			t.Errorf("Test '%s' failed with partial\nexpected:\n\t%q\ngot:\n\t%q", test.name, test.output, output)
+		}
+	}
+}
+
+func TestFieldNameResolverNilEmbedded(t *testing.T) {
+	t.Parallel()
+
+	type ctxType struct {
+		Name string
+		*fieldNamesAudit
+	}
+
+	tpl := MustParse(`{{Name}}[{{created_by}}]|{{#each .}}{{@key}},{{/each}}`)
+	tpl.SetFieldNameResolver(JSONTagFieldNames)
+
+	if output := tpl.MustExec(ctxType{Name: "foo"}); output != "foo[]|Name," {
+		t.Errorf("Failed to evaluate struct with nil embedded pointer, got: %q", output)
+	}
+}
+
+type funcFieldNames func(name string) string
+
+func (f funcFieldNames) FieldName(field reflect.StructField) string { return f(field.Name) }
+
+func (f funcFieldNames) Match(field reflect.StructField, name string) bool {
+	return f(field.Name) == name
+}
+
+func TestFieldNameResolverNotComparable(t *testing.T) {
+	t.Parallel()
+
+	defer func() {
+		if r := recover(); r == nil {
+			t.Errorf("Setting a non comparable field name resolver should panic")
+		}
+	}()
+
+	MustParse(`{{foo}}`).SetFieldNameResolver(funcFieldNames(strings.ToLower))
+}
+
+func TestSnakeCase(t *testing.T) {
+	t.Parallel()
+
+	for name, expected := range map[string]string{
+		"":           "",
+		"Name":       "name",
+		"FirstName":  "first_name",
+		"UserID":     "user_id",
+		"HTTPServer": "http_server",
+		"Address2":   "address2",
+		"Page2Title": "page2_title",
+		"Already_Ok": "already_ok",
+		"ÉtéÀPlage":  "été_à_plage",
+	} {
+		if output := snakeCase(name); output != expected {
+			t.Errorf("Failed to convert %q to snake case, expected: %q, got: %q", name, expected, output)
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package raymond
+
+import (
+	"errors"
+
+	"github.com/komand/raymond/ast"
+	"github.com/komand/raymond/parser"
+)
+
+// Format parses given template source and returns it in a normalized form, with given options (nil for defaults).
+//
+// Formatting never changes rendered output: an error is returned if the formatted template is not
+// equivalent to source.
+func Format(source string, opts *ast.FormatOptions) (string, error) {
+	tpl := newTemplate(source, false)
+	if err := tpl.parse(); err != nil {
+		return "", err
+	}
+
+	program := tpl.program
+	expected := ast.Print(program)
+
+	result := ast.Format(program, source, opts)
+	if formatEquivalent(result, expected) {
+		return result, nil
+	}
+
+	// re-indented whitespaces may be rendered
+	keepIndent := ast.FormatOptions{}
+	if opts != nil {
+		keepIndent = *opts
+	}
+	keepIndent.KeepIndent = true
+
+	result = ast.Format(program, source, &keepIndent)
+	if formatEquivalent(result, expected) {
+		return result, nil
+	}
+
+	return "", errors.New("Template can't be formatted without changing its output")
+}
+
+// formatEquivalent returns true if given formatted template parses to given AST representation
+func formatEquivalent(formatted string, expected string) bool {
+	program, err := parser.Parse(formatted, false)
+	if err != nil {
+		return false
+	}
+
+	return ast.Print(program) == expected
+}
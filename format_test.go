@@ -0,0 +1,96 @@
+package raymond
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/komand/raymond/ast"
+)
+
+var formatTests = []struct {
+	name   string
+	input  string
+	opts   *ast.FormatOptions
+	output string
+}{
+	{
+		"mustache whitespaces",
+		"{{~  title  }} {{{ body }}} {{#  if  ok ~}}yes{{~/ if }}",
+		nil,
+		"{{~title}} {{{body}}} {{#if ok~}}yes{{~/if}}",
+	},
+	{
+		"hash and params",
+		"{{#each   items   sep = ', '  as | item  i |}}{{/each}}{{> ( lookup . 'p' )  foo = bar }}",
+		nil,
+		"{{#each items sep=\", \" as |item i|}}{{/each}}{{> (lookup . \"p\") foo=bar}}",
+	},
+	{
+		"preferred quote",
+		"{{#if (equal a \"x\")}}{{link 'say \"hi\"'}}{{/if}}",
+		&ast.FormatOptions{Quote: '\''},
+		"{{#if (equal a 'x')}}{{link 'say \"hi\"'}}{{/if}}",
+	},
+	{
+		"standalone tags indentation",
+		"{{#each items}}\n      {{#if ok}}   \n  - {{name}}\n         {{else}}\n  - none\n{{/if}}\n   {{! comment }}\n {{/each}}\n",
+		nil,
+		"{{#each items}}\n  {{#if ok}}\n  - {{name}}\n  {{else}}\n  - none\n  {{/if}}\n   {{! comment }}\n{{/each}}\n",
+	},
+	{
+		"else chain indentation",
+		"<ul>\n{{#if a}}\n{{#b}}\n  <li>b</li>\n{{/b}}\n    {{else if c}}\n<li>c</li>\n    {{^}}\n<li>none</li>\n  {{/if}}\n</ul>",
+		&ast.FormatOptions{Indent: "\t"},
+		"<ul>\n{{#if a}}\n\t{{#b}}\n  <li>b</li>\n\t{{/b}}\n    {{else if c}}\n<li>c</li>\n{{^}}\n<li>none</li>\n  {{/if}}\n</ul>",
+	},
+	{
+		"rendered indentation",
+		"{{#if a}} {{#b}}\n  {{b}}  {{/b}}\n    {{/if}}",
+		nil,
+		"{{#if a}} {{#b}}\n  {{b}}  {{/b}}\n{{/if}}",
+	},
+	{
+		"keep indentation",
+		"{{#if a}}\n    {{#b}}\n    {{/b}}\n{{/if}}",
+		&ast.FormatOptions{KeepIndent: true},
+		"{{#if a}}\n    {{#b}}\n    {{/b}}\n{{/if}}",
+	},
+}
+
+func TestFormat(t *testing.T) {
+	t.Parallel()
+
+	for _, test := range formatTests {
+		output, err := Format(test.input, test.opts)
+		if err != nil {
+			t.Errorf("Test '%s' failed - Failed to format template: %s", test.name, err)
+			continue
+		}
+
+		if output != test.output {
+			t.Errorf("Test '%s' failed\ninput:\n\t%q\nexpected\n\t%q\ngot\n\t%q", test.name, test.input, test.output, output)
+		}
+	}
+}
+
+func TestFormatError(t *testing.T) {
+	t.Parallel()
+
+	if _, err := Format("{{#if}}", nil); err == nil {
+		t.Errorf("Test failed - Error expected")
+	}
+}
+
+func ExampleFormat() {
+	source := "{{#if   author}}\n    <h1>{{ firstName }} {{lastName   }}</h1>\n      {{/if}}"
+
+	output, err := Format(source, nil)
+	if err != nil {
+		panic(err)
+	}
+
+	fmt.Print(output)
+	// Output: {{#if author}}
+	//     <h1>{{firstName}} {{lastName}}</h1>
+	// {{/if}}
+}
@@ -0,0 +1,81 @@
+package handlebars
+
+import (
+	"testing"
+
+	"github.com/komand/raymond"
+)
+
+// execTest renders given template source with test data, helpers and given partials
+func execTest(test Test, source string, partials map[string]string) (string, error) {
+	tpl, err := raymond.Parse(source)
+	if err != nil {
+		return "", err
+	}
+
+	if len(test.helpers) > 0 {
+		tpl.RegisterHelpers(test.helpers)
+	}
+
+	if len(partials) > 0 {
+		tpl.RegisterPartials(partials)
+	}
+
+	var privData *raymond.DataFrame
+	if test.privData != nil {
+		privData = raymond.NewDataFrame()
+		for k, v := range test.privData {
+			privData.Set(k, v)
+		}
+	}
+
+	return tpl.ExecWith(test.data, privData)
+}
+
+// outputAllowed returns true if given output is one of several allowed test outputs
+func outputAllowed(test Test, output string) bool {
+	if outputs, ok := test.output.([]string); ok {
+		for _, str := range outputs {
+			if str == output {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func TestFormatOutput(t *testing.T) {
+	t.Parallel()
+
+	for _, test := range allTests() {
+		input, err := raymond.Format(test.input, nil)
+		if err != nil {
+			if _, errParse := raymond.Parse(test.input); errParse == nil {
+				t.Errorf("Test '%s' failed - Failed to format template\ninput:\n\t%q\nerror:\n\t%s", test.name, test.input, err)
+			}
+			continue
+		}
+
+		partials := make(map[string]string)
+		for name, partial := range test.partials {
+			if partials[name], err = raymond.Format(partial, nil); err != nil {
+				partials[name] = partial
+			}
+		}
+
+		expected, errExpected := execTest(test, test.input, test.partials)
+		output, errOutput := execTest(test, input, partials)
+
+		if (errExpected == nil) != (errOutput == nil) {
+			t.Errorf("Test '%s' failed\ninput:\n\t%q\nformatted:\n\t%q\nexpected error:\n\t%v\ngot error:\n\t%v", test.name, test.input, input, errExpected, errOutput)
+		} else if (expected != output) && !outputAllowed(test, output) {
+			t.Errorf("Test '%s' failed\ninput:\n\t%q\nformatted:\n\t%q\nexpected\n\t%q\ngot\n\t%q", test.name, test.input, input, expected, output)
+		}
+
+		// formatting is idempotent
+		if again, err := raymond.Format(input, nil); (err != nil) || (again != input) {
+			t.Errorf("Test '%s' failed - Formatting is not idempotent\ninput:\n\t%q\nformatted:\n\t%q\nformatted again:\n\t%q", test.name, test.input, input, again)
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package handlebars
+
+import (
+	"testing"
+
+	"github.com/komand/raymond"
+	"github.com/komand/raymond/ast"
+	"github.com/komand/raymond/parser"
+)
+
+// allTests returns all handlebars.js tests
+func allTests() []Test {
+	var result []Test
+
+	for _, tests := range [][]Test{
+		basicTests,
+		blocksTests,
+		builtinsTests,
+		dataTests,
+		helpersTests,
+		partialsTests,
+		subexpressionsTests,
+		whitespaceControlTests,
+	} {
+		result = append(result, tests...)
+	}
+
+	return result
+}
+
+func TestPrintRoundTrip(t *testing.T) {
+	t.Parallel()
+
+	for _, test := range allTests() {
+		sources := []string{test.input}
+		for _, partial := range test.partials {
+			sources = append(sources, partial)
+		}
+
+		for _, source := range sources {
+			tpl, err := raymond.Parse(source)
+			if err != nil {
+				// parsing errors are checked by other tests
+				continue
+			}
+
+			if output := tpl.Print(); output != source {
+				t.Errorf("Test '%s' failed\ninput:\n\t%q\ngot\n\t%q\nAST:\n%s", test.name, source, output, tpl.PrintAST())
+			}
+		}
+	}
+}
+
+func TestPrintCanonical(t *testing.T) {
+	t.Parallel()
+
+	for _, test := range allTests() {
+		program, err := parser.Parse(test.input, false)
+		if err != nil {
+			continue
+		}
+
+		// without source, the whole template is regenerated
+		output := ast.PrintOriginal(program)
+
+		reparsed, err := parser.Parse(output, false)
+		if err != nil {
+			t.Errorf("Test '%s' failed - Failed to parse printed template\ninput:\n\t%q\noutput:\n\t%q\nerror:\n\t%s", test.name, test.input, output, err)
+			continue
+		}
+
+		if expected, got := ast.Print(program), ast.Print(reparsed); expected != got {
+			t.Errorf("Test '%s' failed\ninput:\n\t%q\noutput:\n\t%q\nexpected AST:\n%s\ngot AST:\n%s", test.name, test.input, output, expected, got)
+		}
+	}
+}
@@ -1,13 +1,19 @@
 package raymond
 
 import (
+	"errors"
 	"fmt"
-	"log"
 	"reflect"
+	"strings"
 	"sync"
+	"sync/atomic"
+
+	"github.com/komand/raymond/ast"
 )
 
 // Options represents the options argument provided to helpers and context functions.
+//
+// Options, and the private data frame it returns, are only valid during the call of the helper or function.
 type Options struct {
 	// evaluation visitor
 	eval *evalVisitor
@@ -47,6 +53,9 @@ func RegisterHelper(name string, helper interface{}) {
 	ensureValidHelper(name, val)
 
 	helpers[name] = val
+
+	// invalidates compiled programs, that bind global helpers
+	atomic.AddUint64(&helpersVersion, 1)
 }
 
 // RegisterHelpers registers several global helpers. Those helpers will be available to all templates.
@@ -58,17 +67,26 @@ func RegisterHelpers(helpers map[string]interface{}) {
 
 // ensureValidHelper panics if given helper is not valid
 func ensureValidHelper(name string, funcValue reflect.Value) {
+	if err := validateHelper(funcValue); err != nil {
+		panic(fmt.Errorf("%s: %s", err, name))
+	}
+}
+
+// validateHelper returns an error if given helper is not valid
+func validateHelper(funcValue reflect.Value) error {
 	if funcValue.Kind() != reflect.Func {
-		panic(fmt.Errorf("Helper must be a function: %s", name))
+		return errors.New("Helper must be a function")
 	}
 
 	funcType := funcValue.Type()
 
 	if funcType.NumOut() != 1 {
-		panic(fmt.Errorf("Helper function must return a string or a SafeString: %s", name))
+		return errors.New("Helper function must return a string or a SafeString")
 	}
 
 	// @todo Check if first returned value is a string, SafeString or interface{} ?
+
+	return nil
 }
 
 // findHelper finds a globally registered helper
@@ -236,7 +254,26 @@ func (options *Options) Inverse() string {
 	}
 
 	if block := options.eval.curBlock(); (block != nil) && (block.Inverse != nil) {
-		result, _ = block.Inverse.Accept(options.eval).(string)
+		result = options.eval.program(block.Inverse)(options.eval)
+	}
+
+	return result
+}
+
+// RawContent returns the unparsed content of current raw block, eg. "{{bar}}" for {{{{foo}}}}{{bar}}{{{{/foo}}}}.
+// It returns an empty string if current block is not a raw block.
+func (options *Options) RawContent() string {
+	block := options.eval.curBlock()
+	if (block == nil) || !block.Raw || (block.Program == nil) {
+		return ""
+	}
+
+	result := ""
+
+	for _, node := range block.Program.Body {
+		if content, ok := node.(*ast.ContentStatement); ok {
+			result += content.Original
+		}
 	}
 
 	return result
@@ -314,7 +351,11 @@ func eachHelper(context interface{}, options *Options) interface{} {
 		return options.Inverse()
 	}
 
-	result := ""
+	if obj, ok := context.(*jsonObject); ok {
+		return eachJSONMember(obj, options)
+	}
+
+	var result strings.Builder
 
 	val := reflect.ValueOf(context)
 	switch val.Kind() {
@@ -324,7 +365,7 @@ func eachHelper(context interface{}, options *Options) interface{} {
 			data := options.newIterDataFrame(val.Len(), i, nil)
 
 			// evaluates block
-			result += options.evalBlock(val.Index(i).Interface(), data, i)
+			result.WriteString(options.evalBlock(val.Index(i).Interface(), data, i))
 		}
 	case reflect.Map:
 		// note: a go hash is not ordered, so result may vary, this behaviour differs from the JS implementation
@@ -337,9 +378,13 @@ func eachHelper(context interface{}, options *Options) interface{} {
 			data := options.newIterDataFrame(len(keys), i, key)
 
 			// evaluates block
-			result += options.evalBlock(ctx, data, key)
+			result.WriteString(options.evalBlock(ctx, data, key))
 		}
 	case reflect.Struct:
+		if resolver := options.eval.tpl.fieldNames; resolver != nil {
+			return eachStructField(val, resolver, options)
+		}
+
 		var exportedFields []int
 
 		// collect exported fields only
@@ -357,28 +402,82 @@ func eachHelper(context interface{}, options *Options) interface{} {
 			data := options.newIterDataFrame(len(exportedFields), i, key)
 
 			// evaluates block
-			result += options.evalBlock(ctx, data, key)
+			result.WriteString(options.evalBlock(ctx, data, key))
+		}
+	}
+
+	return result.String()
+}
+
+// eachStructField iterates over struct fields visible with given resolver, for the #each block helper
+func eachStructField(val reflect.Value, resolver FieldNameResolver, options *Options) string {
+	var keys []string
+	var fields []reflect.Value
+
+	for _, tField := range typeInfoFor(val.Type()).visibleFields(resolver) {
+		// skip fields promoted from a nil embedded struct pointer
+		if field, err := val.FieldByIndexErr(tField.Index); err == nil {
+			keys = append(keys, resolver.FieldName(tField))
+			fields = append(fields, field)
 		}
 	}
 
+	result := ""
+
+	for i, key := range keys {
+		// computes private data
+		data := options.newIterDataFrame(len(keys), i, key)
+
+		// evaluates block
+		result += options.evalBlock(fields[i].Interface(), data, key)
+	}
+
+	return result
+}
+
+// eachJSONMember iterates over JSON object members in order, for the #each block helper
+func eachJSONMember(obj *jsonObject, options *Options) interface{} {
+	if obj.len() == 0 {
+		return options.Inverse()
+	}
+
+	result := ""
+
+	obj.each(func(i int, key string, val interface{}) {
+		// computes private data
+		data := options.newIterDataFrame(obj.len(), i, key)
+
+		// evaluates block
+		result += options.evalBlock(val, data, key)
+	})
+
 	return result
 }
 
 // #log helper
-func logHelper(message string) interface{} {
-	log.Print(message)
+func logHelper(args ...interface{}) interface{} {
+	options := args[len(args)-1].(*Options)
+
+	// level= hash argument, then @level private variable
+	level := options.HashProp("level")
+	if level == nil {
+		level = options.Data("level")
+	}
+
+	options.eval.log(logLevel(level), args[:len(args)-1])
+
 	return ""
 }
 
 // #lookup helper
 func lookupHelper(obj interface{}, field string, options *Options) interface{} {
-	return Str(options.Eval(obj, field))
+	return options.eval.str(options.Eval(obj, field))
 }
 
 // #equal helper
 // Ref: https://github.com/komand/raymond/issues/7
 func equalHelper(a interface{}, b interface{}, options *Options) interface{} {
-	if Str(a) == Str(b) {
+	if options.eval.str(a) == options.eval.str(b) {
 		return options.Fn()
 	}
 
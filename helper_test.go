@@ -1,6 +1,9 @@
 package raymond
 
-import "testing"
+import (
+	"fmt"
+	"testing"
+)
 
 const (
 	VERBOSE = false
@@ -29,6 +32,10 @@ func boolHelper(b bool) string {
 	return "absolutely not"
 }
 
+func codeHelper(lang string, options *Options) string {
+	return fmt.Sprintf("<pre class=%q>%s</pre>", lang, options.RawContent())
+}
+
 func gnakHelper(nb int) string {
 	result := ""
 	for i := 0; i < nb; i++ {
@@ -220,6 +227,23 @@ foo is the same as baz
 there is one
 everything is stringified before comparison`,
 	},
+	{
+		"raw block helper gets unparsed content",
+		"{{{{code \"go\"}}}}\n{{#if ok}}{{x}}{{/if}}\n{{{{/code}}}}",
+		nil, nil,
+		map[string]interface{}{"code": codeHelper},
+		nil,
+		"<pre class=\"go\">\n{{#if ok}}{{x}}{{/if}}\n</pre>",
+	},
+	{
+		"raw content of a block that is not raw",
+		"{{#code \"go\"}}{{x}}{{/code}}",
+		map[string]string{"x": "foo"},
+		nil,
+		map[string]interface{}{"code": codeHelper},
+		nil,
+		"<pre class=\"go\"></pre>",
+	},
 }
 
 //
@@ -0,0 +1,156 @@
+package raymond
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"reflect"
+)
+
+// jsonNumberType is the reflect.Type of json.Number
+var jsonNumberType = reflect.TypeOf(json.Number(""))
+
+// errInvalidJSON is returned by ExecJSON() when given data is not valid JSON
+var errInvalidJSON = errors.New("Invalid JSON context")
+
+// jsonObject is a JSON object context, decoded lazily: its members are split on first access, and each member is
+// decoded only when a template references it. Members order is preserved when iterating with #each.
+type jsonObject struct {
+	raw     json.RawMessage
+	keys    []string
+	members map[string]json.RawMessage
+	decoded map[string]interface{}
+}
+
+// newJSONValue returns a context value for given valid raw JSON value
+//
+// Objects are returned as *jsonObject, arrays as []interface{}, numbers as json.Number, and other values as decoded
+// by encoding/json.
+func newJSONValue(raw json.RawMessage) interface{} {
+	raw = bytes.TrimSpace(raw)
+	if len(raw) == 0 {
+		return nil
+	}
+
+	switch raw[0] {
+	case '{':
+		return &jsonObject{raw: raw}
+	case '[':
+		var items []json.RawMessage
+		if err := json.Unmarshal(raw, &items); err != nil {
+			return nil
+		}
+
+		result := make([]interface{}, len(items))
+		for i, item := range items {
+			result[i] = newJSONValue(item)
+		}
+
+		return result
+	case '"':
+		var result string
+		if err := json.Unmarshal(raw, &result); err != nil {
+			return nil
+		}
+
+		return result
+	case 't':
+		return true
+	case 'f':
+		return false
+	case 'n':
+		return nil
+	}
+
+	return json.Number(raw)
+}
+
+// Resolve implements Resolver
+func (obj *jsonObject) Resolve(name string) (interface{}, bool) {
+	obj.split()
+
+	if val, ok := obj.decoded[name]; ok {
+		return val, true
+	}
+
+	raw, ok := obj.members[name]
+	if !ok {
+		return nil, false
+	}
+
+	val := newJSONValue(raw)
+	obj.decoded[name] = val
+
+	return val, true
+}
+
+// MarshalJSON implements json.Marshaler
+func (obj *jsonObject) MarshalJSON() ([]byte, error) {
+	return obj.raw, nil
+}
+
+// str returns the compacted JSON object, as Str() does for maps
+func (obj *jsonObject) str() string {
+	var buf bytes.Buffer
+
+	if err := json.Compact(&buf, obj.raw); err != nil {
+		return string(obj.raw)
+	}
+
+	return buf.String()
+}
+
+// split splits object members, if not already done
+func (obj *jsonObject) split() {
+	if obj.members != nil {
+		return
+	}
+
+	obj.members = make(map[string]json.RawMessage)
+	obj.decoded = make(map[string]interface{})
+
+	dec := json.NewDecoder(bytes.NewReader(obj.raw))
+
+	// opening brace
+	if _, err := dec.Token(); err != nil {
+		return
+	}
+
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return
+		}
+
+		key, _ := tok.(string)
+
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); err != nil {
+			return
+		}
+
+		// as with encoding/json, last duplicate member wins
+		if _, ok := obj.members[key]; !ok {
+			obj.keys = append(obj.keys, key)
+		}
+
+		obj.members[key] = raw
+	}
+}
+
+// each calls given function for each object member, in order
+func (obj *jsonObject) each(fn func(i int, key string, val interface{})) {
+	obj.split()
+
+	for i, key := range obj.keys {
+		val, _ := obj.Resolve(key)
+		fn(i, key, val)
+	}
+}
+
+// len returns the number of object members
+func (obj *jsonObject) len() int {
+	obj.split()
+
+	return len(obj.keys)
+}
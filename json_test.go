@@ -0,0 +1,98 @@
+package raymond
+
+import "testing"
+
+var execJSONTests = []struct {
+	name   string
+	source string
+	data   string
+	output string
+}{
+	{
+		"paths",
+		`{{title}} by {{author.name}} [{{missing}}] [{{author.missing.foo}}]`,
+		`{"title": "foo", "author": {"name": "bar"}}`,
+		`foo by bar [] []`,
+	},
+	{
+		"numbers kept verbatim",
+		`{{id}} {{price}} {{small}} {{#each list}}{{.}},{{/each}}`,
+		`{"id": 12345678901234567890, "price": 1.10, "small": 1e-7, "list": [1.0, 2]}`,
+		`12345678901234567890 1.10 1e-7 1.0,2,`,
+	},
+	{
+		"number truthiness",
+		`{{#if zero}}zero{{/if}}{{#if float}}float{{/if}}{{#if one}}one{{/if}}`,
+		`{"zero": 0, "float": 0.0, "one": 1}`,
+		`one`,
+	},
+	{
+		"object members order",
+		`{{#each .}}{{@index}}:{{@key}}={{.}}{{#if @last}}.{{else}},{{/if}}{{/each}}`,
+		`{"z": 1, "a": "two", "m": true, "b": false, "a": "dup"}`,
+		`0:z=1,1:a=dup,2:m=true,3:b=false.`,
+	},
+	{
+		"empty object and array",
+		`{{#each obj}}x{{else}}empty{{/each}} {{#each list}}x{{else}}empty{{/each}} {{#if obj}}truthy{{/if}}`,
+		`{"obj": {}, "list": []}`,
+		`empty empty truthy`,
+	},
+	{
+		"arrays of objects",
+		`{{#each items}}{{name}}:{{#each tags}}{{.}}{{/each}} {{/each}}{{items.[1].name}}`,
+		`{"items": [{"name": "a", "tags": ["x", "y"]}, {"name": "b", "tags": []}]}`,
+		`a:xy b: b`,
+	},
+	{
+		"output objects",
+		`{{obj}} {{list}}`,
+		"{\"obj\": {\"a\": 1,\n \"b\": [1.50, {}]}, \"list\": [{\"c\": 2.0}, 3]}",
+		`{&quot;a&quot;:1,&quot;b&quot;:[1.50,{}]} [{&quot;c&quot;:2.0},3]`,
+	},
+	{
+		"root array",
+		`{{#each .}}{{a}}{{/each}}`,
+		`[{"a": 1}, {"a": 2}]`,
+		`12`,
+	},
+}
+
+func TestExecJSON(t *testing.T) {
+	t.Parallel()
+
+	for _, test := range execJSONTests {
+		output, err := MustParse(test.source).ExecJSON([]byte(test.data))
+		if err != nil {
+			t.Errorf("Test '%s' failed: %s", test.name, err)
+		} else if output != test.output {
+			t.Errorf("Test '%s' failed\nexpected:\n\t%q\ngot:\n\t%q", test.name, test.output, output)
+		}
+	}
+}
+
+func TestExecJSONLazy(t *testing.T) {
+	t.Parallel()
+
+	obj := newJSONValue([]byte(`{"used": {"a": 1}, "unused": {"b": 2}}`)).(*jsonObject)
+
+	if output := MustParse(`{{used.a}}`).MustExec(obj); output != "1" {
+		t.Errorf("Failed to evaluate JSON context, got: %q", output)
+	}
+
+	if _, ok := obj.decoded["unused"]; ok {
+		t.Errorf("Unused member was decoded")
+	}
+
+	if used := obj.decoded["used"].(*jsonObject); used.members == nil {
+		t.Errorf("Used member was not decoded")
+	}
+}
+
+func TestExecJSONInvalid(t *testing.T) {
+	t.Parallel()
+
+	if _, err := MustParse(`{{foo}}`).ExecJSON([]byte(`{"foo": `)); err != errInvalidJSON {
+		t.Errorf("Expected invalid JSON error, got: %v", err)
+	}
+}
@@ -3,7 +3,6 @@ package lexer
 
 import (
 	"fmt"
-	"regexp"
 	"strings"
 	"unicode"
 	"unicode/utf8"
@@ -15,12 +14,8 @@ import (
 
 const (
 	// Mustaches detection
-	escapedEscapedOpenMustache  = "\\\\{{"
-	escapedOpenMustache         = "\\{{"
-	openMustache                = "{{"
-	closeMustache               = "}}"
-	closeStripMustache          = "~}}"
-	closeUnescapedStripMustache = "}~}}"
+	openMustache  = "{{"
+	closeMustache = "}}"
 )
 
 const eof = -1
@@ -29,66 +24,115 @@ const eof = -1
 type lexFunc func(*Lexer) lexFunc
 
 // Lexer is a lexical analyzer.
+//
+// Scanning is pull-based: lexer functions are run on demand by NextToken(), until at least one token is scanned.
 type Lexer struct {
-	input    string     // input to scan
-	name     string     // lexer name, used for testing purpose
-	tokens   chan Token // channel of scanned tokens
-	nextFunc lexFunc    // the next function to execute
+	input    string  // input to scan
+	name     string  // lexer name, used for testing purpose
+	tokens   []Token // scanned tokens not fetched yet, starting at index head
+	head     int     // index of next token to fetch
+	last     Token   // last fetched token
+	nextFunc lexFunc // the next function to execute
 
 	pos   int // current byte position in input string
-	line  int // current line position in input string
 	width int // size of last rune scanned from input string
 	start int // start position of the token we are scanning
 
+	// last located position, to compute line and column numbers incrementally
+	locPos    int
+	locLine   int
+	locColumn int
+
+	// current mustache delimiters
+	delims *delimiters
+
 	// the shameful contextual properties needed because `nextFunc` is not enough
-	closeComment *regexp.Regexp // regexp to scan close of current comment
-	rawBlock     bool           // are we parsing a raw block content ?
+	closeComment string // mark closing current comment, before close delimiter: "--" or ""
+	rawBlock     bool   // are we parsing a raw block content ?
 
 	idAllowSpaces bool
+
+	// resume scanning after errors
+	tolerant bool
 }
 
-var (
-	lookheadChars        = `[\s` + regexp.QuoteMeta("=~}/)|") + `]`
-	literalLookheadChars = `[\s` + regexp.QuoteMeta("~})") + `]`
+// delimiters holds the mustache delimiters and the strings derived from them
+type delimiters struct {
+	open  string
+	close string
+
+	// \\{{ and \{{
+	escapedEscapedOpen string
+	escapedOpen        string
+
+	// ~}}, }}} and }~}}
+	closeStrip          string
+	closeUnescaped      string
+	closeUnescapedStrip string
+
+	// {{{{/, {{= and =}}
+	openEndRaw         string
+	openSetDelimiters  string
+	closeSetDelimiters string
+}
+
+const (
+	// characters that can follow an identifier, besides close delimiter
+	lookheadChars        = " \t\n\f\r=~}/)|"
+	literalLookheadChars = " \t\n\f\r~})"
+
+	// whitespace characters, as matched by \s in regular expressions
+	spaceChars = " \t\n\f\r"
 
 	// characters not allowed in an identifier
 	unallowedIDChars            = " \n\t!\"#%&'()*+,./;<=>@[\\]^`{|}~"
 	unallowedIDCharsAllowSpaces = "\n\t!\"#%&'()*+,./;<=>@[\\]^`{|}~"
-
-	// regular expressions
-	rID = regexp.MustCompile(`^[^` + regexp.QuoteMeta(unallowedIDChars) + `]+`)
-
-	rIDAllowSpaces = regexp.MustCompile(`^[^` + regexp.QuoteMeta(unallowedIDCharsAllowSpaces) + `]+`)
-
-	rDotID               = regexp.MustCompile(`^\.` + lookheadChars)
-	rTrue                = regexp.MustCompile(`^true` + literalLookheadChars)
-	rFalse               = regexp.MustCompile(`^false` + literalLookheadChars)
-	rOpenRaw             = regexp.MustCompile(`^\{\{\{\{`)
-	rCloseRaw            = regexp.MustCompile(`^\}\}\}\}`)
-	rOpenEndRaw          = regexp.MustCompile(`^\{\{\{\{/`)
-	rOpenEndRawLookAhead = regexp.MustCompile(`\{\{\{\{/`)
-	rOpenUnescaped       = regexp.MustCompile(`^\{\{~?\{`)
-	rCloseUnescaped      = regexp.MustCompile(`^\}~?\}\}`)
-	rOpenBlock           = regexp.MustCompile(`^\{\{~?#`)
-	rOpenEndBlock        = regexp.MustCompile(`^\{\{~?/`)
-	rOpenPartial         = regexp.MustCompile(`^\{\{~?>`)
-	rOpenFunc            = regexp.MustCompile(`^\{\{~?$`)
-	// {{^}} or {{else}}
-	rInverse          = regexp.MustCompile(`^(\{\{~?\^\s*~?\}\}|\{\{~?\s*else\s*~?\}\})`)
-	rOpenInverse      = regexp.MustCompile(`^\{\{~?\^`)
-	rOpenInverseChain = regexp.MustCompile(`^\{\{~?\s*else`)
-	// {{ or {{&
-	rOpen            = regexp.MustCompile(`^\{\{~?&?`)
-	rClose           = regexp.MustCompile(`^~?\}\}`)
-	rOpenBlockParams = regexp.MustCompile(`^as\s+\|`)
-	// {{!--  ... --}}
-	rOpenCommentDash  = regexp.MustCompile(`^\{\{~?!--\s*`)
-	rCloseCommentDash = regexp.MustCompile(`^\s*--~?\}\}`)
-	// {{! ... }}
-	rOpenComment  = regexp.MustCompile(`^\{\{~?!\s*`)
-	rCloseComment = regexp.MustCompile(`^\s*~?\}\}`)
 )
 
+var defaultDelimiters = newDelimiters(openMustache, closeMustache)
+
+// newDelimiters instanciates delimiters for given open and close strings, that must be valid
+func newDelimiters(open string, close string) *delimiters {
+	return &delimiters{
+		open:  open,
+		close: close,
+
+		escapedEscapedOpen: "\\\\" + open,
+		escapedOpen:        "\\" + open,
+
+		closeStrip:          "~" + close,
+		closeUnescaped:      "}" + close,
+		closeUnescapedStrip: "}~" + close,
+
+		openEndRaw:         open + "{{/",
+		openSetDelimiters:  open + "=",
+		closeSetDelimiters: "=" + close,
+	}
+}
+
+// canonicalOpen returns given string starting with open delimiter, with {{ instead of that delimiter
+func (d *delimiters) canonicalOpen(str string) string {
+	if d.open == openMustache {
+		return str
+	}
+
+	return openMustache + str[len(d.open):]
+}
+
+// canonicalClose returns given string ending with close delimiter, with }} instead of that delimiter
+func (d *delimiters) canonicalClose(str string) string {
+	if d.close == closeMustache {
+		return str
+	}
+
+	return str[:len(str)-len(d.close)] + closeMustache
+}
+
+// validDelimiter returns true if given delimiter is not empty and does not contain whitespaces nor equal signs
+func validDelimiter(delim string) bool {
+	return (delim != "") && !strings.ContainsAny(delim, spaceChars+"=")
+}
+
 // Scan scans given input.
 //
 // Tokens can then be fetched sequentially thanks to NextToken() function on returned lexer.
@@ -96,22 +140,53 @@ func Scan(input string) *Lexer {
 	return scanWithName(input, "")
 }
 
-// scanWithName scans given input, with a name used for testing
+// ScanWithDelimiters scans given input, like Scan, with given mustache delimiters instead of {{ and }}.
 //
-// Tokens can then be fetched sequentially thanks to NextToken() function on returned lexer.
-func scanWithName(input string, name string) *Lexer {
-	result := &Lexer{
-		input:  input,
-		name:   name,
-		tokens: make(chan Token),
-		line:   1,
+// Delimiters must not be empty, and must not contain whitespaces nor equal signs. Values of scanned mustache and
+// comment tokens are still given with {{ and }} delimiters, so that the parser does not depend on them.
+func ScanWithDelimiters(input string, open string, close string) *Lexer {
+	result := newLexer(input, "")
+
+	if !validDelimiter(open) || !validDelimiter(close) {
+		result.nextFunc = result.errorf("Invalid delimiters: %q %q", open, close)
+
+		return result
 	}
 
-	go result.run()
+	result.delims = newDelimiters(open, close)
 
 	return result
 }
 
+// ScanTolerant scans given input, like Scan, but does not stop on errors.
+//
+// After an error token, scanning resumes at next mustache.
+func ScanTolerant(input string) *Lexer {
+	result := newLexer(input, "")
+	result.tolerant = true
+
+	return result
+}
+
+// scanWithName scans given input, with a name used for testing
+//
+// Tokens can then be fetched sequentially thanks to NextToken() function on returned lexer.
+func scanWithName(input string, name string) *Lexer {
+	return newLexer(input, name)
+}
+
+// newLexer instanciates a new lexer
+func newLexer(input string, name string) *Lexer {
+	return &Lexer{
+		input:     input,
+		name:      name,
+		nextFunc:  lexContent,
+		locLine:   1,
+		locColumn: 1,
+		delims:    defaultDelimiters,
+	}
+}
+
 // Collect scans and collect all tokens.
 //
 // This should be used for debugging purpose only. You should use Scan() and lexer.NextToken() functions instead.
@@ -132,17 +207,23 @@ func Collect(input string) []Token {
 }
 
 // NextToken returns the next scanned token.
+//
+// Once scanning is over, the last token (EOF or error) is returned again.
 func (l *Lexer) NextToken() Token {
-	result := <-l.tokens
-
-	return result
-}
+	for (l.head == len(l.tokens)) && (l.nextFunc != nil) {
+		// all tokens fetched: reuse buffer
+		l.tokens = l.tokens[:0]
+		l.head = 0
 
-// run starts lexical analysis
-func (l *Lexer) run() {
-	for l.nextFunc = lexContent; l.nextFunc != nil; {
 		l.nextFunc = l.nextFunc(l)
 	}
+
+	if l.head < len(l.tokens) {
+		l.last = l.tokens[l.head]
+		l.head++
+	}
+
+	return l.last
 }
 
 // next returns next character from input, or eof of there is nothing left to scan
@@ -152,21 +233,49 @@ func (l *Lexer) next() rune {
 		return eof
 	}
 
-	r, w := utf8.DecodeRuneInString(l.input[l.pos:])
+	r, w := rune(l.input[l.pos]), 1
+	if r >= utf8.RuneSelf {
+		r, w = utf8.DecodeRuneInString(l.input[l.pos:])
+	}
+
 	l.width = w
 	l.pos += l.width
 
 	return r
 }
 
+// locate returns line and column numbers of given byte position, that must not precede last located position
+func (l *Lexer) locate(pos int) (int, int) {
+	for l.locPos < pos {
+		r, w := utf8.DecodeRuneInString(l.input[l.locPos:])
+		l.locPos += w
+
+		if r == '\n' {
+			l.locLine++
+			l.locColumn = 1
+		} else {
+			l.locColumn++
+		}
+	}
+
+	return l.locLine, l.locColumn
+}
+
+// token returns a token with given kind and value, spanning from start to current position
+func (l *Lexer) token(kind TokenKind, val string) Token {
+	result := Token{Kind: kind, Val: val, Pos: l.start, End: l.pos}
+
+	result.Line, result.Column = l.locate(result.Pos)
+	result.EndLine, result.EndColumn = l.locate(result.End)
+
+	return result
+}
+
 func (l *Lexer) produce(kind TokenKind, val string) {
-	l.tokens <- Token{kind, val, l.start, l.line}
+	l.tokens = append(l.tokens, l.token(kind, val))
 
 	// scanning a new token
 	l.start = l.pos
-
-	// update line number
-	l.line += strings.Count(val, "\n")
 }
 
 // emit emits a new scanned token
@@ -186,7 +295,9 @@ func (l *Lexer) emitString(delimiter rune) {
 	str := l.input[l.start:l.pos]
 
 	// replace escaped delimiters
-	str = strings.Replace(str, "\\"+string(delimiter), string(delimiter), -1)
+	if strings.IndexByte(str, '\\') >= 0 {
+		str = strings.Replace(str, "\\"+string(delimiter), string(delimiter), -1)
+	}
 
 	l.produce(TokenString, str)
 }
@@ -231,46 +342,284 @@ func (l *Lexer) acceptRun(valid string) {
 
 // errorf emits an error token
 func (l *Lexer) errorf(format string, args ...interface{}) lexFunc {
-	l.tokens <- Token{TokenError, fmt.Sprintf(format, args...), l.start, l.line}
+	l.tokens = append(l.tokens, l.token(TokenError, fmt.Sprintf(format, args...)))
+
+	if l.tolerant {
+		return l.resume()
+	}
+
 	return nil
 }
 
+// resume skips input up to next mustache following an error, and resumes scanning
+func (l *Lexer) resume() lexFunc {
+	l.closeComment = ""
+	l.rawBlock = false
+	l.idAllowSpaces = false
+
+	// always move forward
+	from := l.pos
+	if from == l.start {
+		from++
+	}
+
+	if from > len(l.input) {
+		from = len(l.input)
+	}
+
+	if i := strings.Index(l.input[from:], l.delims.open); i >= 0 {
+		l.pos = from + i
+	} else {
+		l.pos = len(l.input)
+	}
+
+	l.ignore()
+
+	return lexContent
+}
+
 // isString returns true if content at current scanning position starts with given string
 func (l *Lexer) isString(str string) bool {
 	return strings.HasPrefix(l.input[l.pos:], str)
 }
 
-// findRegexp returns the first string from current scanning position that matches given regular expression
-func (l *Lexer) findRegexp(r *regexp.Regexp) string {
-	return r.FindString(l.input[l.pos:])
+//
+// Matching
+//
+// Those functions match patterns at the start of given string, and return the length of the match, or 0 if there
+// is no match.
+//
+
+// matchSpaces matches whitespaces: \s*
+func matchSpaces(s string) int {
+	i := 0
+	for (i < len(s)) && (strings.IndexByte(spaceChars, s[i]) >= 0) {
+		i++
+	}
+
+	return i
+}
+
+// matchID matches an identifier, with given unallowed characters
+func matchID(s string, unallowed string) int {
+	i := 0
+	for (i < len(s)) && (strings.IndexByte(unallowed, s[i]) < 0) {
+		i++
+	}
+
+	return i
 }
 
-// indexRegexp returns the index of the first string from current scanning position that matches given regular expression
-//
-// It returns -1 if not found
-func (l *Lexer) indexRegexp(r *regexp.Regexp) int {
-	loc := r.FindStringIndex(l.input[l.pos:])
-	if loc == nil {
-		return -1
+// matchOpen matches an open delimiter, followed by an optional strip mark: {{~?
+func (d *delimiters) matchOpen(s string) int {
+	if !strings.HasPrefix(s, d.open) {
+		return 0
+	}
+
+	i := len(d.open)
+	if (i < len(s)) && (s[i] == '~') {
+		i++
+	}
+
+	return i
+}
+
+// matchClose matches an optional strip mark followed by close delimiter: ~?}}
+func (d *delimiters) matchClose(s string) int {
+	if strings.HasPrefix(s, d.closeStrip) {
+		return len(d.closeStrip)
+	}
+
+	if strings.HasPrefix(s, d.close) {
+		return len(d.close)
+	}
+
+	return 0
+}
+
+// matchOpenComment matches the opening of a comment: {{~?!-- or {{~?!, with given mark
+func (d *delimiters) matchOpenComment(s string, mark string) bool {
+	i := d.matchOpen(s)
+
+	return (i > 0) && (i < len(s)) && (s[i] == '!') && strings.HasPrefix(s[i+1:], mark)
+}
+
+// matchCloseComment matches the closing of a comment: \s*--~?}} or \s*~?}}, with given mark
+func (d *delimiters) matchCloseComment(s string, mark string) int {
+	i := matchSpaces(s)
+	if !strings.HasPrefix(s[i:], mark) {
+		return 0
+	}
+
+	i += len(mark)
+
+	if n := d.matchClose(s[i:]); n > 0 {
+		return i + n
+	}
+
+	return 0
+}
+
+// matchInverse matches {{^}} or {{else}}
+func (d *delimiters) matchInverse(s string) int {
+	i := d.matchOpen(s)
+	if i == 0 {
+		return 0
+	}
+
+	if (i < len(s)) && (s[i] == '^') {
+		// {{^}}
+		j := i + 1
+		j += matchSpaces(s[j:])
+
+		if n := d.matchClose(s[j:]); n > 0 {
+			return j + n
+		}
+	}
+
+	// {{else}}
+	if j := d.matchInverseChain(s); j > 0 {
+		j += matchSpaces(s[j:])
+
+		if n := d.matchClose(s[j:]); n > 0 {
+			return j + n
+		}
+	}
+
+	return 0
+}
+
+// matchInverseChain matches {{else
+func (d *delimiters) matchInverseChain(s string) int {
+	i := d.matchOpen(s)
+	if i == 0 {
+		return 0
+	}
+
+	i += matchSpaces(s[i:])
+	if !strings.HasPrefix(s[i:], "else") {
+		return 0
+	}
+
+	return i + len("else")
+}
+
+// matchSetDelimiters matches {{=<% %>=}}, and returns new open and close delimiters
+func (d *delimiters) matchSetDelimiters(s string) (int, string, string) {
+	if !strings.HasPrefix(s, d.openSetDelimiters) {
+		return 0, "", ""
+	}
+
+	i := len(d.open) + 1
+	i += matchSpaces(s[i:])
+
+	open := s[i : i+matchDelimiter(s[i:])]
+	i += len(open)
+
+	spaces := matchSpaces(s[i:])
+	i += spaces
+
+	close := s[i : i+matchDelimiter(s[i:])]
+	i += len(close)
+
+	i += matchSpaces(s[i:])
+
+	if (open == "") || (spaces == 0) || (close == "") || !strings.HasPrefix(s[i:], d.closeSetDelimiters) {
+		return 0, "", ""
 	}
-	return loc[0]
+
+	return i + len(d.closeSetDelimiters), open, close
+}
+
+// matchDelimiter matches a delimiter: [^\s=]*
+func matchDelimiter(s string) int {
+	return matchID(s, spaceChars+"=")
 }
 
+// matchKeyword matches given keyword followed by one of given lookahead characters or by close delimiter, and
+// returns the length of keyword
+func (d *delimiters) matchKeyword(s string, keyword string, lookahead string) int {
+	if !strings.HasPrefix(s, keyword) {
+		return 0
+	}
+
+	rest := s[len(keyword):]
+	if (rest != "") && ((strings.IndexByte(lookahead, rest[0]) >= 0) || strings.HasPrefix(rest, d.close)) {
+		return len(keyword)
+	}
+
+	return 0
+}
+
+//
+// Lexer functions
+//
+
 // lexContent scans content (ie: not between mustaches)
 func lexContent(l *Lexer) lexFunc {
-	var next lexFunc
-
 	if l.rawBlock {
-		if i := l.indexRegexp(rOpenEndRawLookAhead); i != -1 {
+		if i := strings.Index(l.input[l.pos:], l.delims.openEndRaw); i != -1 {
 			// {{{{/
 			l.rawBlock = false
 			l.pos += i
 
-			next = lexOpenMustache
-		} else {
-			return l.errorf("Unclosed raw block")
+			l.emitContent()
+
+			return lexOpenMustache
+		}
+
+		return l.errorf("Unclosed raw block")
+	}
+
+	for {
+		if next := lexContentMustache(l); next != nil {
+			// emit scanned content
+			l.emitContent()
+
+			// scan next token
+			return next
+		}
+
+		// scan next rune
+		if l.next() == eof {
+			// emit scanned content
+			l.emitContent()
+
+			// this is over
+			l.emit(TokenEOF)
+			return nil
+		}
+
+		// skip content that can't start a mustache: escapes and open delimiters are always at a rune boundary
+		if i := l.indexContentMustache(); i > 0 {
+			l.pos += i
 		}
-	} else if l.isString(escapedEscapedOpenMustache) {
+	}
+}
+
+// indexContentMustache returns the index from current position of the next character that may start a mustache
+// or an escaped mustache, or -1 if there is none
+func (l *Lexer) indexContentMustache() int {
+	s := l.input[l.pos:]
+
+	i := strings.IndexByte(s, l.delims.open[0])
+	if j := strings.IndexByte(s, '\\'); (j >= 0) && ((i < 0) || (j < i)) {
+		i = j
+	}
+
+	if i < 0 {
+		return len(s)
+	}
+
+	return i
+}
+
+// lexContentMustache returns the lexer function to scan the mustache at current position, or nil if there is none
+func lexContentMustache(l *Lexer) lexFunc {
+	s := l.input[l.pos:]
+
+	switch {
+	case strings.HasPrefix(s, l.delims.escapedEscapedOpen):
 		// \\{{
 
 		// emit content with only one escaped escape
@@ -281,45 +630,29 @@ func lexContent(l *Lexer) lexFunc {
 		l.next()
 		l.ignore()
 
-		next = lexContent
-	} else if l.isString(escapedOpenMustache) {
+		return lexContent
+	case strings.HasPrefix(s, l.delims.escapedOpen):
 		// \{{
-		next = lexEscapedOpenMustache
-	} else if str := l.findRegexp(rOpenCommentDash); str != "" {
+		return lexEscapedOpenMustache
+	case l.delims.matchOpenComment(s, "--"):
 		// {{!--
-		l.closeComment = rCloseCommentDash
+		l.closeComment = "--"
 
-		next = lexComment
-	} else if str := l.findRegexp(rOpenComment); str != "" {
+		return lexComment
+	case l.delims.matchOpenComment(s, ""):
 		// {{!
-		l.closeComment = rCloseComment
+		l.closeComment = ""
 
-		next = lexComment
-	} else if l.isString(openMustache) {
+		return lexComment
+	case strings.HasPrefix(s, l.delims.openSetDelimiters):
+		// {{=
+		return lexSetDelimiters
+	case strings.HasPrefix(s, l.delims.open):
 		// {{
-		next = lexOpenMustache
+		return lexOpenMustache
 	}
 
-	if next != nil {
-		// emit scanned content
-		l.emitContent()
-
-		// scan next token
-		return next
-	}
-
-	// scan next rune
-	if l.next() == eof {
-		// emit scanned content
-		l.emitContent()
-
-		// this is over
-		l.emit(TokenEOF)
-		return nil
-	}
-
-	// continue content scanning
-	return lexContent
+	return nil
 }
 
 // lexEscapedOpenMustache scans \{{
@@ -329,6 +662,7 @@ func lexEscapedOpenMustache(l *Lexer) lexFunc {
 	l.ignore()
 
 	// scan mustaches
+	l.pos += len(l.delims.open)
 	for l.peek() == '{' {
 		l.next()
 	}
@@ -338,43 +672,77 @@ func lexEscapedOpenMustache(l *Lexer) lexFunc {
 
 // lexOpenMustache scans {{
 func lexOpenMustache(l *Lexer) lexFunc {
-	var str string
 	var tok TokenKind
 
 	nextFunc := lexExpression
 
-	if str = l.findRegexp(rOpenEndRaw); str != "" {
+	s := l.input[l.pos:]
+
+	// length of open delimiter, with strip mark
+	n := l.delims.matchOpen(s)
+	if n == 0 {
+		// this is rotten
+		panic("Current pos MUST be an opening mustache")
+	}
+
+	// scanned string
+	str := s[:n]
+
+	// character following open delimiter and strip mark
+	var c byte
+	if n < len(s) {
+		c = s[n]
+	}
+
+	if raw := s[len(l.delims.open):]; strings.HasPrefix(raw, "{{/") {
+		str = s[:len(l.delims.open)+len("{{/")]
 		tok = TokenOpenEndRawBlock
-	} else if str = l.findRegexp(rOpenRaw); str != "" {
+	} else if strings.HasPrefix(raw, "{{") {
+		str = s[:len(l.delims.open)+len("{{")]
 		tok = TokenOpenRawBlock
 		l.rawBlock = true
-	} else if str = l.findRegexp(rOpenUnescaped); str != "" {
+	} else if c == '{' {
+		str = s[:n+1]
 		tok = TokenOpenUnescaped
-	} else if str = l.findRegexp(rOpenBlock); str != "" {
+	} else if c == '#' {
+		str = s[:n+1]
+		if (n+1 < len(s)) && ((s[n+1] == '*') || (s[n+1] == '>')) {
+			str = s[:n+2]
+		}
 		tok = TokenOpenBlock
-	} else if str = l.findRegexp(rOpenEndBlock); str != "" {
+	} else if c == '/' {
+		str = s[:n+1]
 		tok = TokenOpenEndBlock
-	} else if str = l.findRegexp(rOpenPartial); str != "" {
+	} else if c == '>' {
+		str = s[:n+1]
 		tok = TokenOpenPartial
-	} else if str = l.findRegexp(rInverse); str != "" {
+	} else if i := l.delims.matchInverse(s); i > 0 {
+		str = s[:i]
 		tok = TokenInverse
 		nextFunc = lexContent
-	} else if str = l.findRegexp(rOpenInverse); str != "" {
+	} else if c == '^' {
+		str = s[:n+1]
 		tok = TokenOpenInverse
-	} else if str = l.findRegexp(rOpenInverseChain); str != "" {
+	} else if i := l.delims.matchInverseChain(s); i > 0 {
+		str = s[:i]
 		tok = TokenOpenInverseChain
-	} else if str = l.findRegexp(rOpenFunc); str != "" {
+	} else if n == len(s) {
 		tok = TokenOpenBlock
-	} else if str = l.findRegexp(rOpen); str != "" {
+	} else if c == '*' {
+		// decorator params are separated by spaces
+		str = s[:n+1]
 		tok = TokenOpen
-		l.idAllowSpaces = true
 	} else {
-		// this is rotten
-		panic("Current pos MUST be an opening mustache")
+		// {{ or {{&
+		if c == '&' {
+			str = s[:n+1]
+		}
+		tok = TokenOpen
+		l.idAllowSpaces = true
 	}
 
 	l.pos += len(str)
-	l.emit(tok)
+	l.produce(tok, l.delims.canonicalOpen(str))
 
 	return nextFunc
 }
@@ -384,14 +752,19 @@ func lexCloseMustache(l *Lexer) lexFunc {
 	var str string
 	var tok TokenKind
 
-	if str = l.findRegexp(rCloseRaw); str != "" {
+	s := l.input[l.pos:]
+
+	if strings.HasPrefix(s, "}}"+l.delims.close) {
 		// }}}}
+		str = s[:len("}}")+len(l.delims.close)]
 		tok = TokenCloseRawBlock
-	} else if str = l.findRegexp(rCloseUnescaped); str != "" {
+	} else if n := l.delims.matchClose(s[1:]); (s[0] == '}') && (n > 0) {
 		// }}}
+		str = s[:1+n]
 		tok = TokenCloseUnescaped
-	} else if str = l.findRegexp(rClose); str != "" {
+	} else if n := l.delims.matchClose(s); n > 0 {
 		// }}
+		str = s[:n]
 		tok = TokenClose
 	} else {
 		// this is rotten
@@ -399,7 +772,7 @@ func lexCloseMustache(l *Lexer) lexFunc {
 	}
 	l.idAllowSpaces = false
 	l.pos += len(str)
-	l.emit(tok)
+	l.produce(tok, l.delims.canonicalClose(str))
 
 	return lexContent
 }
@@ -407,43 +780,46 @@ func lexCloseMustache(l *Lexer) lexFunc {
 // lexExpression scans inside mustaches
 func lexExpression(l *Lexer) lexFunc {
 	// search close mustache delimiter
-	if l.isString(closeMustache) || l.isString(closeStripMustache) || l.isString(closeUnescapedStripMustache) {
+	if l.isString(l.delims.close) || l.isString(l.delims.closeStrip) || l.isString(l.delims.closeUnescaped) || l.isString(l.delims.closeUnescapedStrip) {
 		return lexCloseMustache
 	}
 
 	// search some patterns before advancing scanning position
+	s := l.input[l.pos:]
 
 	// "as |"
-	if str := l.findRegexp(rOpenBlockParams); str != "" {
-		l.pos += len(str)
-		l.emit(TokenOpenBlockParams)
-		return lexExpression
+	if strings.HasPrefix(s, "as") {
+		if n := matchSpaces(s[len("as"):]); (n > 0) && strings.HasPrefix(s[len("as")+n:], "|") {
+			l.pos += len("as") + n + len("|")
+			l.emit(TokenOpenBlockParams)
+			return lexExpression
+		}
 	}
 
 	// ..
-	if l.isString("..") {
+	if strings.HasPrefix(s, "..") {
 		l.pos += len("..")
 		l.emit(TokenID)
 		return lexExpression
 	}
 
 	// .
-	if str := l.findRegexp(rDotID); str != "" {
-		l.pos += len(".")
+	if n := l.delims.matchKeyword(s, ".", lookheadChars); n > 0 {
+		l.pos += n
 		l.emit(TokenID)
 		return lexExpression
 	}
 
 	// true
-	if str := l.findRegexp(rTrue); str != "" {
-		l.pos += len("true")
+	if n := l.delims.matchKeyword(s, "true", literalLookheadChars); n > 0 {
+		l.pos += n
 		l.emit(TokenBoolean)
 		return lexExpression
 	}
 
 	// false
-	if str := l.findRegexp(rFalse); str != "" {
-		l.pos += len("false")
+	if n := l.delims.matchKeyword(s, "false", literalLookheadChars); n > 0 {
+		l.pos += n
 		l.emit(TokenBoolean)
 		return lexExpression
 	}
@@ -489,18 +865,37 @@ func lexExpression(l *Lexer) lexFunc {
 
 // lexComment scans {{!-- or {{!
 func lexComment(l *Lexer) lexFunc {
-	if str := l.findRegexp(l.closeComment); str != "" {
-		l.pos += len(str)
-		l.emit(TokenComment)
+	for {
+		if n := l.delims.matchCloseComment(l.input[l.pos:], l.closeComment); n > 0 {
+			l.pos += n
 
-		return lexContent
+			l.produce(TokenComment, l.delims.canonicalClose(l.delims.canonicalOpen(l.input[l.start:l.pos])))
+
+			return lexContent
+		}
+
+		if r := l.next(); r == eof {
+			return l.errorf("Unclosed comment")
+		}
 	}
+}
 
-	if r := l.next(); r == eof {
-		return l.errorf("Unclosed comment")
+// lexSetDelimiters scans {{=<% %>=}}
+func lexSetDelimiters(l *Lexer) lexFunc {
+	n, open, close := l.delims.matchSetDelimiters(l.input[l.pos:])
+	if n == 0 {
+		return l.errorf("Invalid set delimiters tag")
 	}
 
-	return lexComment
+	str := l.input[l.pos : l.pos+n]
+	l.pos += n
+
+	// emitted as a comment, as it outputs nothing and can be standalone
+	l.produce(TokenComment, openMustache+"!"+str[len(l.delims.open):len(str)-len(l.delims.close)]+closeMustache)
+
+	l.delims = newDelimiters(open, close)
+
+	return lexContent
 }
 
 // lexIgnorable scans all following ignorable characters
@@ -517,7 +912,7 @@ func lexIgnorable(l *Lexer) lexFunc {
 func lexString(l *Lexer) lexFunc {
 	// get string delimiter
 	delim := l.next()
-	var prev rune
+	escaped := false
 
 	// ignore delimiter
 	l.ignore()
@@ -528,11 +923,13 @@ func lexString(l *Lexer) lexFunc {
 			return l.errorf("Unterminated string")
 		}
 
-		if (r == delim) && (prev != '\\') {
+		if escaped {
+			escaped = false
+		} else if r == '\\' {
+			escaped = true
+		} else if r == delim {
 			break
 		}
-
-		prev = r
 	}
 
 	// remove end delimiter
@@ -609,14 +1006,24 @@ func (l *Lexer) scanNumber() bool {
 
 // lexIdentifier scans an ID
 func lexIdentifier(l *Lexer) lexFunc {
-	idToUse := rID
+	unallowed := unallowedIDChars
 
 	if l.idAllowSpaces {
-		idToUse = rIDAllowSpaces
+		unallowed = unallowedIDCharsAllowSpaces
 		l.idAllowSpaces = false
 	}
 
-	str := l.findRegexp(idToUse)
+	str := l.input[l.pos:]
+	str = str[:matchID(str, unallowed)]
+
+	// an identifier can't contain close delimiter
+	if i := strings.Index(str, l.delims.close); i >= 0 {
+		str = str[:i]
+	}
+
+	// spaces are allowed inside an identifier, but not at its end
+	str = strings.TrimRight(str, " ")
+
 	if len(str) == 0 {
 		// this is rotten
 		panic("Identifier expected")
@@ -2,6 +2,7 @@ package lexer
 
 import (
 	"fmt"
+	"strings"
 	"testing"
 )
 
@@ -12,40 +13,43 @@ type lexTest struct {
 }
 
 // helpers
-func tokContent(val string) Token { return Token{TokenContent, val, 0, 1} }
-func tokID(val string) Token      { return Token{TokenID, val, 0, 1} }
-func tokSep(val string) Token     { return Token{TokenSep, val, 0, 1} }
-func tokString(val string) Token  { return Token{TokenString, val, 0, 1} }
-func tokNumber(val string) Token  { return Token{TokenNumber, val, 0, 1} }
-func tokInverse(val string) Token { return Token{TokenInverse, val, 0, 1} }
-func tokBool(val string) Token    { return Token{TokenBoolean, val, 0, 1} }
-func tokError(val string) Token   { return Token{TokenError, val, 0, 1} }
-func tokComment(val string) Token { return Token{TokenComment, val, 0, 1} }
-
-var tokEOF = Token{TokenEOF, "", 0, 1}
-var tokEquals = Token{TokenEquals, "=", 0, 1}
-var tokData = Token{TokenData, "@", 0, 1}
-var tokOpen = Token{TokenOpen, "{{", 0, 1}
-var tokOpenAmp = Token{TokenOpen, "{{&", 0, 1}
-var tokOpenPartial = Token{TokenOpenPartial, "{{>", 0, 1}
-var tokClose = Token{TokenClose, "}}", 0, 1}
-var tokOpenStrip = Token{TokenOpen, "{{~", 0, 1}
-var tokCloseStrip = Token{TokenClose, "~}}", 0, 1}
-var tokOpenUnescaped = Token{TokenOpenUnescaped, "{{{", 0, 1}
-var tokCloseUnescaped = Token{TokenCloseUnescaped, "}}}", 0, 1}
-var tokOpenUnescapedStrip = Token{TokenOpenUnescaped, "{{~{", 0, 1}
-var tokCloseUnescapedStrip = Token{TokenCloseUnescaped, "}~}}", 0, 1}
-var tokOpenBlock = Token{TokenOpenBlock, "{{#", 0, 1}
-var tokOpenEndBlock = Token{TokenOpenEndBlock, "{{/", 0, 1}
-var tokOpenInverse = Token{TokenOpenInverse, "{{^", 0, 1}
-var tokOpenInverseChain = Token{TokenOpenInverseChain, "{{else", 0, 1}
-var tokOpenSexpr = Token{TokenOpenSexpr, "(", 0, 1}
-var tokCloseSexpr = Token{TokenCloseSexpr, ")", 0, 1}
-var tokOpenBlockParams = Token{TokenOpenBlockParams, "as |", 0, 1}
-var tokCloseBlockParams = Token{TokenCloseBlockParams, "|", 0, 1}
-var tokOpenRawBlock = Token{TokenOpenRawBlock, "{{{{", 0, 1}
-var tokCloseRawBlock = Token{TokenCloseRawBlock, "}}}}", 0, 1}
-var tokOpenEndRawBlock = Token{TokenOpenEndRawBlock, "{{{{/", 0, 1}
+func tokContent(val string) Token { return Token{Kind: TokenContent, Val: val, Line: 1} }
+func tokID(val string) Token      { return Token{Kind: TokenID, Val: val, Line: 1} }
+func tokSep(val string) Token     { return Token{Kind: TokenSep, Val: val, Line: 1} }
+func tokString(val string) Token  { return Token{Kind: TokenString, Val: val, Line: 1} }
+func tokNumber(val string) Token  { return Token{Kind: TokenNumber, Val: val, Line: 1} }
+func tokInverse(val string) Token { return Token{Kind: TokenInverse, Val: val, Line: 1} }
+func tokBool(val string) Token    { return Token{Kind: TokenBoolean, Val: val, Line: 1} }
+func tokError(val string) Token   { return Token{Kind: TokenError, Val: val, Line: 1} }
+func tokComment(val string) Token { return Token{Kind: TokenComment, Val: val, Line: 1} }
+
+var tokEOF = Token{Kind: TokenEOF, Val: "", Line: 1}
+var tokEquals = Token{Kind: TokenEquals, Val: "=", Line: 1}
+var tokData = Token{Kind: TokenData, Val: "@", Line: 1}
+var tokOpen = Token{Kind: TokenOpen, Val: "{{", Line: 1}
+var tokOpenAmp = Token{Kind: TokenOpen, Val: "{{&", Line: 1}
+var tokOpenPartial = Token{Kind: TokenOpenPartial, Val: "{{>", Line: 1}
+var tokClose = Token{Kind: TokenClose, Val: "}}", Line: 1}
+var tokOpenStrip = Token{Kind: TokenOpen, Val: "{{~", Line: 1}
+var tokCloseStrip = Token{Kind: TokenClose, Val: "~}}", Line: 1}
+var tokOpenUnescaped = Token{Kind: TokenOpenUnescaped, Val: "{{{", Line: 1}
+var tokCloseUnescaped = Token{Kind: TokenCloseUnescaped, Val: "}}}", Line: 1}
+var tokOpenUnescapedStrip = Token{Kind: TokenOpenUnescaped, Val: "{{~{", Line: 1}
+var tokCloseUnescapedStrip = Token{Kind: TokenCloseUnescaped, Val: "}~}}", Line: 1}
+var tokOpenBlock = Token{Kind: TokenOpenBlock, Val: "{{#", Line: 1}
+var tokOpenDecorator = Token{Kind: TokenOpen, Val: "{{*", Line: 1}
+var tokOpenDecoratorBlock = Token{Kind: TokenOpenBlock, Val: "{{#*", Line: 1}
+var tokOpenPartialBlock = Token{Kind: TokenOpenBlock, Val: "{{#>", Line: 1}
+var tokOpenEndBlock = Token{Kind: TokenOpenEndBlock, Val: "{{/", Line: 1}
+var tokOpenInverse = Token{Kind: TokenOpenInverse, Val: "{{^", Line: 1}
+var tokOpenInverseChain = Token{Kind: TokenOpenInverseChain, Val: "{{else", Line: 1}
+var tokOpenSexpr = Token{Kind: TokenOpenSexpr, Val: "(", Line: 1}
+var tokCloseSexpr = Token{Kind: TokenCloseSexpr, Val: ")", Line: 1}
+var tokOpenBlockParams = Token{Kind: TokenOpenBlockParams, Val: "as |", Line: 1}
+var tokCloseBlockParams = Token{Kind: TokenCloseBlockParams, Val: "|", Line: 1}
+var tokOpenRawBlock = Token{Kind: TokenOpenRawBlock, Val: "{{{{", Line: 1}
+var tokCloseRawBlock = Token{Kind: TokenCloseRawBlock, Val: "}}}}", Line: 1}
+var tokOpenEndRawBlock = Token{Kind: TokenOpenEndRawBlock, Val: "{{{{/", Line: 1}
 
 var lexTests = []lexTest{
 	{"empty", "", []Token{tokEOF}},
@@ -207,6 +211,11 @@ var lexTests = []lexTest{
 		`{{  foo  }}`,
 		[]Token{tokOpen, tokID("foo"), tokClose, tokEOF},
 	},
+	{
+		`tokenizes a mustache with spaces inside and after an ID as "OPEN ID SEP ID CLOSE"`,
+		`{{ step 1.name  }}`,
+		[]Token{tokOpen, tokID("step 1"), tokSep("."), tokID("name"), tokClose, tokEOF},
+	},
 	{
 		`tokenizes a simple mustache with line breaks as "OPEN ID ID CLOSE"`,
 		"{{  foo  \n   bar }}",
@@ -262,6 +271,21 @@ var lexTests = []lexTest{
 		`{{#foo}}content{{/foo}}`,
 		[]Token{tokOpenBlock, tokID("foo"), tokClose, tokContent("content"), tokOpenEndBlock, tokID("foo"), tokClose, tokEOF},
 	},
+	{
+		`tokenizes decorators as OPEN, ID, ID, CLOSE`,
+		`{{* foo bar}}`,
+		[]Token{tokOpenDecorator, tokID("foo"), tokID("bar"), tokClose, tokEOF},
+	},
+	{
+		`tokenizes decorator blocks as OPEN_BLOCK, ID, STRING, CLOSE ..., OPEN_ENDBLOCK ID CLOSE`,
+		`{{#*inline "foo"}}content{{/inline}}`,
+		[]Token{tokOpenDecoratorBlock, tokID("inline"), tokString("foo"), tokClose, tokContent("content"), tokOpenEndBlock, tokID("inline"), tokClose, tokEOF},
+	},
+	{
+		`tokenizes partial blocks as OPEN_BLOCK, ID, CLOSE ..., OPEN_ENDBLOCK ID CLOSE`,
+		`{{#>foo}}content{{/foo}}`,
+		[]Token{tokOpenPartialBlock, tokID("foo"), tokClose, tokContent("content"), tokOpenEndBlock, tokID("foo"), tokClose, tokEOF},
+	},
 	{
 		`tokenizes inverse sections as "INVERSE"`,
 		`{{^}}`,
@@ -317,6 +341,11 @@ var lexTests = []lexTest{
 		`{{ foo 'bar\'baz' }}`,
 		[]Token{tokOpen, tokID("foo"), tokString(`bar'baz`), tokClose, tokEOF},
 	},
+	{
+		`tokenizes String params ending with an escaped backslash as STRING`,
+		`{{ foo "bar\\" baz "bat\\\"" }}`,
+		[]Token{tokOpen, tokID("foo"), tokString(`bar\\`), tokID("baz"), tokString(`bat\\"`), tokClose, tokEOF},
+	},
 	{
 		`tokenizes numbers`,
 		`{{ foo 1 }}`,
@@ -463,6 +492,21 @@ var lexTests = []lexTest{
 		`{{else foo as |bar baz|}}`,
 		[]Token{tokOpenInverseChain, tokID("foo"), tokOpenBlockParams, tokID("bar"), tokID("baz"), tokCloseBlockParams, tokClose, tokEOF},
 	},
+	{
+		`tokenizes set delimiters tag as a comment`,
+		`{{=<% %>=}}<%#foo%>{{bar}}<%/foo%>`,
+		[]Token{tokComment("{{!=<% %>=}}"), tokOpenBlock, tokID("foo"), tokClose, tokContent("{{bar}}"), tokOpenEndBlock, tokID("foo"), tokClose, tokEOF},
+	},
+	{
+		`tokenizes set delimiters tag with spaces`,
+		`{{= | | =}}|{text}|`,
+		[]Token{tokComment("{{!= | | =}}"), tokOpenUnescaped, tokID("text"), tokCloseUnescaped, tokEOF},
+	},
+	{
+		`does not tokenize invalid set delimiters tag`,
+		`{{=<%=}}`,
+		[]Token{tokError("Invalid set delimiters tag")},
+	},
 }
 
 func collect(t *lexTest) []Token {
@@ -514,6 +558,112 @@ func TestLexer(t *testing.T) {
 	}
 }
 
+func TestLexerPositions(t *testing.T) {
+	t.Parallel()
+
+	input := "héllo\n{{#foo\n  'bär' }}"
+	expected := []string{
+		`Content{"héllo\n"} 0-7 1:1-2:1`,
+		`OpenBlock{"{{#"} 7-10 2:1-2:4`,
+		`ID{"foo"} 10-13 2:4-2:7`,
+		`String{"bär"} 17-21 3:4-3:7`,
+		`Close{"}}"} 23-25 3:9-3:11`,
+		`EOF 25-25 3:11-3:11`,
+	}
+
+	var output []string
+	for _, tok := range Collect(input) {
+		output = append(output, fmt.Sprintf("%s %d-%d %d:%d-%d:%d", tok, tok.Pos, tok.End, tok.Line, tok.Column, tok.EndLine, tok.EndColumn))
+	}
+
+	if fmt.Sprint(output) != fmt.Sprint(expected) {
+		t.Errorf("Erroneous token positions\nexpected\n\t%q\ngot\n\t%q", expected, output)
+	}
+}
+
+func TestScanTolerant(t *testing.T) {
+	t.Parallel()
+
+	input := "a {{foo}b {{bar}} {{!-- c"
+	expected := []Token{tokContent("a "), tokOpen, tokID("foo"), tokError("Unexpected character in expression: '}'"), tokOpen, tokID("bar"), tokClose, tokContent(" "), tokError("Unclosed comment"), tokEOF}
+
+	var tokens []Token
+
+	l := ScanTolerant(input)
+	for {
+		token := l.NextToken()
+		tokens = append(tokens, token)
+
+		if token.Kind == TokenEOF {
+			break
+		}
+	}
+
+	if !equal(tokens, expected, false) {
+		t.Errorf("Test failed\ninput:\n\t'%s'\nexpected\n\t%v\ngot\n\t%+v\n", input, expected, tokens)
+	}
+}
+
+func TestScanWithDelimiters(t *testing.T) {
+	t.Parallel()
+
+	input := "<%#foo .%>{{bar}}<%/foo%> <%! baz %><%={{ }}=%>{{{qux}}}"
+	expected := []Token{tokOpenBlock, tokID("foo"), tokID("."), tokClose, tokContent("{{bar}}"), tokOpenEndBlock, tokID("foo"), tokClose, tokContent(" "), tokComment("{{! baz }}"), tokComment("{{!={{ }}=}}"), tokOpenUnescaped, tokID("qux"), tokCloseUnescaped, tokEOF}
+
+	var tokens []Token
+
+	l := ScanWithDelimiters(input, "<%", "%>")
+	for {
+		token := l.NextToken()
+		tokens = append(tokens, token)
+
+		if token.Kind == TokenEOF || token.Kind == TokenError {
+			break
+		}
+	}
+
+	if !equal(tokens, expected, false) {
+		t.Errorf("Test failed\ninput:\n\t'%s'\nexpected\n\t%v\ngot\n\t%+v\n", input, expected, tokens)
+	}
+
+	if token := ScanWithDelimiters("foo", "<% ", "%>").NextToken(); token.Kind != TokenError {
+		t.Errorf("Expected an error with invalid delimiters, got %v", token)
+	}
+}
+
+func TestNextTokenAfterEnd(t *testing.T) {
+	t.Parallel()
+
+	for input, kind := range map[string]TokenKind{"foo": TokenEOF, "{{foo": TokenError} {
+		l := Scan(input)
+		for token := l.NextToken(); (token.Kind != TokenEOF) && (token.Kind != TokenError); token = l.NextToken() {
+		}
+
+		// last token is returned again
+		for i := 0; i < 2; i++ {
+			if token := l.NextToken(); token.Kind != kind {
+				t.Errorf("Test failed\ninput:\n\t'%s'\nexpected\n\t%s\ngot\n\t%v\n", input, kind, token)
+			}
+		}
+	}
+}
+
+func BenchmarkScan(b *testing.B) {
+	input := strings.Repeat(`<div class="entry {{#if active}}active{{/if}}">
+  <h1>{{title}}</h1>
+  {{#each comments as |c i|}}{{! comment }}<p>{{c.author.name}} - {{{c.body}}}</p>{{else}}none{{/each}}
+  {{> footer year=2016}}
+</div>
+`, 20)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		l := Scan(input)
+		for token := l.NextToken(); token.Kind != TokenEOF; token = l.NextToken() {
+		}
+	}
+}
+
 // @todo Test errors:
 //   `{{{{raw foo`
 
@@ -114,10 +114,15 @@ type TokenKind int
 // Token represents a scanned token.
 type Token struct {
 	Kind TokenKind // Token kind
-	Val  string    // Token value
+	Val  string    // Token value, with default delimiters for mustaches and comments
 
-	Pos  int // Byte position in input string
-	Line int // Line number in input string
+	Pos    int // Byte position in input string
+	Line   int // Line number in input string
+	Column int // Column number in input string, in runes
+
+	End       int // Byte position following the token in input string
+	EndLine   int // Line number of End position
+	EndColumn int // Column number of End position, in runes
 }
 
 // tokenName permits to display token name given token type
@@ -0,0 +1,391 @@
+package raymond
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/komand/raymond/ast"
+)
+
+// LintSeverity represents the severity of a lint finding.
+type LintSeverity int
+
+const (
+	// LintError is the severity of findings that most probably break template rendering or safety
+	LintError LintSeverity = iota
+
+	// LintWarning is the severity of findings that may be intended
+	LintWarning
+)
+
+// LintFinding represents an issue found in a template by a lint rule.
+type LintFinding struct {
+	ErrorLocation
+
+	// Rule is the name of rule that reported that finding
+	Rule string
+
+	Severity LintSeverity
+	Message  string
+}
+
+// LintRule checks templates nodes.
+//
+// Rules are called on every node of a template, in source order. They report findings with LintContext.Report().
+type LintRule interface {
+	// Name returns the rule name, used in findings
+	Name() string
+
+	// Check checks given node
+	Check(ctx *LintContext, node ast.Node)
+}
+
+// LintContext holds the template being linted, and collects findings.
+type LintContext struct {
+	tpl  *Template
+	html bool
+
+	// rule being checked
+	rule LintRule
+
+	// expressions of decorators and partial blocks, that are not helper calls
+	notCalls map[*ast.Expression]bool
+
+	findings []LintFinding
+}
+
+// lintRule is a lint rule implemented by a function
+type lintRule struct {
+	name  string
+	check func(ctx *LintContext, node ast.Node)
+}
+
+// htmlTag matches an opening or closing HTML tag
+var htmlTag = regexp.MustCompile(`</?[a-zA-Z][a-zA-Z0-9-]*[\s/>]`)
+
+var (
+	// UnknownHelperRule reports helpers calls to helpers that are not registered, ie. expressions with
+	// parameters or hash arguments, and sub-expressions.
+	UnknownHelperRule = NewLintRule("unknown-helper", checkUnknownHelper)
+
+	// UnusedBlockParamRule reports block parameters that are not used in their block.
+	UnusedBlockParamRule = NewLintRule("unused-block-param", checkUnusedBlockParam)
+
+	// TripleStashRule reports unescaped mustaches, eg. {{{foo}}} or {{&foo}}, in HTML templates.
+	TripleStashRule = NewLintRule("triple-stash", checkTripleStash)
+
+	// AttributeTripleStashRule reports unescaped mustaches inside HTML attribute values, eg. <a href="{{{url}}}">.
+	AttributeTripleStashRule = NewLintRule("attribute-triple-stash", checkAttributeTripleStash)
+
+	// HelperShadowingRule reports mustaches without parameters that call a helper, when a context value with the
+	// same name was probably intended: helpers always take precedence over context values.
+	HelperShadowingRule = NewLintRule("helper-shadowing", checkHelperShadowing)
+
+	// EmptyBlockRule reports blocks without content.
+	EmptyBlockRule = NewLintRule("empty-block", checkEmptyBlock)
+)
+
+// DefaultLintRules returns the rules used by Template.Lint() when no rule is given.
+func DefaultLintRules() []LintRule {
+	return []LintRule{
+		UnknownHelperRule,
+		UnusedBlockParamRule,
+		TripleStashRule,
+		AttributeTripleStashRule,
+		HelperShadowingRule,
+		EmptyBlockRule,
+	}
+}
+
+// NewLintRule instanciates a lint rule with given name and check function.
+func NewLintRule(name string, check func(ctx *LintContext, node ast.Node)) LintRule {
+	return &lintRule{name: name, check: check}
+}
+
+// DeprecatedHelperRule returns a lint rule that reports calls to given deprecated helpers.
+//
+// The helpers map keys are helpers names, and values are hints appended to findings messages, like the name of
+// the helper to use instead. Hints can be empty.
+func DeprecatedHelperRule(helpers map[string]string) LintRule {
+	return NewLintRule("deprecated-helper", func(ctx *LintContext, node ast.Node) {
+		expr, ok := node.(*ast.Expression)
+		if !ok || ctx.notCalls[expr] {
+			return
+		}
+
+		name := expr.HelperName()
+
+		hint, deprecated := helpers[name]
+		if !deprecated || !(ctx.IsHelper(name) || isHelperCall(expr)) {
+			return
+		}
+
+		if hint != "" {
+			ctx.Report(expr, LintWarning, "Helper '%s' is deprecated: %s", name, hint)
+		} else {
+			ctx.Report(expr, LintWarning, "Helper '%s' is deprecated", name)
+		}
+	})
+}
+
+// Lint checks template with given rules, or with DefaultLintRules() if no rule is given. Findings are sorted
+// by position in template.
+//
+// An error is returned if template can't be parsed.
+func (tpl *Template) Lint(rules ...LintRule) ([]LintFinding, error) {
+	if err := tpl.parse(); err != nil {
+		return nil, err
+	}
+
+	if len(rules) == 0 {
+		rules = DefaultLintRules()
+	}
+
+	ctx := &LintContext{tpl: tpl, notCalls: make(map[*ast.Expression]bool)}
+
+	ast.Walk(tpl.program, func(node ast.Node) {
+		switch n := node.(type) {
+		case *ast.ContentStatement:
+			if !tpl.unescaped && htmlTag.MatchString(n.Original) {
+				ctx.html = true
+			}
+		case *ast.Decorator:
+			ctx.notCalls[n.Expression] = true
+		case *ast.DecoratorBlock:
+			ctx.notCalls[n.Expression] = true
+		case *ast.PartialBlock:
+			ctx.notCalls[n.Expression] = true
+		}
+	})
+
+	ast.Walk(tpl.program, func(node ast.Node) {
+		for _, rule := range rules {
+			ctx.rule = rule
+			rule.Check(ctx, node)
+		}
+	})
+
+	sort.SliceStable(ctx.findings, func(i, j int) bool {
+		return ctx.findings[i].Offset < ctx.findings[j].Offset
+	})
+
+	return ctx.findings, nil
+}
+
+// Template returns the template being linted.
+func (ctx *LintContext) Template() *Template {
+	return ctx.tpl
+}
+
+// Source returns the source of template being linted.
+func (ctx *LintContext) Source() string {
+	return ctx.tpl.source
+}
+
+// IsHelper returns true if a helper with given name is registered, for that template or globally.
+func (ctx *LintContext) IsHelper(name string) bool {
+	return (ctx.tpl.findHelper(name) != zero) || (findHelper(name) != zero)
+}
+
+// IsHTML returns true if template outputs HTML, ie. if it is not unescaped and its content holds HTML tags.
+func (ctx *LintContext) IsHTML() bool {
+	return ctx.html
+}
+
+// Report adds a finding for the rule being checked, at given node.
+func (ctx *LintContext) Report(node ast.Node, severity LintSeverity, format string, args ...interface{}) {
+	ctx.findings = append(ctx.findings, LintFinding{
+		ErrorLocation: newErrorLocation(ctx.tpl, node),
+		Rule:          ctx.rule.Name(),
+		Severity:      severity,
+		Message:       fmt.Sprintf(format, args...),
+	})
+}
+
+// Name implements the LintRule interface.
+func (rule *lintRule) Name() string {
+	return rule.name
+}
+
+// Check implements the LintRule interface.
+func (rule *lintRule) Check(ctx *LintContext, node ast.Node) {
+	rule.check(ctx, node)
+}
+
+// String returns the string representation of severity.
+func (severity LintSeverity) String() string {
+	switch severity {
+	case LintError:
+		return "error"
+	case LintWarning:
+		return "warning"
+	}
+
+	return "unknown"
+}
+
+// MarshalText implements the encoding.TextMarshaler interface.
+func (severity LintSeverity) MarshalText() ([]byte, error) {
+	return []byte(severity.String()), nil
+}
+
+// String returns the string representation of finding.
+func (finding LintFinding) String() string {
+	return fmt.Sprintf("%s on %s: %s (%s)", finding.Severity, finding.ErrorLocation, finding.Message, finding.Rule)
+}
+
+//
+// Rules
+//
+
+// isHelperCall returns true if given expression can only be a helper call, because it has parameters or hash arguments
+func isHelperCall(expr *ast.Expression) bool {
+	return (len(expr.Params) > 0) || (expr.Hash != nil)
+}
+
+// checkUnknownHelper implements the UnknownHelperRule
+func checkUnknownHelper(ctx *LintContext, node ast.Node) {
+	var expr *ast.Expression
+
+	switch n := node.(type) {
+	case *ast.Expression:
+		if isHelperCall(n) && !ctx.notCalls[n] {
+			expr = n
+		}
+	case *ast.SubExpression:
+		if !isHelperCall(n.Expression) {
+			// sub-expression with parameters is checked as an expression
+			expr = n.Expression
+		}
+	}
+
+	if expr == nil {
+		return
+	}
+
+	if name := expr.HelperName(); (name != "") && !ctx.IsHelper(name) {
+		ctx.Report(expr, LintError, "Unknown helper '%s'", name)
+	}
+}
+
+// checkUnusedBlockParam implements the UnusedBlockParamRule
+func checkUnusedBlockParam(ctx *LintContext, node ast.Node) {
+	block, ok := node.(*ast.BlockStatement)
+	if !ok || (block.Program == nil) {
+		return
+	}
+
+	for _, param := range block.Program.BlockParams {
+		used := false
+
+		ast.Walk(block.Program, func(n ast.Node) {
+			if path, ok := n.(*ast.PathExpression); ok && !path.Data && !path.Scoped && (path.Depth == 0) && (len(path.Parts) > 0) && (path.Parts[0] == param) {
+				used = true
+			}
+		})
+
+		if !used {
+			ctx.Report(block, LintWarning, "Block param '%s' is never used", param)
+		}
+	}
+}
+
+// checkTripleStash implements the TripleStashRule
+func checkTripleStash(ctx *LintContext, node ast.Node) {
+	mustache, ok := node.(*ast.MustacheStatement)
+	if !ok || !mustache.Unescaped || !ctx.IsHTML() {
+		return
+	}
+
+	// reported by AttributeTripleStashRule
+	if inAttribute(ctx.Source(), mustache.Pos) {
+		return
+	}
+
+	ctx.Report(mustache, LintWarning, "Unescaped mustache outputs raw HTML")
+}
+
+// checkAttributeTripleStash implements the AttributeTripleStashRule
+func checkAttributeTripleStash(ctx *LintContext, node ast.Node) {
+	mustache, ok := node.(*ast.MustacheStatement)
+	if !ok || !mustache.Unescaped || ctx.tpl.unescaped {
+		return
+	}
+
+	if inAttribute(ctx.Source(), mustache.Pos) {
+		ctx.Report(mustache, LintError, "Unescaped mustache in HTML attribute value")
+	}
+}
+
+// inAttribute returns true if given position of source is inside an HTML attribute value
+func inAttribute(source string, pos int) bool {
+	before := source[:pos]
+
+	start := strings.LastIndexAny(before, "<>")
+	if (start == -1) || (before[start] == '>') {
+		// not in a tag
+		return false
+	}
+
+	var quote byte
+
+	tag := before[start:]
+	for i := 0; i < len(tag); i++ {
+		switch {
+		case quote != 0:
+			if tag[i] == quote {
+				quote = 0
+			}
+		case (tag[i] == '"') || (tag[i] == '\''):
+			quote = tag[i]
+		}
+	}
+
+	// quoted or unquoted value
+	return (quote != 0) || strings.HasSuffix(strings.TrimRight(tag, " \t\r\n"), "=")
+}
+
+// checkHelperShadowing implements the HelperShadowingRule
+func checkHelperShadowing(ctx *LintContext, node ast.Node) {
+	mustache, ok := node.(*ast.MustacheStatement)
+	if !ok || isHelperCall(mustache.Expression) {
+		return
+	}
+
+	if name := mustache.Expression.HelperName(); (name != "") && ctx.IsHelper(name) {
+		ctx.Report(mustache.Expression, LintWarning, "Path '%s' is shadowed by helper '%s', use {{this.%s}} to output context value", name, name, name)
+	}
+}
+
+// checkEmptyBlock implements the EmptyBlockRule
+func checkEmptyBlock(ctx *LintContext, node ast.Node) {
+	block, ok := node.(*ast.BlockStatement)
+	if !ok || !isEmptyProgram(block.Program) || !isEmptyProgram(block.Inverse) {
+		return
+	}
+
+	ctx.Report(block, LintWarning, "Empty '%s' block", block.Expression.Canonical())
+}
+
+// isEmptyProgram returns true if given program is nil, or only holds whitespaces and comments
+func isEmptyProgram(program *ast.Program) bool {
+	if program == nil {
+		return true
+	}
+
+	for _, node := range program.Body {
+		switch n := node.(type) {
+		case *ast.ContentStatement:
+			if strings.TrimSpace(n.Original) != "" {
+				return false
+			}
+		case *ast.CommentStatement:
+		default:
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,143 @@
+package raymond
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/komand/raymond/ast"
+)
+
+var lintTests = []struct {
+	name   string
+	input  string
+	output []string
+}{
+	{
+		"valid template",
+		"<p>{{title}}</p>{{#each users as |user i|}}{{i}}: {{user.name}}{{/each}}{{#if (upcase title)}}x{{/if}}",
+		nil,
+	},
+	{
+		"unknown helpers",
+		"{{{shout title}}} {{#if (lower title)}}x{{/if}} {{#each (sort users by=name)}}x{{/each}}",
+		[]string{
+			"1:4 error unknown-helper: Unknown helper 'shout'",
+			"1:26 error unknown-helper: Unknown helper 'lower'",
+			"1:58 error unknown-helper: Unknown helper 'sort'",
+		},
+	},
+	{
+		"decorators and partial blocks are not helper calls",
+		`{{* locale lang}}{{#*inline "row"}}{{name}}{{/inline}}{{#> layout title=name}}{{> row}}{{/layout}}`,
+		nil,
+	},
+	{
+		"unused block params",
+		"{{#each users as |user i|}}{{user.name}}{{/each}}{{#each users as |u|}}{{this.u}}{{/each}}",
+		[]string{
+			"1:1 warning unused-block-param: Block param 'i' is never used",
+			"1:50 warning unused-block-param: Block param 'u' is never used",
+		},
+	},
+	{
+		"triple-stash",
+		"<div>{{{body}}}</div><a href=\"{{url}}\" title=\"{{{title}}}\" class={{{klass}}}>{{&text}}</a>",
+		[]string{
+			"1:6 warning triple-stash: Unescaped mustache outputs raw HTML",
+			"1:47 error attribute-triple-stash: Unescaped mustache in HTML attribute value",
+			"1:66 error attribute-triple-stash: Unescaped mustache in HTML attribute value",
+			"1:78 warning triple-stash: Unescaped mustache outputs raw HTML",
+		},
+	},
+	{
+		"triple-stash in text template",
+		"Hello {{{name}}} > {{{body}}}",
+		nil,
+	},
+	{
+		"helper shadowing",
+		"{{upcase}} {{this.upcase}} {{upcase title}} {{#upcase}}x{{/upcase}}",
+		[]string{
+			"1:3 warning helper-shadowing: Path 'upcase' is shadowed by helper 'upcase', use {{this.upcase}} to output context value",
+		},
+	},
+	{
+		"empty blocks",
+		"{{#if a}} {{! nothing }}\n{{/if}}{{#if b}}{{else}}x{{/if}}{{#each c}}{{else}} {{/each}}",
+		[]string{
+			"1:1 warning empty-block: Empty 'if' block",
+			"2:33 warning empty-block: Empty 'each' block",
+		},
+	},
+}
+
+func lintOutput(findings []LintFinding) []string {
+	var result []string
+	for _, f := range findings {
+		result = append(result, fmt.Sprintf("%d:%d %s %s: %s", f.Line, f.Column, f.Severity, f.Rule, f.Message))
+	}
+	return result
+}
+
+func TestLint(t *testing.T) {
+	t.Parallel()
+
+	for _, test := range lintTests {
+		tpl := MustParse(test.input)
+		tpl.RegisterHelper("upcase", func(s string) string { return s })
+
+		findings, err := tpl.Lint()
+		if err != nil {
+			t.Fatalf("Test '%s' failed: %s", test.name, err)
+		}
+
+		if output := lintOutput(findings); fmt.Sprint(output) != fmt.Sprint(test.output) {
+			t.Errorf("Test '%s' failed\ninput:\n\t%s\nexpected\n\t%q\ngot\n\t%q", test.name, test.input, test.output, output)
+		}
+	}
+}
+
+func TestLintRules(t *testing.T) {
+	t.Parallel()
+
+	tpl := MustParse("{{{shout title}}} {{#if (old title)}}{{{link url}}}{{/if}}")
+	tpl.RegisterHelper("link", func(s string) string { return s })
+
+	noLinks := NewLintRule("no-link", func(ctx *LintContext, node ast.Node) {
+		if expr, ok := node.(*ast.Expression); ok && (expr.HelperName() == "link") {
+			ctx.Report(node, LintError, "Links are forbidden")
+		}
+	})
+
+	deprecated := DeprecatedHelperRule(map[string]string{"old": "use 'new' instead", "link": ""})
+
+	findings, err := tpl.Lint(noLinks, deprecated)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{
+		"1:26 warning deprecated-helper: Helper 'old' is deprecated: use 'new' instead",
+		"1:41 error no-link: Links are forbidden",
+		"1:41 warning deprecated-helper: Helper 'link' is deprecated",
+	}
+
+	if output := lintOutput(findings); fmt.Sprint(output) != fmt.Sprint(expected) {
+		t.Errorf("Erroneous findings\nexpected\n\t%q\ngot\n\t%q", expected, output)
+	}
+}
+
+func ExampleTemplate_Lint() {
+	tpl := MustParse(`<a href="{{{url}}}">{{#each items as |item|}}{{name}}{{/each}}</a>`)
+
+	findings, err := tpl.Lint()
+	if err != nil {
+		panic(err)
+	}
+
+	for _, finding := range findings {
+		fmt.Println(finding)
+	}
+	// Output: error on line 1, column 10: Unescaped mustache in HTML attribute value (attribute-triple-stash)
+	// warning on line 1, column 21: Block param 'item' is never used (unused-block-param)
+}
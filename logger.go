@@ -0,0 +1,148 @@
+package raymond
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/komand/raymond/ast"
+)
+
+// Log levels supported by the log helper, with the level= hash argument or the @level private variable.
+const (
+	LogLevelDebug = "debug"
+	LogLevelInfo  = "info"
+	LogLevelWarn  = "warn"
+	LogLevelError = "error"
+)
+
+// logLevels are the log levels, by increasing severity
+var logLevels = []string{LogLevelDebug, LogLevelInfo, LogLevelWarn, LogLevelError}
+
+// Logger receives the messages of the log helper, see Template.SetLogger() and DataFrame.SetLogger().
+type Logger interface {
+	Log(entry LogEntry)
+}
+
+// LogEntry is a message of the log helper.
+type LogEntry struct {
+	// Level is the log level: "debug", "info", "warn" or "error"
+	Level string
+
+	// Message is the log helper arguments, separated by spaces
+	Message string
+
+	// Args are the log helper arguments
+	Args []interface{}
+
+	// Location is the location of the log helper call
+	Location ErrorLocation
+}
+
+// stdLogger is a Logger writing to a log.Logger
+type stdLogger struct {
+	logger *log.Logger
+	level  string
+}
+
+// NewStdLogger returns a Logger writing messages of given level or above to given log.Logger, as key=value pairs
+// like the slog text handler does: level, message, then template name, line and column. A nil log.Logger writes to
+// the standard logger, and NewStdLogger(nil, LogLevelInfo) is the default logger of templates.
+func NewStdLogger(logger *log.Logger, level string) Logger {
+	return stdLogger{logger, level}
+}
+
+// Log implements Logger
+func (l stdLogger) Log(entry LogEntry) {
+	rank := logLevelRank(entry.Level)
+	if rank < logLevelRank(l.level) {
+		return
+	}
+
+	logger := l.logger
+	if logger == nil {
+		logger = log.Default()
+	}
+
+	var b strings.Builder
+
+	b.WriteString("level=")
+	b.WriteString(strings.ToUpper(logLevels[rank]))
+	b.WriteString(" msg=")
+	b.WriteString(logValue(entry.Message))
+
+	if entry.Location.Template != "" {
+		b.WriteString(" template=")
+		b.WriteString(logValue(entry.Location.Template))
+	}
+
+	fmt.Fprintf(&b, " line=%d column=%d", entry.Location.Line, entry.Location.Column)
+
+	logger.Print(b.String())
+}
+
+// logValue returns given value, quoted if it is empty or contains spaces, quotes, equal signs or non printable
+// characters
+func logValue(value string) string {
+	quote := strings.IndexFunc(value, func(r rune) bool {
+		return unicode.IsSpace(r) || (r == '"') || (r == '=') || !unicode.IsPrint(r)
+	})
+
+	if (value == "") || (quote >= 0) {
+		return strconv.Quote(value)
+	}
+
+	return value
+}
+
+// logLevelRank returns the index of given log level in logLevels, unknown levels are info
+func logLevelRank(level string) int {
+	for i, l := range logLevels {
+		if l == level {
+			return i
+		}
+	}
+
+	return 1
+}
+
+// logLevel returns the log level of given level= hash argument or @level private variable value, as handlebars.js
+// it can be a level name or a level index
+func logLevel(value interface{}) string {
+	if str, ok := value.(string); ok {
+		return strings.ToLower(str)
+	}
+
+	if i, err := strconv.Atoi(Str(value)); (err == nil) && (i >= 0) && (i < len(logLevels)) {
+		return logLevels[i]
+	}
+
+	return LogLevelInfo
+}
+
+// log sends given log helper arguments to the execution or template logger
+func (v *evalVisitor) log(level string, args []interface{}) {
+	strs := make([]string, len(args))
+	for i, arg := range args {
+		strs[i] = v.str(arg)
+	}
+
+	var node ast.Node = v.curNode
+	if expr := v.curExpr(); expr != nil {
+		node = expr
+	}
+
+	logger := v.logger
+	if logger == nil {
+		logger = NewStdLogger(nil, LogLevelInfo)
+	}
+
+	logger.Log(LogEntry{
+		Level:    level,
+		Message:  strings.Join(strs, " "),
+		Args:     args,
+		Location: newErrorLocation(v.curTpl, node),
+	})
+}
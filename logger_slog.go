@@ -0,0 +1,51 @@
+//go:build go1.21
+
+package raymond
+
+import (
+	"context"
+	"log/slog"
+)
+
+// slogLogger is a Logger writing to a slog.Logger
+type slogLogger struct {
+	logger *slog.Logger
+}
+
+// NewSlogLogger returns a Logger writing to given slog.Logger, with the template name, line and column as
+// attributes. A nil slog.Logger writes to slog.Default(). It requires Go 1.21.
+func NewSlogLogger(logger *slog.Logger) Logger {
+	return slogLogger{logger}
+}
+
+// Log implements Logger
+func (l slogLogger) Log(entry LogEntry) {
+	logger := l.logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+
+	var attrs []slog.Attr
+
+	if entry.Location.Template != "" {
+		attrs = append(attrs, slog.String("template", entry.Location.Template))
+	}
+
+	attrs = append(attrs, slog.Int("line", entry.Location.Line), slog.Int("column", entry.Location.Column))
+
+	logger.LogAttrs(context.Background(), slogLevel(entry.Level), entry.Message, attrs...)
+}
+
+// slogLevel returns the slog.Level of given log level
+func slogLevel(level string) slog.Level {
+	switch level {
+	case LogLevelDebug:
+		return slog.LevelDebug
+	case LogLevelWarn:
+		return slog.LevelWarn
+	case LogLevelError:
+		return slog.LevelError
+	}
+
+	return slog.LevelInfo
+}
@@ -0,0 +1,35 @@
+//go:build go1.21
+
+package raymond
+
+import (
+	"bytes"
+	"log/slog"
+	"testing"
+)
+
+func TestSlogLogger(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+
+	handler := slog.NewTextHandler(&buf, &slog.HandlerOptions{
+		Level: slog.LevelInfo,
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			if a.Key == slog.TimeKey {
+				return slog.Attr{}
+			}
+			return a
+		},
+	})
+
+	tpl := MustParse(`{{{log "hello" name}}}{{{log "hidden" level="debug"}}}{{{log "oops" level="error"}}}`)
+
+	tpl.SetLogger(NewSlogLogger(slog.New(handler)))
+	tpl.MustExec(map[string]string{"name": "world"})
+
+	expected := "level=INFO msg=\"hello world\" line=1 column=4\nlevel=ERROR msg=oops line=1 column=58\n"
+	if buf.String() != expected {
+		t.Errorf("Unexpected slog output:\n%s", buf.String())
+	}
+}
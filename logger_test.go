@@ -0,0 +1,142 @@
+package raymond
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"strings"
+	"testing"
+)
+
+// recordLogger records log entries
+type recordLogger struct {
+	entries []string
+}
+
+func (l *recordLogger) Log(entry LogEntry) {
+	l.entries = append(l.entries, fmt.Sprintf("%s %q %d %s", entry.Level, entry.Message, len(entry.Args), entry.Location))
+}
+
+func TestLogHelper(t *testing.T) {
+	t.Parallel()
+
+	tpl := MustParse("{{{log \"x\" 3 blah}}}\n{{{log \"whee\" level=\"warn\"}}}{{{log \"\" level=3}}}{{@level}}")
+
+	logger := &recordLogger{}
+	tpl.SetLogger(logger)
+
+	if output := tpl.MustExec(map[string]string{"blah": "whee"}); output != "\ninfo" {
+		t.Errorf("Unexpected output: %q", output)
+	}
+
+	expected := []string{
+		`info "x 3 whee" 3 line 1, column 4`,
+		`warn "whee" 1 line 2, column 4`,
+		`error "" 1 line 2, column 33`,
+	}
+
+	if strings.Join(logger.entries, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("Unexpected log entries:\n%s", strings.Join(logger.entries, "\n"))
+	}
+
+	// @level private variable
+	logger.entries = nil
+
+	data := NewDataFrame()
+	data.Set("level", "debug")
+
+	tpl = MustParse(`{{> part}}{{@level}}`)
+	tpl.RegisterPartial("part", `{{{log "in partial"}}}`)
+	tpl.SetLogger(logger)
+
+	if output, err := tpl.ExecWith(nil, data); (err != nil) || (output != "debug") {
+		t.Errorf("Unexpected output: %q, %v", output, err)
+	}
+
+	if (len(logger.entries) != 1) || (logger.entries[0] != `debug "in partial" 1 line 1, column 4 of template 'part'`) {
+		t.Errorf("Unexpected log entries: %q", logger.entries)
+	}
+
+	// default @level, given data frame is not modified
+	data = NewDataFrame()
+
+	if output, err := tpl.ExecWith(nil, data); (err != nil) || (output != "info") || (data.Get("level") != nil) {
+		t.Errorf("Unexpected output: %q, %v, %v", output, err, data.Get("level"))
+	}
+}
+
+func TestExecutionLogger(t *testing.T) {
+	t.Parallel()
+
+	tpl := MustParse(`{{{log "hello"}}}{{> part}}`)
+	tpl.RegisterPartial("part", `{{{log "in partial"}}}`)
+
+	tplLogger := &recordLogger{}
+	tpl.SetLogger(tplLogger)
+
+	// execution logger takes precedence over template logger
+	execLogger := &recordLogger{}
+
+	data := NewDataFrame()
+	data.SetLogger(execLogger)
+
+	if _, err := tpl.ExecWith(nil, data); err != nil {
+		t.Fatal(err)
+	}
+
+	if (len(tplLogger.entries) != 0) || (len(execLogger.entries) != 2) {
+		t.Errorf("Unexpected log entries: %q %q", tplLogger.entries, execLogger.entries)
+	}
+
+	// template logger is used by other executions
+	tpl.MustExec(nil)
+
+	if (len(tplLogger.entries) != 2) || (len(execLogger.entries) != 2) {
+		t.Errorf("Unexpected log entries: %q %q", tplLogger.entries, execLogger.entries)
+	}
+}
+
+func TestStdLogger(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+
+	tpl := MustParse(`{{{log "hello" name}}}{{{log "hidden" level="debug"}}}{{{log "oops" level="error"}}}`)
+
+	tpl.SetLogger(NewStdLogger(log.New(&buf, "", 0), LogLevelInfo))
+	tpl.MustExec(map[string]string{"name": "world"})
+
+	expected := "level=INFO msg=\"hello world\" line=1 column=4\nlevel=ERROR msg=oops line=1 column=58\n"
+	if buf.String() != expected {
+		t.Errorf("Unexpected log output:\n%s", buf.String())
+	}
+}
+
+func TestVariadicHelper(t *testing.T) {
+	t.Parallel()
+
+	tpl := MustParse(`{{{join "-" "a" "b" 1}}} {{{join "+"}}} {{{count "a" true 2}}}`)
+	tpl.RegisterHelper("join", func(sep string, strs ...string) string {
+		return strings.Join(strs, sep)
+	})
+	tpl.RegisterHelper("count", func(args ...interface{}) string {
+		options := args[len(args)-1].(*Options)
+		return fmt.Sprintf("%d/%d", len(args)-1, len(options.Params()))
+	})
+
+	if output := tpl.MustExec(nil); output != "a-b-1  3/3" {
+		t.Errorf("Unexpected output: %q", output)
+	}
+
+	if _, err := MustParse(`{{join}}`).Exec(nil); err != nil {
+		// join is not registered on that template: evaluated as missing field
+		t.Errorf("Unexpected error: %s", err)
+	}
+
+	tpl = MustParse(`{{join}}`)
+	tpl.RegisterHelper("join", func(sep string, strs ...string) string { return "" })
+
+	if _, err := tpl.Exec(nil); err == nil {
+		t.Errorf("Expected wrong number of arguments error")
+	}
+}
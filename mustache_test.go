@@ -3,7 +3,6 @@ package raymond
 import (
 	"io/ioutil"
 	"path"
-	"regexp"
 	"strings"
 	"testing"
 
@@ -12,8 +11,7 @@ import (
 
 //
 // Note, as the JS implementation, the divergences from mustache spec:
-//   - we don't support alternative delimeters
-//   - the mustache lambda spec differs
+//   - the mustache lambda spec differs, unless mustache compatibility mode is enabled
 //
 
 type mustacheTest struct {
@@ -31,16 +29,13 @@ type mustacheTestFile struct {
 }
 
 var (
-	rAltDelim = regexp.MustCompile(regexp.QuoteMeta("{{="))
-)
-
-var (
-	musTestLambdaInterMult = 0
+	musTestLambdaInterMult       = 0
+	musTestCompatLambdaInterMult = 0
 )
 
 func TestMustache(t *testing.T) {
 	skipFiles := map[string]bool{
-		// mustache lambdas differ from handlebars lambdas
+		// lambdas are defined with code, see mustacheCompatLambdasTests
 		"~lambdas.yml": true,
 	}
 
@@ -89,27 +84,8 @@ func testsFromMustacheFile(fileName string) []Test {
 
 // returns true if test must be skipped
 func mustBeSkipped(test mustacheTest, fileName string) bool {
-	// handlebars does not support alternative delimiters
-	return haveAltDelimiter(test) ||
-		// the JS implementation skips those tests
-		fileName == "partials.yml" && (test.Name == "Failed Lookup" || test.Name == "Standalone Indentation")
-}
-
-// returns true if test have alternative delimeter in template or in partials
-func haveAltDelimiter(test mustacheTest) bool {
-	// check template
-	if rAltDelim.MatchString(test.Template) {
-		return true
-	}
-
-	// check partials
-	for _, partial := range test.Partials {
-		if rAltDelim.MatchString(partial) {
-			return true
-		}
-	}
-
-	return false
+	// the JS implementation skips those tests
+	return fileName == "partials.yml" && (test.Name == "Failed Lookup" || test.Name == "Standalone Indentation")
 }
 
 func mustacheTestFiles() []string {
@@ -135,6 +111,7 @@ func mustacheTestFiles() []string {
 // Following tests come fron ~lambdas.yml
 //
 
+// in handlebars mode, lambdas are helpers
 var mustacheLambdasTests = []Test{
 	{
 		"Interpolation",
@@ -143,18 +120,6 @@ var mustacheLambdasTests = []Test{
 		nil, nil, nil,
 		"Hello, world!",
 	},
-
-	// // SKIP: lambda return value is not parsed
-	// {
-	// 	"Interpolation - Expansion",
-	// 	"Hello, {{lambda}}!",
-	// 	map[string]interface{}{"lambda": func() string { return "{{planet}}" }},
-	// 	nil, nil, nil,
-	// 	"Hello, world!",
-	// },
-
-	// SKIP "Interpolation - Alternate Delimiters"
-
 	{
 		"Interpolation - Multiple Calls",
 		"{{lambda}} == {{{lambda}}} == {{lambda}}",
@@ -165,7 +130,6 @@ var mustacheLambdasTests = []Test{
 		nil, nil, nil,
 		"1 == 2 == 3",
 	},
-
 	{
 		"Escaping",
 		"<{{lambda}}{{{lambda}}}",
@@ -173,35 +137,6 @@ var mustacheLambdasTests = []Test{
 		nil, nil, nil,
 		"<&gt;>",
 	},
-
-	// // SKIP: "Lambdas used for sections should receive the raw section string."
-	// {
-	// 	"Section",
-	// 	"<{{#lambda}}{{x}}{{/lambda}}>",
-	// 	map[string]interface{}{"lambda": func(param string) string {
-	// 		if param == "{{x}}" {
-	// 			return "yes"
-	// 		}
-
-	// 		return "false"
-	// 	}, "x": "Error!"},
-	// 	nil, nil, nil,
-	// 	"<yes>",
-	// },
-
-	// // SKIP: lambda return value is not parsed
-	// {
-	// 	"Section - Expansion",
-	// 	"<{{#lambda}}-{{/lambda}}>",
-	// 	map[string]interface{}{"lambda": func(param string) string {
-	// 		return param + "{{planet}}" + param
-	// 	}, "planet": "Earth"},
-	// 	nil, nil, nil,
-	// 	"<-Earth->",
-	// },
-
-	// SKIP: "Section - Alternate Delimiters"
-
 	{
 		"Section - Multiple Calls",
 		"{{#lambda}}FILE{{/lambda}} != {{#lambda}}LINE{{/lambda}}",
@@ -211,20 +146,97 @@ var mustacheLambdasTests = []Test{
 		nil, nil, nil,
 		"__FILE__ != __LINE__",
 	},
+}
 
-	// // SKIP: "Lambdas used for inverted sections should be considered truthy."
-	// {
-	// 	"Inverted Section",
-	// 	"<{{^lambda}}{{static}}{{/lambda}}>",
-	// 	map[string]interface{}{
-	// 		"lambda": func() interface{} {
-	// 			return false
-	// 		},
-	// 		"static": "static",
-	// 	},
-	// 	nil, nil, nil,
-	// 	"<>",
-	// },
+// in mustache compatibility mode, the whole spec file passes
+var mustacheCompatLambdasTests = []Test{
+	{
+		"Interpolation",
+		"Hello, {{lambda}}!",
+		map[string]interface{}{"lambda": func() string { return "world" }},
+		nil, nil, nil,
+		"Hello, world!",
+	},
+	{
+		"Interpolation - Expansion",
+		"Hello, {{lambda}}!",
+		map[string]interface{}{"lambda": func() string { return "{{planet}}" }, "planet": "world"},
+		nil, nil, nil,
+		"Hello, world!",
+	},
+	{
+		"Interpolation - Alternate Delimiters",
+		"{{= | | =}}\nHello, (|&lambda|)!",
+		map[string]interface{}{"lambda": func() string { return "|planet| => {{planet}}" }, "planet": "world"},
+		nil, nil, nil,
+		"Hello, (|planet| => world)!",
+	},
+	{
+		"Interpolation - Multiple Calls",
+		"{{lambda}} == {{{lambda}}} == {{lambda}}",
+		map[string]interface{}{"lambda": func() string {
+			musTestCompatLambdaInterMult++
+			return Str(musTestCompatLambdaInterMult)
+		}},
+		nil, nil, nil,
+		"1 == 2 == 3",
+	},
+	{
+		"Escaping",
+		"<{{lambda}}{{{lambda}}}",
+		map[string]interface{}{"lambda": func() string { return ">" }},
+		nil, nil, nil,
+		"<&gt;>",
+	},
+	{
+		"Section",
+		"<{{#lambda}}{{x}}{{/lambda}}>",
+		map[string]interface{}{"lambda": func(text string) string {
+			if text == "{{x}}" {
+				return "yes"
+			}
+
+			return "no"
+		}, "x": "Error!"},
+		nil, nil, nil,
+		"<yes>",
+	},
+	{
+		"Section - Expansion",
+		"<{{#lambda}}-{{/lambda}}>",
+		map[string]interface{}{"lambda": func(text string) string {
+			return text + "{{planet}}" + text
+		}, "planet": "Earth"},
+		nil, nil, nil,
+		"<-Earth->",
+	},
+	{
+		"Section - Alternate Delimiters",
+		"{{= | | =}}<|#lambda|-|/lambda|>",
+		map[string]interface{}{"lambda": func(text string) string {
+			return text + "{{planet}} => |planet|" + text
+		}, "planet": "Earth"},
+		nil, nil, nil,
+		"<-{{planet}} => Earth->",
+	},
+	{
+		"Section - Multiple Calls",
+		"{{#lambda}}FILE{{/lambda}} != {{#lambda}}LINE{{/lambda}}",
+		map[string]interface{}{"lambda": func(text string) string {
+			return "__" + text + "__"
+		}},
+		nil, nil, nil,
+		"__FILE__ != __LINE__",
+	},
+	{
+		"Inverted Section",
+		"<{{^lambda}}{{static}}{{/lambda}}>",
+		map[string]interface{}{"lambda": func(text string) string {
+			return ""
+		}, "static": "static"},
+		nil, nil, nil,
+		"<>",
+	},
 }
 
 func TestMustacheLambdas(t *testing.T) {
@@ -232,3 +244,19 @@ func TestMustacheLambdas(t *testing.T) {
 
 	launchTests(t, mustacheLambdasTests)
 }
+
+func TestMustacheCompatLambdas(t *testing.T) {
+	t.Parallel()
+
+	for _, test := range mustacheCompatLambdasTests {
+		tpl := MustParse(test.input)
+		tpl.SetMustacheCompat(true)
+
+		output, err := tpl.Exec(test.data)
+		if err != nil {
+			t.Errorf("Test '%s' failed: %s", test.name, err)
+		} else if output != test.output {
+			t.Errorf("Test '%s' failed\ninput:\n\t%q\nexpected\n\t%q\ngot\n\t%q", test.name, test.input, test.output, output)
+		}
+	}
+}
@@ -0,0 +1,219 @@
+package parser
+
+import (
+	"sort"
+	"unicode/utf8"
+
+	"github.com/komand/raymond/ast"
+)
+
+// lineIndex computes line and column numbers of byte positions in an input string
+type lineIndex struct {
+	input string
+
+	// byte positions of lines starts
+	starts []int
+}
+
+// locationVisitor walks through the AST to set nodes line and column numbers from their byte positions
+type locationVisitor struct {
+	index *lineIndex
+}
+
+// newLineIndex instanciates a new lineIndex for given input
+func newLineIndex(input string) *lineIndex {
+	result := &lineIndex{
+		input:  input,
+		starts: []int{0},
+	}
+
+	for i := 0; i < len(input); i++ {
+		if input[i] == '\n' {
+			result.starts = append(result.starts, i+1)
+		}
+	}
+
+	return result
+}
+
+// locate returns line and column numbers of given byte position, the column being counted in runes
+func (idx *lineIndex) locate(pos int) (int, int) {
+	if pos < 0 {
+		pos = 0
+	} else if pos > len(idx.input) {
+		pos = len(idx.input)
+	}
+
+	line := sort.Search(len(idx.starts), func(i int) bool { return idx.starts[i] > pos })
+
+	return line, utf8.RuneCountInString(idx.input[idx.starts[line-1]:pos]) + 1
+}
+
+// processLocations sets line and column numbers of all nodes in given AST
+func processLocations(node ast.Node, input string) {
+	node.Accept(&locationVisitor{index: newLineIndex(input)})
+}
+
+// locate sets line and column numbers of given location
+func (v *locationVisitor) locate(loc *ast.Loc) {
+	loc.Line, loc.Column = v.index.locate(loc.Pos)
+	loc.EndLine, loc.EndColumn = v.index.locate(loc.End)
+}
+
+// accept visits given node, if not nil
+func (v *locationVisitor) accept(node ast.Node) {
+	if node != nil {
+		node.Accept(v)
+	}
+}
+
+// VisitProgram implements corresponding Visitor interface method
+func (v *locationVisitor) VisitProgram(node *ast.Program) interface{} {
+	v.locate(&node.Loc)
+
+	for _, n := range node.Body {
+		n.Accept(v)
+	}
+
+	return nil
+}
+
+// VisitMustache implements corresponding Visitor interface method
+func (v *locationVisitor) VisitMustache(node *ast.MustacheStatement) interface{} {
+	v.locate(&node.Loc)
+	node.Expression.Accept(v)
+
+	return nil
+}
+
+// VisitDecorator implements corresponding Visitor interface method
+func (v *locationVisitor) VisitDecorator(node *ast.Decorator) interface{} {
+	return v.VisitMustache(&node.MustacheStatement)
+}
+
+// VisitBlock implements corresponding Visitor interface method
+func (v *locationVisitor) VisitBlock(node *ast.BlockStatement) interface{} {
+	v.locate(&node.Loc)
+	node.Expression.Accept(v)
+
+	if node.Program != nil {
+		node.Program.Accept(v)
+	}
+
+	if node.Inverse != nil {
+		node.Inverse.Accept(v)
+	}
+
+	return nil
+}
+
+// VisitDecoratorBlock implements corresponding Visitor interface method
+func (v *locationVisitor) VisitDecoratorBlock(node *ast.DecoratorBlock) interface{} {
+	return v.VisitBlock(&node.BlockStatement)
+}
+
+// VisitPartialBlock implements corresponding Visitor interface method
+func (v *locationVisitor) VisitPartialBlock(node *ast.PartialBlock) interface{} {
+	return v.VisitBlock(&node.BlockStatement)
+}
+
+// VisitPartial implements corresponding Visitor interface method
+func (v *locationVisitor) VisitPartial(node *ast.PartialStatement) interface{} {
+	v.locate(&node.Loc)
+	v.accept(node.Name)
+
+	for _, param := range node.Params {
+		param.Accept(v)
+	}
+
+	if node.Hash != nil {
+		node.Hash.Accept(v)
+	}
+
+	return nil
+}
+
+// VisitContent implements corresponding Visitor interface method
+func (v *locationVisitor) VisitContent(node *ast.ContentStatement) interface{} {
+	v.locate(&node.Loc)
+
+	return nil
+}
+
+// VisitComment implements corresponding Visitor interface method
+func (v *locationVisitor) VisitComment(node *ast.CommentStatement) interface{} {
+	v.locate(&node.Loc)
+
+	return nil
+}
+
+// VisitExpression implements corresponding Visitor interface method
+func (v *locationVisitor) VisitExpression(node *ast.Expression) interface{} {
+	v.locate(&node.Loc)
+	v.accept(node.Path)
+
+	for _, param := range node.Params {
+		param.Accept(v)
+	}
+
+	if node.Hash != nil {
+		node.Hash.Accept(v)
+	}
+
+	return nil
+}
+
+// VisitSubExpression implements corresponding Visitor interface method
+func (v *locationVisitor) VisitSubExpression(node *ast.SubExpression) interface{} {
+	v.locate(&node.Loc)
+	node.Expression.Accept(v)
+
+	return nil
+}
+
+// VisitPath implements corresponding Visitor interface method
+func (v *locationVisitor) VisitPath(node *ast.PathExpression) interface{} {
+	v.locate(&node.Loc)
+
+	return nil
+}
+
+// VisitString implements corresponding Visitor interface method
+func (v *locationVisitor) VisitString(node *ast.StringLiteral) interface{} {
+	v.locate(&node.Loc)
+
+	return nil
+}
+
+// VisitBoolean implements corresponding Visitor interface method
+func (v *locationVisitor) VisitBoolean(node *ast.BooleanLiteral) interface{} {
+	v.locate(&node.Loc)
+
+	return nil
+}
+
+// VisitNumber implements corresponding Visitor interface method
+func (v *locationVisitor) VisitNumber(node *ast.NumberLiteral) interface{} {
+	v.locate(&node.Loc)
+
+	return nil
+}
+
+// VisitHash implements corresponding Visitor interface method
+func (v *locationVisitor) VisitHash(node *ast.Hash) interface{} {
+	v.locate(&node.Loc)
+
+	for _, pair := range node.Pairs {
+		pair.Accept(v)
+	}
+
+	return nil
+}
+
+// VisitHashPair implements corresponding Visitor interface method
+func (v *locationVisitor) VisitHashPair(node *ast.HashPair) interface{} {
+	v.locate(&node.Loc)
+	node.Val.Accept(v)
+
+	return nil
+}
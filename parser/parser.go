@@ -20,39 +20,88 @@ type parser struct {
 	// Lexer
 	lex *lexer.Lexer
 
+	// Input string
+	input string
+
 	// Root node
 	root ast.Node
 
 	// Tokens parsed but not consumed yet
 	tokens []*lexer.Token
 
+	// Byte position following last consumed token
+	pos int
+
 	// All tokens have been retreieved from lexer
 	lexOver bool
 
 	unescaped bool
+
+	// record syntax errors instead of stopping on first one
+	tolerant bool
+	errors   []*Error
+}
+
+// Error represents a parsing error.
+type Error struct {
+	// Message describes the error
+	Message string
+
+	// Line is the line number, starting at 1
+	Line int
+
+	// Column is the column number, in runes, starting at 1
+	Column int
+
+	// Pos is the byte offset in input
+	Pos int
 }
 
 var (
-	rOpenComment  = regexp.MustCompile(`^\{\{~?!-?-?`)
-	rCloseComment = regexp.MustCompile(`-?-?~?\}\}$`)
-	rOpenAmp      = regexp.MustCompile(`^\{\{~?&`)
+	rOpenComment        = regexp.MustCompile(`^\{\{~?!-?-?`)
+	rCloseComment       = regexp.MustCompile(`-?-?~?\}\}$`)
+	rOpenAmp            = regexp.MustCompile(`^\{\{~?&`)
+	rOpenDecorator      = regexp.MustCompile(`^\{\{~?\*`)
+	rOpenDecoratorBlock = regexp.MustCompile(`^\{\{~?#\*`)
+	rOpenPartialBlock   = regexp.MustCompile(`^\{\{~?#>`)
 )
 
 // new instanciates a new parser
-func new(input string, unescaped bool) *parser {
+func new(input string, lex *lexer.Lexer, unescaped bool) *parser {
 	return &parser{
-		lex:       lexer.Scan(input),
+		lex:       lex,
+		input:     input,
 		unescaped: unescaped,
 	}
 }
 
 // Parse analyzes given input and returns the AST root node.
-func Parse(input string, unescaped bool) (result *ast.Program, err error) {
+func Parse(input string, unescaped bool) (*ast.Program, error) {
+	return parse(new(input, lexer.Scan(input), unescaped))
+}
+
+// ParseWithDelimiters analyzes given input, like Parse, with given mustache delimiters instead of {{ and }}.
+//
+// Delimiters can also be changed inside the template with a set delimiters tag, like {{=<% %>=}}, which is parsed
+// as a comment.
+func ParseWithDelimiters(input string, unescaped bool, open string, close string) (*ast.Program, error) {
+	return parse(new(input, lexer.ScanWithDelimiters(input, open, close), unescaped))
+}
+
+// parse analyzes input with given parser and returns the AST root node
+func parse(parser *parser) (result *ast.Program, err error) {
+	input := parser.input
+
+	// set error column, once error is recovered
+	defer func() {
+		if perr, ok := err.(*Error); ok {
+			_, perr.Column = newLineIndex(input).locate(perr.Pos)
+		}
+	}()
+
 	// recover error
 	defer errRecover(&err)
 
-	parser := new(input, unescaped)
-
 	// parse
 	result = parser.parseProgram()
 
@@ -66,10 +115,51 @@ func Parse(input string, unescaped bool) (result *ast.Program, err error) {
 	// fix whitespaces
 	processWhitespaces(result)
 
+	// set lines and columns
+	processLocations(result, input)
+
 	// named returned values
 	return
 }
 
+// ParseTolerant analyzes given input, like Parse, but does not stop on first syntax error.
+//
+// Parsing resumes at next mustache after an error, so that all syntax errors are returned, along with
+// a best-effort AST of what could be parsed.
+func ParseTolerant(input string, unescaped bool) (*ast.Program, []*Error) {
+	parser := new(input, lexer.ScanTolerant(input), unescaped)
+	parser.tolerant = true
+
+	// parse
+	result := parser.parseProgram()
+
+	// skip stray tokens, like {{/foo}} or {{else}} outside a block
+	for !parser.isToken(lexer.TokenEOF) {
+		parser.tolerate(func() { errToken(parser.shift(), "Syntax error") })
+		parser.synchronize()
+
+		more := parser.parseProgram()
+		for _, node := range more.Body {
+			result.AddStatement(node)
+		}
+
+		result.End = more.End
+	}
+
+	// fix whitespaces
+	processWhitespaces(result)
+
+	// set lines and columns
+	processLocations(result, input)
+
+	index := newLineIndex(input)
+	for _, err := range parser.errors {
+		_, err.Column = index.locate(err.Pos)
+	}
+
+	return result, parser.errors
+}
+
 // errRecover recovers parsing panic
 func errRecover(errp *error) {
 	e := recover()
@@ -85,38 +175,97 @@ func errRecover(errp *error) {
 	}
 }
 
+// Error implements the error interface.
+func (err *Error) Error() string {
+	return fmt.Sprintf("Parse error on line %d:\n%s", err.Line, err.Message)
+}
+
 // errPanic panics
-func errPanic(err error, line int) {
-	panic(fmt.Errorf("Parse error on line %d:\n%s", line, err))
+func errPanic(err error, pos int, line int) {
+	panic(&Error{
+		Message: err.Error(),
+		Line:    line,
+		Pos:     pos,
+	})
 }
 
 // errNode panics with given node infos
 func errNode(node ast.Node, msg string) {
-	errPanic(fmt.Errorf("%s\nNode: %s", msg, node), node.Location().Line)
+	errPanic(fmt.Errorf("%s\nNode: %s", msg, node), node.Location().Pos, node.Location().Line)
 }
 
 // errNode panics with given Token infos
 func errToken(tok *lexer.Token, msg string) {
-	errPanic(fmt.Errorf("%s\nToken: %s", msg, tok), tok.Line)
+	errPanic(fmt.Errorf("%s\nToken: %s", msg, tok), tok.Pos, tok.Line)
 }
 
 // errNode panics because of an unexpected Token kind
 func errExpected(expect lexer.TokenKind, tok *lexer.Token) {
-	errPanic(fmt.Errorf("Expecting %s, got: '%s'", expect, tok), tok.Line)
+	errPanic(fmt.Errorf("Expecting %s, got: '%s'", expect, tok), tok.Pos, tok.Line)
+}
+
+// tolerate calls given function and, in tolerant mode, records the syntax error it panics with
+//
+// Returns false if an error was recorded.
+func (p *parser) tolerate(f func()) (ok bool) {
+	if !p.tolerant {
+		f()
+		return true
+	}
+
+	defer func() {
+		if e := recover(); e != nil {
+			err, isErr := e.(*Error)
+			if !isErr {
+				panic(e)
+			}
+
+			p.errors = append(p.errors, err)
+			ok = false
+		}
+	}()
+
+	f()
+
+	return true
+}
+
+// synchronize skips tokens up to next statement, or end of block, following a syntax error
+func (p *parser) synchronize() {
+	for p.have(1) {
+		switch p.next().Kind {
+		case lexer.TokenEOF, lexer.TokenOpenEndBlock, lexer.TokenInverse, lexer.TokenOpenInverseChain:
+			return
+		}
+
+		if p.isStatement() {
+			return
+		}
+
+		p.tolerate(func() { p.shift() })
+	}
 }
 
 // program : statement*
 func (p *parser) parseProgram() *ast.Program {
-	result := ast.NewProgram(p.next().Pos, p.next().Line)
+	result := ast.NewProgram(p.pos, p.next().Line)
 
 	for p.isStatement() {
-		result.AddStatement(p.parseStatement())
+		var node ast.Node
+
+		if p.tolerate(func() { node = p.parseStatement() }) {
+			result.AddStatement(node)
+		} else {
+			p.synchronize()
+		}
 	}
 
+	result.End = p.next().Pos
+
 	return result
 }
 
-// statement : mustache | block | rawBlock | partial | content | COMMENT
+// statement : mustache | decorator | block | decoratorBlock | rawBlock | partial | partialBlock | content | COMMENT
 func (p *parser) parseStatement() ast.Node {
 	var result ast.Node
 
@@ -124,11 +273,24 @@ func (p *parser) parseStatement() ast.Node {
 
 	switch tok.Kind {
 	case lexer.TokenOpen, lexer.TokenOpenUnescaped:
-		// mustache
-		result = p.parseMustache()
+		if rOpenDecorator.MatchString(tok.Val) {
+			// decorator
+			result = ast.NewDecorator(p.parseMustache())
+		} else {
+			// mustache
+			result = p.parseMustache()
+		}
 	case lexer.TokenOpenBlock:
-		// block
-		result = p.parseBlock()
+		if rOpenDecoratorBlock.MatchString(tok.Val) {
+			// decoratorBlock
+			result = p.parseDecoratorBlock()
+		} else if rOpenPartialBlock.MatchString(tok.Val) {
+			// partialBlock
+			result = p.parsePartialBlock()
+		} else {
+			// block
+			result = p.parseBlock()
+		}
 	case lexer.TokenOpenInverse:
 		// block
 		result = p.parseInverse()
@@ -144,6 +306,9 @@ func (p *parser) parseStatement() ast.Node {
 	case lexer.TokenComment:
 		// COMMENT
 		result = p.parseComment()
+	case lexer.TokenError:
+		// lexer error, in tolerant mode
+		p.shift()
 	}
 
 	return result
@@ -160,6 +325,8 @@ func (p *parser) isStatement() bool {
 		lexer.TokenOpenInverse, lexer.TokenOpenRawBlock, lexer.TokenOpenPartial,
 		lexer.TokenContent, lexer.TokenComment:
 		return true
+	case lexer.TokenError:
+		return p.tolerant
 	}
 
 	return false
@@ -174,7 +341,10 @@ func (p *parser) parseContent() *ast.ContentStatement {
 		errExpected(lexer.TokenContent, tok)
 	}
 
-	return ast.NewContentStatement(tok.Pos, tok.Line, tok.Val)
+	result := ast.NewContentStatement(tok.Pos, tok.Line, tok.Val)
+	result.End = tok.End
+
+	return result
 }
 
 // COMMENT
@@ -187,6 +357,7 @@ func (p *parser) parseComment() *ast.CommentStatement {
 
 	result := ast.NewCommentStatement(tok.Pos, tok.Line, value)
 	result.Strip = ast.NewStripForStr(tok.Val)
+	result.End = tok.End
 
 	return result
 }
@@ -210,15 +381,24 @@ func (p *parser) parseExpressionParamsHash() ([]ast.Node, *ast.Hash) {
 }
 
 // helperName param* hash?
-func (p *parser) parseExpression(tok *lexer.Token) *ast.Expression {
+func (p *parser) parseExpression() *ast.Expression {
+	tok := p.next()
+
 	result := ast.NewExpression(tok.Pos, tok.Line)
 
 	// helperName
 	result.Path = p.parseHelperName()
+	result.End = result.Path.Location().End
 
 	// param* hash?
 	result.Params, result.Hash = p.parseExpressionParamsHash()
 
+	if result.Hash != nil {
+		result.End = result.Hash.End
+	} else if len(result.Params) > 0 {
+		result.End = result.Params[len(result.Params)-1].Location().End
+	}
+
 	return result
 }
 
@@ -230,9 +410,10 @@ func (p *parser) parseRawBlock() *ast.BlockStatement {
 	tok := p.shift()
 
 	result := ast.NewBlockStatement(tok.Pos, tok.Line)
+	result.Raw = true
 
 	// helperName param* hash?
-	result.Expression = p.parseExpression(tok)
+	result.Expression = p.parseExpression()
 
 	openName := result.Expression.Canonical()
 
@@ -246,8 +427,9 @@ func (p *parser) parseRawBlock() *ast.BlockStatement {
 	// @todo Is content mandatory in a raw block ?
 	content := p.parseContent()
 
-	program := ast.NewProgram(tok.Pos, tok.Line)
+	program := ast.NewProgram(tok.End, tok.Line)
 	program.AddStatement(content)
+	program.End = content.End
 
 	result.Program = program
 
@@ -263,11 +445,9 @@ func (p *parser) parseRawBlock() *ast.BlockStatement {
 
 	closeName, ok := ast.HelperNameStr(endID)
 	if !ok {
-		errNode(endID, "Erroneous closing expression")
-	}
-
-	if openName != closeName {
-		errNode(endID, fmt.Sprintf("%s doesn't match %s", openName, closeName))
+		p.tolerate(func() { errNode(endID, "Erroneous closing expression") })
+	} else if openName != closeName {
+		p.tolerate(func() { errNode(endID, fmt.Sprintf("%s doesn't match %s", openName, closeName)) })
 	}
 
 	// CLOSE_RAW_BLOCK
@@ -276,6 +456,8 @@ func (p *parser) parseRawBlock() *ast.BlockStatement {
 		errExpected(lexer.TokenCloseRawBlock, tok)
 	}
 
+	result.End = tok.End
+
 	return result
 }
 
@@ -302,6 +484,33 @@ func (p *parser) parseBlock() *ast.BlockStatement {
 	return result
 }
 
+// decoratorBlock : openBlock program closeBlock
+func (p *parser) parseDecoratorBlock() *ast.DecoratorBlock {
+	block := p.parseBlock()
+
+	if block.Inverse != nil {
+		p.tolerate(func() { errNode(block.Inverse, "Unexpected inverse block on decorator") })
+	}
+
+	return ast.NewDecoratorBlock(block)
+}
+
+// partialBlock : openPartialBlock program closeBlock
+// openPartialBlock : OPEN_PARTIAL_BLOCK helperName param* hash? CLOSE
+func (p *parser) parsePartialBlock() *ast.PartialBlock {
+	block := p.parseBlock()
+
+	if len(block.Program.BlockParams) > 0 {
+		p.tolerate(func() { errNode(block, "Unexpected block params on partial block") })
+	}
+
+	if block.Inverse != nil {
+		p.tolerate(func() { errNode(block.Inverse, "Unexpected inverse block on partial block") })
+	}
+
+	return ast.NewPartialBlock(block)
+}
+
 // setBlockInverseStrip is called when parsing `block` (openBlock | openInverse) and `inverseChain`
 //
 // TODO: This was totally cargo culted ! CHECK THAT !
@@ -352,7 +561,7 @@ func (p *parser) parseOpenBlockExpression(tok *lexer.Token) (*ast.BlockStatement
 	result := ast.NewBlockStatement(tok.Pos, tok.Line)
 
 	// helperName param* hash?
-	result.Expression = p.parseExpression(tok)
+	result.Expression = p.parseExpression()
 
 	// blockParams?
 	if p.isBlockParams() {
@@ -385,12 +594,16 @@ func (p *parser) parseInverseChain() *ast.Program {
 	// inverseChain?
 	if p.isInverseChain() {
 		block.Inverse = p.parseInverseChain()
+		block.End = block.Inverse.End
+	} else {
+		block.End = program.End
 	}
 
 	setBlockInverseStrip(block)
 
 	result.Chained = true
 	result.AddStatement(block)
+	result.End = block.End
 
 	return result
 }
@@ -436,6 +649,16 @@ func (p *parser) parseOpenBlock() (*ast.BlockStatement, []string) {
 
 // closeBlock : OPEN_ENDBLOCK helperName CLOSE
 func (p *parser) parseCloseBlock(block *ast.BlockStatement) {
+	if p.tolerant && !p.isToken(lexer.TokenOpenEndBlock) {
+		// keep unclosed block
+		p.tolerate(func() { errNode(block, fmt.Sprintf("%s block is not closed", block.Expression.Canonical())) })
+
+		block.CloseStrip = &ast.Strip{}
+		block.End = p.pos
+
+		return
+	}
+
 	// OPEN_ENDBLOCK
 	tok := p.shift()
 	if tok.Kind != lexer.TokenOpenEndBlock {
@@ -447,12 +670,9 @@ func (p *parser) parseCloseBlock(block *ast.BlockStatement) {
 
 	closeName, ok := ast.HelperNameStr(endID)
 	if !ok {
-		errNode(endID, "Erroneous closing expression")
-	}
-
-	openName := block.Expression.Canonical()
-	if openName != closeName {
-		errNode(endID, fmt.Sprintf("%s doesn't match %s", openName, closeName))
+		p.tolerate(func() { errNode(endID, "Erroneous closing expression") })
+	} else if openName := block.Expression.Canonical(); openName != closeName {
+		p.tolerate(func() { errNode(endID, fmt.Sprintf("%s doesn't match %s", openName, closeName)) })
 	}
 
 	// CLOSE
@@ -462,6 +682,7 @@ func (p *parser) parseCloseBlock(block *ast.BlockStatement) {
 	}
 
 	block.CloseStrip = ast.NewStrip(tok.Val, tokClose.Val)
+	block.End = tokClose.End
 }
 
 // mustache : OPEN helperName param* hash? CLOSE
@@ -483,7 +704,7 @@ func (p *parser) parseMustache() *ast.MustacheStatement {
 	result := ast.NewMustacheStatement(tok.Pos, tok.Line, unescaped)
 
 	// helperName param* hash?
-	result.Expression = p.parseExpression(tok)
+	result.Expression = p.parseExpression()
 
 	// CLOSE | CLOSE_UNESCAPED
 	tokClose := p.shift()
@@ -492,6 +713,7 @@ func (p *parser) parseMustache() *ast.MustacheStatement {
 	}
 
 	result.Strip = ast.NewStrip(tok.Val, tokClose.Val)
+	result.End = tokClose.End
 
 	return result
 }
@@ -516,6 +738,7 @@ func (p *parser) parsePartial() *ast.PartialStatement {
 	}
 
 	result.Strip = ast.NewStrip(tok.Val, tokClose.Val)
+	result.End = tokClose.End
 
 	return result
 }
@@ -560,7 +783,7 @@ func (p *parser) parseSexpr() *ast.SubExpression {
 	result := ast.NewSubExpression(tok.Pos, tok.Line)
 
 	// helperName param* hash?
-	result.Expression = p.parseExpression(tok)
+	result.Expression = p.parseExpression()
 
 	// CLOSE_SEXPR
 	tok = p.shift()
@@ -568,6 +791,8 @@ func (p *parser) parseSexpr() *ast.SubExpression {
 		errExpected(lexer.TokenCloseSexpr, tok)
 	}
 
+	result.End = tok.End
+
 	return result
 }
 
@@ -583,6 +808,7 @@ func (p *parser) parseHash() *ast.Hash {
 
 	result := ast.NewHash(firstLoc.Pos, firstLoc.Line)
 	result.Pairs = pairs
+	result.End = pairs[len(pairs)-1].End
 
 	return result
 }
@@ -606,6 +832,7 @@ func (p *parser) parseHashSegment() *ast.HashPair {
 	result := ast.NewHashPair(tok.Pos, tok.Line)
 	result.Key = tok.Val
 	result.Val = param
+	result.End = param.Location().End
 
 	return result
 }
@@ -645,17 +872,30 @@ func (p *parser) parseHelperName() ast.Node {
 	case lexer.TokenBoolean:
 		// BOOLEAN
 		p.shift()
-		result = ast.NewBooleanLiteral(tok.Pos, tok.Line, (tok.Val == "true"), tok.Val)
+
+		boolean := ast.NewBooleanLiteral(tok.Pos, tok.Line, (tok.Val == "true"), tok.Val)
+		boolean.End = tok.End
+
+		result = boolean
 	case lexer.TokenNumber:
 		// NUMBER
 		p.shift()
 
 		val, isInt := parseNumber(tok)
-		result = ast.NewNumberLiteral(tok.Pos, tok.Line, val, isInt, tok.Val)
+
+		number := ast.NewNumberLiteral(tok.Pos, tok.Line, val, isInt, tok.Val)
+		number.End = tok.End
+
+		result = number
 	case lexer.TokenString:
 		// STRING
 		p.shift()
-		result = ast.NewStringLiteral(tok.Pos, tok.Line, tok.Val)
+
+		// token position is right after opening delimiter
+		str := ast.NewStringLiteral(tok.Pos-1, tok.Line, tok.Val)
+		str.End = tok.End + 1
+
+		result = str
 	case lexer.TokenData:
 		// dataName
 		result = p.parseDataName()
@@ -708,10 +948,15 @@ func (p *parser) parsePartialName() ast.Node {
 // dataName : DATA pathSegments
 func (p *parser) parseDataName() *ast.PathExpression {
 	// DATA
-	p.shift()
+	tok := p.shift()
 
 	// pathSegments
-	return p.parsePath(true)
+	result := p.parsePath(true)
+
+	result.Pos = tok.Pos
+	result.Line = tok.Line
+
+	return result
 }
 
 // path : pathSegments
@@ -750,6 +995,8 @@ func (p *parser) parsePath(data bool) *ast.PathExpression {
 		}
 	}
 
+	result.End = tok.End
+
 	return result
 }
 
@@ -769,7 +1016,7 @@ func (p *parser) ensure(index int) {
 		// queue it
 		p.tokens = append(p.tokens, &tok)
 
-		if (tok.Kind == lexer.TokenEOF) || (tok.Kind == lexer.TokenError) {
+		if (tok.Kind == lexer.TokenEOF) || ((tok.Kind == lexer.TokenError) && !p.tolerant) {
 			p.lexOver = true
 			break
 		}
@@ -810,6 +1057,12 @@ func (p *parser) shift() *lexer.Token {
 		errToken(result, "Lexer error")
 	}
 
+	p.pos = result.End
+	if result.Kind == lexer.TokenString {
+		// skip closing delimiter
+		p.pos++
+	}
+
 	return result
 }
 
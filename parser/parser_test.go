@@ -3,6 +3,7 @@ package parser
 import (
 	"fmt"
 	"regexp"
+	"strings"
 	"testing"
 
 	"github.com/komand/raymond/ast"
@@ -72,6 +73,9 @@ var parserTests = []parserTest{
 	{"parses an inverse (else-style) section", `{{#foo}} bar {{else}} baz {{/foo}}`, "BLOCK:\n  PATH:foo []\n  PROGRAM:\n    CONTENT[ ' bar ' ]\n  {{^}}\n    CONTENT[ ' baz ' ]\n"},
 	{"parses multiple inverse sections", `{{#foo}} bar {{else if bar}}{{else}} baz {{/foo}}`, "BLOCK:\n  PATH:foo []\n  PROGRAM:\n    CONTENT[ ' bar ' ]\n  {{^}}\n    BLOCK:\n      PATH:if [PATH:bar]\n      PROGRAM:\n      {{^}}\n        CONTENT[ ' baz ' ]\n"},
 	{"parses empty blocks", `{{#foo}}{{/foo}}`, "BLOCK:\n  PATH:foo []\n  PROGRAM:\n"},
+	{"parses decorators", `{{* foo bar baz=1}}`, "{{* PATH:foo [PATH:bar] HASH{baz=NUMBER{1}} }}\n"},
+	{"parses inline partials", `{{#*inline "foo"}} bar {{/inline}}`, "DIRECTIVE BLOCK:\n  PATH:inline [\"foo\"]\n  PROGRAM:\n    CONTENT[ ' bar ' ]\n"},
+	{"parses partial blocks", `{{#> foo bar}} baz {{/foo}}`, "PARTIAL BLOCK:\n  PATH:foo [PATH:bar]\n  PROGRAM:\n    CONTENT[ ' baz ' ]\n"},
 	{"parses empty blocks with empty inverse section", `{{#foo}}{{^}}{{/foo}}`, "BLOCK:\n  PATH:foo []\n  PROGRAM:\n  {{^}}\n"},
 	{"parses empty blocks with empty inverse (else-style) section", `{{#foo}}{{else}}{{/foo}}`, "BLOCK:\n  PATH:foo []\n  PROGRAM:\n  {{^}}\n"},
 	{"parses non-empty blocks with empty inverse section", `{{#foo}} bar {{^}}{{/foo}}`, "BLOCK:\n  PATH:foo []\n  PROGRAM:\n    CONTENT[ ' bar ' ]\n  {{^}}\n"},
@@ -180,6 +184,101 @@ func TestParserErrors(t *testing.T) {
 	}
 }
 
+func TestLocations(t *testing.T) {
+	t.Parallel()
+
+	program, err := Parse("héllo\n{{#if ok}}\nü {{{step1.output}}}\n{{/if}}", false)
+	if err != nil {
+		t.Fatalf("Failed to parse: %s", err)
+	}
+
+	locStr := func(node ast.Node) string {
+		loc := node.Location()
+		return fmt.Sprintf("%d:%d-%d:%d", loc.Line, loc.Column, loc.EndLine, loc.EndColumn)
+	}
+
+	block := program.Body[1].(*ast.BlockStatement)
+	mustache := block.Program.Body[1].(*ast.MustacheStatement)
+
+	tests := []struct {
+		node     ast.Node
+		expected string
+	}{
+		{program, "1:1-4:8"},
+		{block, "2:1-4:8"},
+		{block.Expression.Params[0], "2:7-2:9"},
+		{mustache, "3:3-3:21"},
+		{mustache.Expression.Path, "3:6-3:18"},
+	}
+
+	for _, test := range tests {
+		if output := locStr(test.node); output != test.expected {
+			t.Errorf("Erroneous location of %s - expected %s, got %s", test.node, test.expected, output)
+		}
+	}
+}
+
+func TestParseWithDelimiters(t *testing.T) {
+	t.Parallel()
+
+	program, err := ParseWithDelimiters("<%#if ok%>\n  {{foo}}\n  <%={{ }}=%>\n{{/if}}", false, "<%", "%>")
+	if err != nil {
+		t.Fatalf("Failed to parse: %s", err)
+	}
+
+	expected := "BLOCK:\n  PATH:if [PATH:ok]\n  PROGRAM:\n    CONTENT[ '  {{foo}}\n' ]\n    {{! '={{ }}=' }}\n    CONTENT[ '' ]\n"
+	if output := ast.Print(program); output != expected {
+		t.Errorf("Test failed\nexpected\n\t%q\ngot\n\t%q", expected, output)
+	}
+
+	if _, err := ParseWithDelimiters("{{foo}}", false, "", "}}"); err == nil {
+		t.Errorf("Expected an error with invalid delimiters")
+	}
+}
+
+func TestParseTolerant(t *testing.T) {
+	t.Parallel()
+
+	input := "{{#if a}}\n  {{foo}\n{{bar}}\n{{/each}} {{/if}} {{#with b}}{{baz}}"
+
+	program, errs := ParseTolerant(input, false)
+
+	expectedErrors := []string{
+		"2:8 Lexer error",
+		"4:4 if doesn't match each",
+		"4:11 Syntax error",
+		"4:19 with block is not closed",
+	}
+
+	var output []string
+	for _, err := range errs {
+		output = append(output, fmt.Sprintf("%d:%d %s", err.Line, err.Column, strings.Split(err.Message, "\n")[0]))
+	}
+
+	if fmt.Sprint(output) != fmt.Sprint(expectedErrors) {
+		t.Errorf("Erroneous errors\nexpected\n\t%q\ngot\n\t%q", expectedErrors, output)
+	}
+
+	// best-effort AST
+	if len(program.Body) < 2 {
+		t.Fatalf("Erroneous AST:\n%s", ast.Print(program))
+	}
+
+	block := program.Body[0].(*ast.BlockStatement)
+	if mustache, ok := block.Program.Body[1].(*ast.MustacheStatement); !ok || (mustache.Expression.Canonical() != "bar") {
+		t.Errorf("Erroneous block program:\n%s", ast.Print(block))
+	}
+
+	if with, ok := program.Body[len(program.Body)-1].(*ast.BlockStatement); !ok || (with.Expression.Canonical() != "with") {
+		t.Errorf("Erroneous unclosed block:\n%s", ast.Print(program))
+	}
+
+	// valid template
+	if _, errs := ParseTolerant("{{#if a}}{{b}}{{/if}}", false); len(errs) != 0 {
+		t.Errorf("Unexpected errors: %v", errs)
+	}
+}
+
 // package example
 func Example() {
 	source := "You know {{nothing}} John Snow"
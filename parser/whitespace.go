@@ -210,7 +210,7 @@ func (v *whitespaceVisitor) VisitProgram(program *ast.Program) interface{} {
 			}
 		}
 
-		if b, ok := current.(*ast.BlockStatement); ok {
+		if b, ok := ast.AsBlock(current); ok {
 			if openStandalone {
 				prog := b.Program
 				if prog == nil {
@@ -318,10 +318,22 @@ func (v *whitespaceVisitor) VisitBlock(block *ast.BlockStatement) interface{} {
 	return strip
 }
 
+func (v *whitespaceVisitor) VisitDecoratorBlock(node *ast.DecoratorBlock) interface{} {
+	return v.VisitBlock(&node.BlockStatement)
+}
+
+func (v *whitespaceVisitor) VisitPartialBlock(node *ast.PartialBlock) interface{} {
+	return v.VisitBlock(&node.BlockStatement)
+}
+
 func (v *whitespaceVisitor) VisitMustache(mustache *ast.MustacheStatement) interface{} {
 	return mustache.Strip
 }
 
+func (v *whitespaceVisitor) VisitDecorator(decorator *ast.Decorator) interface{} {
+	return decorator.Strip
+}
+
 func _inlineStandalone(strip *ast.Strip) interface{} {
 	return &ast.Strip{
 		Open:             strip.Open,
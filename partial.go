@@ -13,6 +13,9 @@ type partial struct {
 	unescaped bool
 }
 
+// partialBlockName is the name of the partial that renders the block of a partial block: {{> @partial-block}}
+const partialBlockName = "@partial-block"
+
 // partials stores all global partials
 var partials map[string]*partial
 
@@ -25,12 +28,17 @@ func init() {
 
 // newPartial instanciates a new partial
 func newPartial(name string, source string, tpl *Template) *partial {
-	return &partial{
-		name:      name,
-		source:    source,
-		tpl:       tpl,
-		unescaped: tpl.unescaped,
+	result := &partial{
+		name:   name,
+		source: source,
+		tpl:    tpl,
+	}
+
+	if tpl != nil {
+		result.unescaped = tpl.unescaped
 	}
+
+	return result
 }
 
 // RegisterPartial registers a global partial. That partial will be available to all templates.
@@ -76,12 +84,14 @@ func findPartial(name string) *partial {
 // template returns parsed partial template
 func (p *partial) template() (*Template, error) {
 	if p.tpl == nil {
-		var err error
+		tpl := newTemplate(p.source, p.unescaped)
+		tpl.name = p.name
 
-		p.tpl, err = ParseTemplate(p.source, p.unescaped)
-		if err != nil {
+		if err := tpl.parse(); err != nil {
 			return nil, err
 		}
+
+		p.tpl = tpl
 	}
 
 	return p.tpl, nil
@@ -0,0 +1,521 @@
+package raymond
+
+import (
+	"reflect"
+	"strings"
+
+	"github.com/komand/raymond/ast"
+)
+
+// ReferenceKind represents the way something is referenced by a template.
+type ReferenceKind int
+
+const (
+	// ValueReference is a context path which value is output, eg: {{foo}}
+	ValueReference ReferenceKind = iota
+
+	// BlockReference is a context path used as a block context, eg: {{#foo}}{{/foo}}
+	BlockReference
+
+	// ParamReference is a context path used as a helper or partial parameter, eg: {{#each foo}}
+	ParamReference
+
+	// HashReference is a context path used as a hash value, eg: {{link url=foo}}
+	HashReference
+
+	// HelperReference is a helper name, eg: {{#each foo}}
+	HelperReference
+
+	// PartialReference is a partial name, eg: {{> foo}}
+	PartialReference
+
+	// DynamicPartialReference is a partial name computed by a sub-expression, eg: {{> (foo)}}
+	DynamicPartialReference
+)
+
+// EachItem is the path part representing an item iterated by an #each block.
+const EachItem = "*"
+
+// String returns the string representation of a reference kind.
+func (kind ReferenceKind) String() string {
+	switch kind {
+	case ValueReference:
+		return "value"
+	case BlockReference:
+		return "block"
+	case ParamReference:
+		return "param"
+	case HashReference:
+		return "hash"
+	case HelperReference:
+		return "helper"
+	case PartialReference:
+		return "partial"
+	case DynamicPartialReference:
+		return "dynamic partial"
+	}
+
+	return "unknown"
+}
+
+// Reference represents a context path, a helper or a partial referenced by a template.
+type Reference struct {
+	// location in template
+	ast.Loc
+
+	Kind ReferenceKind
+
+	// Name is the context path as found in template, or the helper or partial name.
+	//
+	// For a dynamic partial, this is the sub-expression computing the partial name.
+	Name string
+
+	// Path is the context path resolved relatively to the root context, when Resolved is true.
+	//
+	// Parts representing items iterated by an #each block are set to EachItem, so {{name}} in
+	// {{#each users}}{{name}}{{/each}} is resolved to "users.*.name".
+	Path string
+
+	// Resolved is false when the context path can't be resolved relatively to the root context, for
+	// example inside a custom block helper. In that case Path is relative to an unknown context.
+	Resolved bool
+}
+
+// refPath represents a context path while looking for references
+type refPath struct {
+	parts    []string
+	resolved bool
+}
+
+// join returns the path with given parts appended
+func (p refPath) join(parts []string) refPath {
+	result := refPath{
+		parts:    make([]string, 0, len(p.parts)+len(parts)),
+		resolved: p.resolved,
+	}
+
+	result.parts = append(result.parts, p.parts...)
+	result.parts = append(result.parts, parts...)
+
+	return result
+}
+
+// referencesVisitor implements the Visitor interface to collect references of a template.
+//
+// It is also used by Validate() and Rename() to walk the context paths of a template, with pathFunc set.
+type referencesVisitor struct {
+	tpl *Template
+
+	result []Reference
+
+	// called for each context path instead of collecting it, if set
+	pathFunc func(node *ast.PathExpression, ref Reference)
+
+	// number of block statements enclosing next visited node
+	blocks int
+
+	// kind of next visited path
+	kind ReferenceKind
+
+	// contexts scopes
+	scopes []refPath
+
+	// block parameters, set to nil if not a context path (eg: @index)
+	blockParams []map[string]*refPath
+}
+
+func newReferencesVisitor(tpl *Template) *referencesVisitor {
+	return &referencesVisitor{
+		tpl:    tpl,
+		scopes: []refPath{{resolved: true}},
+	}
+}
+
+// add appends a reference
+func (v *referencesVisitor) add(kind ReferenceKind, name string, loc ast.Loc, path refPath) {
+	v.result = append(v.result, newReference(kind, name, loc, path))
+}
+
+// newReference instanciates a new reference
+func newReference(kind ReferenceKind, name string, loc ast.Loc, path refPath) Reference {
+	return Reference{
+		Loc:      loc,
+		Kind:     kind,
+		Name:     name,
+		Path:     strings.Join(path.parts, "."),
+		Resolved: path.resolved,
+	}
+}
+
+// variableParts returns the parts of given context path that name a context variable, ie: the parts following @root
+// for a @root path, or all parts otherwise. It returns nil if the path refers to a block param.
+func (v *referencesVisitor) variableParts(node *ast.PathExpression) []string {
+	if node.Data {
+		if node.IsDataRoot() {
+			return node.Parts[1:]
+		}
+
+		// private data
+		return nil
+	}
+
+	if !node.Scoped && (node.Depth == 0) && (len(node.Parts) > 0) {
+		for _, params := range v.blockParams {
+			if _, ok := params[node.Parts[0]]; ok {
+				return nil
+			}
+		}
+	}
+
+	return node.Parts
+}
+
+// resolve returns the context path of given path expression, with a boolean set to false if this is not a context path
+func (v *referencesVisitor) resolve(node *ast.PathExpression) (refPath, bool) {
+	if node.Data {
+		if node.IsDataRoot() {
+			return refPath{parts: node.Parts[1:], resolved: true}, true
+		}
+
+		// private data
+		return refPath{}, false
+	}
+
+	if !node.Scoped && (node.Depth == 0) && (len(node.Parts) > 0) {
+		for i := len(v.blockParams) - 1; i >= 0; i-- {
+			if param, ok := v.blockParams[i][node.Parts[0]]; ok {
+				if param == nil {
+					return refPath{}, false
+				}
+
+				if !param.resolved {
+					return refPath{parts: node.Parts}, true
+				}
+
+				return param.join(node.Parts[1:]), true
+			}
+		}
+	}
+
+	index := len(v.scopes) - 1 - node.Depth
+	if index < 0 {
+		return refPath{parts: node.Parts}, true
+	}
+
+	result := v.scopes[index].join(node.Parts)
+
+	// looking up through an unknown context
+	for _, scope := range v.scopes[index:] {
+		if !scope.resolved {
+			result.resolved = false
+		}
+	}
+
+	if !result.resolved {
+		result.parts = node.Parts
+	}
+
+	return result, true
+}
+
+// findHelper finds given helper
+func (v *referencesVisitor) findHelper(name string) reflect.Value {
+	// check template helpers
+	if h := v.tpl.findHelper(name); h != zero {
+		return h
+	}
+
+	// check global helpers
+	return findHelper(name)
+}
+
+// helperName returns the name of helper called by given expression, or an empty string if this is not a helper call
+func (v *referencesVisitor) helperName(node *ast.Expression) string {
+	if name := node.HelperName(); (name != "") && (v.findHelper(name) != zero) {
+		return name
+	}
+
+	return ""
+}
+
+// expression collects references of given expression, which path is referenced with given kind if not a helper
+func (v *referencesVisitor) expression(node *ast.Expression, kind ReferenceKind) {
+	if name := v.helperName(node); name != "" {
+		v.add(HelperReference, name, node.Path.Location(), refPath{resolved: true})
+	} else {
+		v.kind = kind
+		node.Path.Accept(v)
+	}
+
+	for _, param := range node.Params {
+		v.kind = ParamReference
+		param.Accept(v)
+	}
+
+	if node.Hash != nil {
+		node.Hash.Accept(v)
+	}
+}
+
+// decorator collects references of given decorator params and hash
+func (v *referencesVisitor) decorator(node *ast.Expression) {
+	for _, param := range node.Params {
+		v.kind = ParamReference
+		param.Accept(v)
+	}
+
+	if node.Hash != nil {
+		node.Hash.Accept(v)
+	}
+}
+
+// partial collects references of a partial with given name, params and hash
+func (v *referencesVisitor) partial(node ast.Node, params []ast.Node, hash *ast.Hash) {
+	if subExpr, ok := node.(*ast.SubExpression); ok {
+		v.add(DynamicPartialReference, ast.PrintOriginal(subExpr), subExpr.Loc, refPath{resolved: true})
+
+		v.kind = ParamReference
+		subExpr.Accept(v)
+	} else if name, ok := ast.HelperNameStr(node); ok {
+		v.add(PartialReference, name, node.Location(), refPath{resolved: true})
+	}
+
+	for _, param := range params {
+		v.kind = ParamReference
+		param.Accept(v)
+	}
+
+	if hash != nil {
+		hash.Accept(v)
+	}
+}
+
+// blockScope returns the context scope and block parameters of given block program
+func (v *referencesVisitor) blockScope(node *ast.BlockStatement) (*refPath, map[string]*refPath) {
+	var result *refPath
+
+	target := func(n ast.Node) *refPath {
+		if path, ok := n.(*ast.PathExpression); ok {
+			if p, ok := v.resolve(path); ok {
+				return &p
+			}
+		}
+		return &refPath{}
+	}
+
+	switch name := v.helperName(node.Expression); name {
+	case "":
+		// context block
+		result = target(node.Expression.Path)
+	case "if", "unless":
+		// context is not changed
+		return nil, nil
+	case "with":
+		if len(node.Expression.Params) > 0 {
+			result = target(node.Expression.Params[0])
+		}
+	case "each":
+		if len(node.Expression.Params) > 0 {
+			item := target(node.Expression.Params[0]).join([]string{EachItem})
+			result = &item
+		}
+	}
+
+	if result == nil {
+		// unknown helper
+		result = &refPath{}
+	}
+
+	var params map[string]*refPath
+
+	if blockParams := node.Program.BlockParams; len(blockParams) > 0 {
+		params = map[string]*refPath{blockParams[0]: result}
+
+		if len(blockParams) > 1 {
+			// index or key
+			params[blockParams[1]] = nil
+		}
+	}
+
+	return result, params
+}
+
+//
+// Visitor interface
+//
+
+// Statements
+
+// VisitProgram implements corresponding Visitor interface method
+func (v *referencesVisitor) VisitProgram(node *ast.Program) interface{} {
+	for _, n := range node.Body {
+		n.Accept(v)
+	}
+
+	return nil
+}
+
+// VisitMustache implements corresponding Visitor interface method
+func (v *referencesVisitor) VisitMustache(node *ast.MustacheStatement) interface{} {
+	v.expression(node.Expression, ValueReference)
+
+	return nil
+}
+
+// VisitBlock implements corresponding Visitor interface method
+func (v *referencesVisitor) VisitBlock(node *ast.BlockStatement) interface{} {
+	v.blocks++
+	defer func() { v.blocks-- }()
+
+	v.expression(node.Expression, BlockReference)
+
+	if node.Program != nil {
+		scope, params := v.blockScope(node)
+
+		if scope != nil {
+			v.scopes = append(v.scopes, *scope)
+		}
+
+		if params != nil {
+			v.blockParams = append(v.blockParams, params)
+		}
+
+		node.Program.Accept(v)
+
+		if params != nil {
+			v.blockParams = v.blockParams[:len(v.blockParams)-1]
+		}
+
+		if scope != nil {
+			v.scopes = v.scopes[:len(v.scopes)-1]
+		}
+	}
+
+	// inverse is evaluated with current context
+	if node.Inverse != nil {
+		node.Inverse.Accept(v)
+	}
+
+	return nil
+}
+
+// VisitDecorator implements corresponding Visitor interface method
+func (v *referencesVisitor) VisitDecorator(node *ast.Decorator) interface{} {
+	v.decorator(node.Expression)
+
+	return nil
+}
+
+// VisitDecoratorBlock implements corresponding Visitor interface method
+func (v *referencesVisitor) VisitDecoratorBlock(node *ast.DecoratorBlock) interface{} {
+	v.decorator(node.Expression)
+
+	// an inline partial is evaluated with the context of the partial statements calling it
+	v.scopes = append(v.scopes, refPath{})
+	node.Program.Accept(v)
+	v.scopes = v.scopes[:len(v.scopes)-1]
+
+	return nil
+}
+
+// VisitPartial implements corresponding Visitor interface method
+func (v *referencesVisitor) VisitPartial(node *ast.PartialStatement) interface{} {
+	v.partial(node.Name, node.Params, node.Hash)
+
+	return nil
+}
+
+// VisitPartialBlock implements corresponding Visitor interface method
+func (v *referencesVisitor) VisitPartialBlock(node *ast.PartialBlock) interface{} {
+	v.partial(node.Expression.Path, node.Expression.Params, node.Expression.Hash)
+
+	// without params nor hash, the block is evaluated with current context when partial is missing, and most
+	// probably with the same context when called by {{> @partial-block}} inside the partial
+	if (len(node.Expression.Params) == 0) && (node.Expression.Hash == nil) {
+		node.Program.Accept(v)
+	} else {
+		v.scopes = append(v.scopes, refPath{})
+		node.Program.Accept(v)
+		v.scopes = v.scopes[:len(v.scopes)-1]
+	}
+
+	return nil
+}
+
+// VisitContent implements corresponding Visitor interface method
+func (v *referencesVisitor) VisitContent(node *ast.ContentStatement) interface{} {
+	return nil
+}
+
+// VisitComment implements corresponding Visitor interface method
+func (v *referencesVisitor) VisitComment(node *ast.CommentStatement) interface{} {
+	return nil
+}
+
+// Expressions
+
+// VisitExpression implements corresponding Visitor interface method
+func (v *referencesVisitor) VisitExpression(node *ast.Expression) interface{} {
+	v.expression(node, v.kind)
+
+	return nil
+}
+
+// VisitSubExpression implements corresponding Visitor interface method
+func (v *referencesVisitor) VisitSubExpression(node *ast.SubExpression) interface{} {
+	v.expression(node.Expression, v.kind)
+
+	return nil
+}
+
+// VisitPath implements corresponding Visitor interface method
+func (v *referencesVisitor) VisitPath(node *ast.PathExpression) interface{} {
+	path, ok := v.resolve(node)
+	if !ok {
+		return nil
+	}
+
+	if v.pathFunc != nil {
+		v.pathFunc(node, newReference(v.kind, node.Original, node.Loc, path))
+	} else {
+		v.add(v.kind, node.Original, node.Loc, path)
+	}
+
+	return nil
+}
+
+// Literals
+
+// VisitString implements corresponding Visitor interface method
+func (v *referencesVisitor) VisitString(node *ast.StringLiteral) interface{} {
+	return nil
+}
+
+// VisitBoolean implements corresponding Visitor interface method
+func (v *referencesVisitor) VisitBoolean(node *ast.BooleanLiteral) interface{} {
+	return nil
+}
+
+// VisitNumber implements corresponding Visitor interface method
+func (v *referencesVisitor) VisitNumber(node *ast.NumberLiteral) interface{} {
+	return nil
+}
+
+// Miscellaneous
+
+// VisitHash implements corresponding Visitor interface method
+func (v *referencesVisitor) VisitHash(node *ast.Hash) interface{} {
+	for _, pair := range node.Pairs {
+		pair.Accept(v)
+	}
+
+	return nil
+}
+
+// VisitHashPair implements corresponding Visitor interface method
+func (v *referencesVisitor) VisitHashPair(node *ast.HashPair) interface{} {
+	v.kind = HashReference
+	node.Val.Accept(v)
+
+	return nil
+}
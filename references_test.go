@@ -0,0 +1,175 @@
+package raymond
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+var referencesTests = []struct {
+	name    string
+	input   string
+	helpers map[string]interface{}
+	output  []string
+}{
+	{
+		"values",
+		"{{foo}} {{{bar.baz}}} {{@index}} {{@root.qux}} {{[step 1].name}}",
+		nil,
+		[]string{
+			"value foo foo 1:2",
+			"value bar.baz bar.baz 1:11",
+			"value @root.qux qux 1:35",
+			"value [step 1].name [step 1].name 1:49",
+		},
+	},
+	{
+		"helpers, params and hash values",
+		"{{#if (equal a \"x\") }}{{{link text url=b.c}}}{{/if}}",
+		nil,
+		[]string{
+			"helper if  1:3",
+			"helper equal  1:7",
+			"param a a 1:13",
+			"value link link 1:25",
+			"param text text 1:30",
+			"hash url=b.c b.c 1:39",
+		},
+	},
+	{
+		"block contexts",
+		"{{#with author}}{{name}} {{../title}}{{/with}}{{#people}}{{firstName}}{{else}}{{none}}{{/people}}",
+		nil,
+		[]string{
+			"helper with  1:3",
+			"param author author 1:8",
+			"value name author.name 1:18",
+			"value ../title title 1:27",
+			"block people people 1:49",
+			"value firstName people.firstName 1:59",
+			"value none none 1:80",
+		},
+	},
+	{
+		"each scopes and block params",
+		"{{#each users as |user i|}}{{user.name}} {{i}} {{#each user.tags}}{{this}} {{@root.x}}{{/each}}{{/each}}",
+		nil,
+		[]string{
+			"helper each  1:3",
+			"param users users 1:8",
+			"value user.name users.*.name 1:29",
+			"helper each  1:50",
+			"param user.tags users.*.tags 1:55",
+			"value this users.*.tags.* 1:68",
+			"value @root.x x 1:77",
+		},
+	},
+	{
+		"custom block helper",
+		"{{#list people}}{{name}} {{../title}}{{/list}} {{#list people as |p|}}{{p.name}}{{/list}}",
+		map[string]interface{}{"list": func(options *Options) string { return options.Fn() }},
+		[]string{
+			"helper list  1:3",
+			"param people people 1:8",
+			"value name name (unresolved) 1:18",
+			"value ../title title (unresolved) 1:27",
+			"helper list  1:50",
+			"param people people 1:55",
+			"value p.name p.name (unresolved) 1:72",
+		},
+	},
+	{
+		"partials",
+		"{{> header title=page.title}}{{> (lookup . 'footer') page}}",
+		nil,
+		[]string{
+			"partial header  1:4",
+			"hash title=page.title page.title 1:17",
+			"dynamic partial (lookup . \"footer\")  1:33",
+			"helper lookup  1:34",
+			"param .  1:41",
+			"param page page 1:53",
+		},
+	},
+	{
+		"decorators",
+		"{{* locale user.lang}}{{#*inline \"row\" size=width}}{{name}}{{/inline}}",
+		nil,
+		[]string{
+			"param user.lang user.lang 1:11",
+			"hash size=width width 1:44",
+			"value name name (unresolved) 1:53",
+		},
+	},
+	{
+		"partial blocks",
+		"{{#> layout}}{{title}}{{/layout}}{{#> card user}}{{name}}{{/card}}",
+		nil,
+		[]string{
+			"partial layout  1:5",
+			"value title title 1:15",
+			"partial card  1:38",
+			"param user user 1:43",
+			"value name name (unresolved) 1:51",
+		},
+	},
+}
+
+// referenceStr returns a string representation of given reference
+func referenceStr(input string, ref Reference) string {
+	name := ref.Name
+	if (ref.Kind == HashReference) && (ref.Pos > 0) {
+		// include the key
+		if i := strings.LastIndex(input[:ref.Pos], " "); i >= 0 {
+			name = input[i+1:ref.Pos] + name
+		}
+	}
+
+	path := ref.Path
+	if (ref.Kind == HelperReference) || (ref.Kind == PartialReference) || (ref.Kind == DynamicPartialReference) {
+		path = ""
+	}
+
+	if !ref.Resolved {
+		path += " (unresolved)"
+	}
+
+	return fmt.Sprintf("%s %s %s %d:%d", ref.Kind, name, path, ref.Line, ref.Pos)
+}
+
+func TestReferences(t *testing.T) {
+	t.Parallel()
+
+	for _, test := range referencesTests {
+		tpl := MustParse(test.input)
+		tpl.RegisterHelpers(test.helpers)
+
+		refs, err := tpl.References()
+		if err != nil {
+			t.Errorf("Test '%s' failed - Failed to get references: %s", test.name, err)
+			continue
+		}
+
+		var output []string
+		for _, ref := range refs {
+			output = append(output, referenceStr(test.input, ref))
+		}
+
+		if strings.Join(output, "\n") != strings.Join(test.output, "\n") {
+			t.Errorf("Test '%s' failed\ninput:\n\t%q\nexpected\n\t%q\ngot\n\t%q", test.name, test.input, test.output, output)
+		}
+	}
+}
+
+func TestReferencesParseError(t *testing.T) {
+	t.Parallel()
+
+	if _, err := MustParse("{{foo}}").References(); err != nil {
+		t.Errorf("Unexpected error: %s", err)
+	}
+
+	tpl := newTemplate("{{#foo}}", false)
+	if _, err := tpl.References(); err == nil {
+		t.Errorf("Test failed - Error expected")
+	}
+}
@@ -0,0 +1,55 @@
+package raymond
+
+import "reflect"
+
+// Resolver is implemented by contexts that resolve their fields themselves, for example to lazily load or decode
+// them.
+//
+// Resolve is called before any other field lookup, and returns the value of given field and true if it exists. When
+// it returns false, the field is looked up as usual: context methods, then struct fields, map keys or array indexes.
+type Resolver interface {
+	Resolve(name string) (interface{}, bool)
+}
+
+// resolverType is the reflect.Type of Resolver
+var resolverType = reflect.TypeOf((*Resolver)(nil)).Elem()
+
+// resolve returns the value of given field if given context implements Resolver and resolves that field
+func resolve(ctx reflect.Value, name string) (reflect.Value, bool) {
+	if ctx.Kind() != reflect.Interface && ctx.CanAddr() {
+		ctx = ctx.Addr()
+	}
+
+	if (ctx.Kind() == reflect.Ptr) && ctx.IsNil() {
+		return zero, false
+	}
+
+	if !ctx.CanInterface() || (ctx.Kind() != reflect.Interface && !typeInfoFor(ctx.Type()).resolver) {
+		return zero, false
+	}
+
+	resolver, ok := ctx.Interface().(Resolver)
+	if !ok {
+		return zero, false
+	}
+
+	val, ok := resolver.Resolve(name)
+	if !ok {
+		return zero, false
+	}
+
+	return reflect.ValueOf(val), true
+}
+
+// resolverPtr returns given value as a non nil pointer that implements Resolver, and false if it is not
+func resolverPtr(val reflect.Value) (reflect.Value, bool) {
+	for (val.Kind() == reflect.Interface) && !val.IsNil() {
+		val = val.Elem()
+	}
+
+	if (val.Kind() != reflect.Ptr) || val.IsNil() || !typeInfoFor(val.Type()).resolver {
+		return zero, false
+	}
+
+	return val, true
+}
@@ -0,0 +1,81 @@
+package raymond
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+// lazyJSON decodes fields of a JSON object only when they are accessed
+type lazyJSON struct {
+	raw     json.RawMessage
+	fields  map[string]json.RawMessage
+	decoded []string
+}
+
+func (doc *lazyJSON) Resolve(name string) (interface{}, bool) {
+	if doc.fields == nil {
+		if err := json.Unmarshal(doc.raw, &doc.fields); err != nil {
+			return nil, false
+		}
+	}
+
+	raw, ok := doc.fields[name]
+	if !ok {
+		return nil, false
+	}
+
+	doc.decoded = append(doc.decoded, name)
+
+	if len(raw) > 0 && raw[0] == '{' {
+		return &lazyJSON{raw: raw}, true
+	}
+
+	var result interface{}
+	if err := json.Unmarshal(raw, &result); err != nil {
+		return nil, false
+	}
+
+	return result, true
+}
+
+func (doc *lazyJSON) Summary() string {
+	return "summary"
+}
+
+type resolverStep struct {
+	Name string
+}
+
+func (step resolverStep) Resolve(name string) (interface{}, bool) {
+	if name == "output" {
+		return func() string { return step.Name + " output" }, true
+	}
+
+	return nil, false
+}
+
+func TestResolver(t *testing.T) {
+	t.Parallel()
+
+	doc := &lazyJSON{raw: json.RawMessage(`{"title": "foo", "author": {"name": "bar"}, "unused": [1, 2, 3]}`)}
+
+	tpl := MustParse(`{{title}} by {{author.name}} - {{summary}}{{#with author}} {{name}}{{/with}} [{{missing}}]`)
+
+	if output := tpl.MustExec(doc); output != "foo by bar - summary bar []" {
+		t.Errorf("Failed to evaluate lazy context, got: %q", output)
+	}
+
+	for _, name := range doc.decoded {
+		if name == "unused" {
+			t.Errorf("Unused field was decoded")
+		}
+	}
+
+	// value receiver, with fallback to struct fields
+	tpl = MustParse(`{{#each steps}}{{name}}: {{output}}. {{/each}}`)
+	ctx := map[string]interface{}{"steps": []resolverStep{{Name: "build"}, {Name: "test"}}}
+
+	if output := tpl.MustExec(ctx); output != "build: build output. test: test output. " {
+		t.Errorf("Failed to evaluate resolver context, got: %q", output)
+	}
+}
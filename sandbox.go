@@ -0,0 +1,243 @@
+package raymond
+
+import (
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"reflect"
+)
+
+var (
+	fmtFormatterType  = reflect.TypeOf((*fmt.Formatter)(nil)).Elem()
+	jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+// Sandbox restricts the methods and functions that templates can call from their context, see Template.SetSandbox().
+//
+// Methods, and functions stored in struct fields or map values, are blocked unless explicitly allowed. That includes
+// the String(), Error(), Format(), MarshalJSON() and MarshalText() methods called to output context values. Helpers
+// are not restricted. A sandbox must not be modified while templates using it are evaluated.
+type Sandbox struct {
+	allowed map[reflect.Type]map[string]bool
+}
+
+// CallNotAllowedError is the underlying error of the ExecError returned when a template calls a method or a function
+// that is not allowed by its sandbox.
+type CallNotAllowedError struct {
+	// Type is the type of the value holding the method or function
+	Type reflect.Type
+
+	// Name is the name of the method, of the struct field, or the map key
+	Name string
+}
+
+// NewSandbox instanciates a new sandbox that blocks all method and function calls from context.
+func NewSandbox() *Sandbox {
+	return &Sandbox{
+		allowed: make(map[reflect.Type]map[string]bool),
+	}
+}
+
+// Allow allows calling the methods with given names on values of the same type as given value, and the functions
+// stored in its struct fields or map values with given names. Pointers and values are equivalent.
+//
+// For example, Allow(&User{}, "FullName") allows {{user.fullName}} but still blocks {{user.delete}}.
+func (sandbox *Sandbox) Allow(value interface{}, names ...string) *Sandbox {
+	typ := sandboxType(reflect.TypeOf(value))
+
+	if sandbox.allowed[typ] == nil {
+		sandbox.allowed[typ] = make(map[string]bool)
+	}
+
+	for _, name := range names {
+		sandbox.allowed[typ][name] = true
+	}
+
+	return sandbox
+}
+
+// allows returns true if calling given method or function of given type is allowed
+func (sandbox *Sandbox) allows(typ reflect.Type, name string) bool {
+	return sandbox.allowed[sandboxType(typ)][name]
+}
+
+// sandboxType returns the type used as allow-list key for given type
+func sandboxType(typ reflect.Type) reflect.Type {
+	for (typ != nil) && (typ.Kind() == reflect.Ptr) {
+		typ = typ.Elem()
+	}
+
+	return typ
+}
+
+// strCall returns an error for the first method called by Str() to convert given value to a string that is not
+// allowed, or nil. Str() formats values with fmt, and maps and slices with encoding/json.
+func (sandbox *Sandbox) strCall(value interface{}) *CallNotAllowedError {
+	switch value.(type) {
+	case nil, string, SafeString, int, *jsonObject:
+		return nil
+	}
+
+	ival, ok := printableValue(reflect.ValueOf(value))
+	if !ok {
+		return nil
+	}
+
+	val := reflect.ValueOf(ival)
+
+	switch val.Kind() {
+	case reflect.Map, reflect.Array, reflect.Slice:
+		return sandbox.jsonCall(val, make(map[uintptr]bool))
+	case reflect.Bool, reflect.Float32, reflect.Float64, reflect.Invalid:
+		return nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		// formatted with %d verb
+		return sandbox.fmtCall(val, false, 0)
+	}
+
+	return sandbox.fmtCall(val, true, 0)
+}
+
+// fmtCall returns an error for the first method called by fmt to format given value that is not allowed, or nil
+func (sandbox *Sandbox) fmtCall(val reflect.Value, strVerb bool, depth int) *CallNotAllowedError {
+	if !val.IsValid() {
+		return nil
+	}
+
+	// fmt only calls methods of values that can be used as interfaces
+	if val.CanInterface() {
+		typ := val.Type()
+		if val.Kind() == reflect.Interface {
+			if val.IsNil() {
+				return nil
+			}
+			typ = val.Elem().Type()
+		}
+
+		switch {
+		case typ.Implements(fmtFormatterType):
+			return sandbox.callError(typ, "Format")
+		case strVerb && typ.Implements(errorType):
+			return sandbox.callError(typ, "Error")
+		case strVerb && typ.Implements(fmtStringerType):
+			return sandbox.callError(typ, "String")
+		}
+	}
+
+	switch val.Kind() {
+	case reflect.Interface:
+		return sandbox.fmtCall(val.Elem(), strVerb, depth+1)
+	case reflect.Ptr:
+		// nested pointers are printed as addresses
+		if (depth == 0) && !val.IsNil() {
+			return sandbox.fmtCall(val.Elem(), strVerb, depth+1)
+		}
+	case reflect.Map:
+		iter := val.MapRange()
+		for iter.Next() {
+			if err := sandbox.fmtCall(iter.Key(), strVerb, depth+1); err != nil {
+				return err
+			}
+			if err := sandbox.fmtCall(iter.Value(), strVerb, depth+1); err != nil {
+				return err
+			}
+		}
+	case reflect.Struct:
+		for i := 0; i < val.NumField(); i++ {
+			if err := sandbox.fmtCall(val.Field(i), strVerb, depth+1); err != nil {
+				return err
+			}
+		}
+	case reflect.Array, reflect.Slice:
+		for i := 0; i < val.Len(); i++ {
+			if err := sandbox.fmtCall(val.Index(i), strVerb, depth+1); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// jsonCall returns an error for the first method called by encoding/json to marshal given value that is not
+// allowed, or nil
+func (sandbox *Sandbox) jsonCall(val reflect.Value, visited map[uintptr]bool) *CallNotAllowedError {
+	if !val.IsValid() {
+		return nil
+	}
+
+	typ := val.Type()
+	nilPtr := (typ.Kind() == reflect.Ptr) && val.IsNil()
+
+	switch {
+	case (typ.Kind() != reflect.Ptr) && val.CanAddr() && reflect.PtrTo(typ).Implements(jsonMarshalerType),
+		typ.Implements(jsonMarshalerType) && !nilPtr:
+		return sandbox.callError(typ, "MarshalJSON")
+	case (typ.Kind() != reflect.Ptr) && val.CanAddr() && reflect.PtrTo(typ).Implements(textMarshalerType),
+		typ.Implements(textMarshalerType) && !nilPtr:
+		return sandbox.callError(typ, "MarshalText")
+	}
+
+	switch val.Kind() {
+	case reflect.Interface:
+		return sandbox.jsonCall(val.Elem(), visited)
+	case reflect.Ptr, reflect.Map, reflect.Slice:
+		if val.IsNil() || visited[val.Pointer()] {
+			return nil
+		}
+		visited[val.Pointer()] = true
+	}
+
+	switch val.Kind() {
+	case reflect.Ptr:
+		return sandbox.jsonCall(val.Elem(), visited)
+	case reflect.Map:
+		keyText := (typ.Key().Kind() != reflect.String) && typ.Key().Implements(textMarshalerType)
+
+		iter := val.MapRange()
+		for iter.Next() {
+			if keyText {
+				if err := sandbox.callError(typ.Key(), "MarshalText"); err != nil {
+					return err
+				}
+			}
+			if err := sandbox.jsonCall(iter.Value(), visited); err != nil {
+				return err
+			}
+		}
+	case reflect.Struct:
+		for i := 0; i < val.NumField(); i++ {
+			field := typ.Field(i)
+			if ((field.PkgPath != "") && !field.Anonymous) || (field.Tag.Get("json") == "-") {
+				continue
+			}
+
+			if err := sandbox.jsonCall(val.Field(i), visited); err != nil {
+				return err
+			}
+		}
+	case reflect.Array, reflect.Slice:
+		for i := 0; i < val.Len(); i++ {
+			if err := sandbox.jsonCall(val.Index(i), visited); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// callError returns an error if calling given method of given type is not allowed, or nil
+func (sandbox *Sandbox) callError(typ reflect.Type, name string) *CallNotAllowedError {
+	if sandbox.allows(typ, name) {
+		return nil
+	}
+
+	return &CallNotAllowedError{Type: typ, Name: name}
+}
+
+// Error implements the error interface.
+func (err *CallNotAllowedError) Error() string {
+	return fmt.Sprintf("Calling '%s' of %s is not allowed by sandbox", err.Name, sandboxType(err.Type))
+}
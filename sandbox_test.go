@@ -0,0 +1,137 @@
+package raymond
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type sandboxUser struct {
+	Name    string
+	deleted bool
+	Hook    func() string
+}
+
+func (user *sandboxUser) FullName() string {
+	return "Mr " + user.Name
+}
+
+func (user *sandboxUser) Delete() string {
+	user.deleted = true
+	return "deleted"
+}
+
+func (user sandboxUser) String() string {
+	return user.Name
+}
+
+type sandboxTag struct {
+	name string
+}
+
+func (tag sandboxTag) String() string {
+	return "#" + tag.name
+}
+
+type sandboxStamp int
+
+func (stamp sandboxStamp) MarshalJSON() ([]byte, error) {
+	return []byte(`"stamp"`), nil
+}
+
+func TestSandbox(t *testing.T) {
+	t.Parallel()
+
+	sandbox := NewSandbox().
+		Allow(sandboxUser{}, "FullName", "String").
+		Allow(map[string]interface{}{}, "greet")
+
+	tests := []struct {
+		source string
+		output string
+		name   string // name of blocked call
+	}{
+		{`{{user.name}}`, "foo", ""},
+		{`{{user.fullName}} {{user.FullName}}`, "Mr foo Mr foo", ""},
+		{`{{greet}}`, "hello", ""},
+		{`{{user.delete}}`, "", "Delete"},
+		{`{{user.hook}}`, "", "Hook"},
+		{`{{shout}}`, "", "shout"},
+		{`{{list.[0]}}`, "", "0"},
+		{`{{> part}}`, "", "Delete"},
+		{`{{user}} {{upper ./user}}`, "foo FOO", ""},
+		{`{{tag}}`, "", "String"},
+		{`{{upper ./tag}}`, "", "String"},
+		{`{{lookup . "tag"}}`, "", "String"},
+		{`{{stamps}}`, "", "MarshalJSON"},
+	}
+
+	for _, test := range tests {
+		user := &sandboxUser{Name: "foo", Hook: func() string { return "hooked" }}
+		ctx := map[string]interface{}{
+			"user":   user,
+			"greet":  func() string { return "hello" },
+			"shout":  func() string { return "HEY" },
+			"list":   []func() string{func() string { return "item" }},
+			"tag":    sandboxTag{"go"},
+			"stamps": []sandboxStamp{1},
+		}
+
+		tpl := MustParse(test.source)
+		tpl.RegisterPartial("part", `{{user.delete}}`)
+		tpl.RegisterHelper("upper", strings.ToUpper)
+		tpl.SetSandbox(sandbox)
+
+		output, err := tpl.Exec(ctx)
+
+		if test.name == "" {
+			if err != nil {
+				t.Errorf("Unexpected error for %q: %s", test.source, err)
+			} else if output != test.output {
+				t.Errorf("Unexpected output for %q: %q", test.source, output)
+			}
+
+			continue
+		}
+
+		var cerr *CallNotAllowedError
+		if !errors.As(err, &cerr) || (cerr.Name != test.name) {
+			t.Errorf("Expected blocked call of %q for %q, got: %v", test.name, test.source, err)
+		}
+
+		if user.deleted {
+			t.Errorf("Blocked method was called for %q", test.source)
+		}
+	}
+
+	// without sandbox
+	tpl := MustParse(`{{user.delete}}`)
+	if output := tpl.MustExec(map[string]interface{}{"user": &sandboxUser{}}); output != "deleted" {
+		t.Errorf("Unexpected output without sandbox: %q", output)
+	}
+}
+
+func TestSandboxError(t *testing.T) {
+	t.Parallel()
+
+	tpl := MustParse("\n  {{user.delete}}")
+	tpl.SetSandbox(NewSandbox())
+
+	_, err := tpl.Exec(map[string]interface{}{"user": &sandboxUser{}})
+
+	var eerr *ExecError
+	if !errors.As(err, &eerr) || (eerr.Line != 2) {
+		t.Fatalf("ExecError expected, got: %#v", err)
+	}
+
+	expected := "Calling 'Delete' of raymond.sandboxUser is not allowed by sandbox"
+	if !strings.HasSuffix(err.Error(), expected) {
+		t.Errorf("Erroneous error message: %q", err.Error())
+	}
+
+	var cerr *CallNotAllowedError
+	if !errors.As(err, &cerr) || (cerr.Type != reflect.TypeOf(&sandboxUser{})) {
+		t.Errorf("CallNotAllowedError expected, got: %#v", err)
+	}
+}
@@ -0,0 +1,116 @@
+package raymond
+
+import (
+	"github.com/komand/raymond/ast"
+)
+
+// Span maps a range of rendered output to the template node that produced it, see Template.ExecSourceMap().
+type Span struct {
+	// Start is the byte offset of the span in output
+	Start int
+
+	// End is the byte offset following the span in output
+	End int
+
+	// Node is the statement that produced that output: content, mustache, block or partial
+	Node ast.Node
+
+	// Location is the location of that statement: template or partial name, line and column
+	Location ErrorLocation
+}
+
+// renderedProgram is the output of a program evaluation, with its spans
+type renderedProgram struct {
+	output string
+	spans  []Span
+}
+
+// sourceMapper records spans while evaluating a template
+//
+// Block and partial statements output the programs they render, possibly modified by helpers or partial indentation:
+// each rendered program is placed right after the previous one in statement output, and the spans of those programs
+// are kept only if the statement output is exactly the concatenation of their outputs.
+type sourceMapper struct {
+	// programs rendered by each statement being evaluated
+	rendered [][]renderedProgram
+}
+
+// newSourceMapper instanciates a new sourceMapper
+func newSourceMapper() *sourceMapper {
+	return &sourceMapper{
+		rendered: make([][]renderedProgram, 1),
+	}
+}
+
+// enterStatement must be called before evaluating a statement
+func (sm *sourceMapper) enterStatement() {
+	sm.rendered = append(sm.rendered, nil)
+}
+
+// exitStatement must be called after evaluating given statement that output given string at given offset of
+// program output, it adds the statement spans to given program spans
+func (sm *sourceMapper) exitStatement(spans []Span, tpl *Template, node ast.Node, output string, start int) []Span {
+	last := len(sm.rendered) - 1
+
+	programs := sm.rendered[last]
+	sm.rendered = sm.rendered[:last]
+
+	if output == "" {
+		return spans
+	}
+
+	spans = append(spans, Span{
+		Start:    start,
+		End:      start + len(output),
+		Node:     node,
+		Location: newErrorLocation(tpl, node),
+	})
+
+	// rendered programs must have been output as is, in order
+	offset := 0
+
+	for _, program := range programs {
+		end := offset + len(program.output)
+		if (end > len(output)) || (output[offset:end] != program.output) {
+			return spans
+		}
+
+		offset = end
+	}
+
+	if offset != len(output) {
+		return spans
+	}
+
+	// spans of rendered programs
+	offset = start
+
+	for _, program := range programs {
+		for _, span := range program.spans {
+			span.Start += offset
+			span.End += offset
+
+			spans = append(spans, span)
+		}
+
+		offset += len(program.output)
+	}
+
+	return spans
+}
+
+// addProgram must be called after evaluating a program
+func (sm *sourceMapper) addProgram(output string, spans []Span) {
+	last := len(sm.rendered) - 1
+
+	sm.rendered[last] = append(sm.rendered[last], renderedProgram{output: output, spans: spans})
+}
+
+// spans returns the spans of the evaluated template
+func (sm *sourceMapper) spans() []Span {
+	if programs := sm.rendered[0]; len(programs) > 0 {
+		return programs[len(programs)-1].spans
+	}
+
+	return nil
+}
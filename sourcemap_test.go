@@ -0,0 +1,96 @@
+package raymond
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestExecSourceMap(t *testing.T) {
+	t.Parallel()
+
+	tpl := MustParse("Hi {{name}}!\n{{#each items}}<{{.}}>{{/each}}{{> part}}{{#upper}}x{{/upper}}")
+	tpl.RegisterPartial("part", "[{{name}}]")
+	tpl.RegisterHelper("upper", func(options *Options) string { return strings.ToUpper(options.Fn()) })
+
+	output, spans, err := tpl.ExecSourceMap(map[string]interface{}{"name": "foo", "items": []int{1, 2}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if output != "Hi foo!\n<1><2>[foo]X" {
+		t.Errorf("Unexpected output: %q", output)
+	}
+
+	var result []string
+
+	for _, span := range spans {
+		result = append(result, fmt.Sprintf("%q %T %s", output[span.Start:span.End], span.Node, span.Location))
+	}
+
+	expected := []string{
+		`"Hi " *ast.ContentStatement line 1, column 1`,
+		`"foo" *ast.MustacheStatement line 1, column 4`,
+		`"!\n" *ast.ContentStatement line 1, column 12`,
+		`"<1><2>" *ast.BlockStatement line 2, column 1`,
+		`"<" *ast.ContentStatement line 2, column 16`,
+		`"1" *ast.MustacheStatement line 2, column 17`,
+		`">" *ast.ContentStatement line 2, column 22`,
+		`"<" *ast.ContentStatement line 2, column 16`,
+		`"2" *ast.MustacheStatement line 2, column 17`,
+		`">" *ast.ContentStatement line 2, column 22`,
+		`"[foo]" *ast.PartialStatement line 2, column 32`,
+		`"[" *ast.ContentStatement line 1, column 1 of template 'part'`,
+		`"foo" *ast.MustacheStatement line 1, column 2 of template 'part'`,
+		`"]" *ast.ContentStatement line 1, column 10 of template 'part'`,
+		`"X" *ast.BlockStatement line 2, column 42`,
+	}
+
+	if strings.Join(result, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("Unexpected spans:\n%s", strings.Join(result, "\n"))
+	}
+}
+
+func TestExecSourceMapWrappedBlock(t *testing.T) {
+	t.Parallel()
+
+	tpl := MustParse("x{{#if ok}}x{{/if}}{{#wrap}}x{{/wrap}}{{#twice}}x{{/twice}}")
+	tpl.RegisterHelper("wrap", func(options *Options) string { return "x[" + options.Fn() + "]" })
+	tpl.RegisterHelper("twice", func(options *Options) string { return options.Fn() + options.Fn() })
+
+	output, spans, err := tpl.ExecSourceMap(map[string]bool{"ok": true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var result []string
+
+	for _, span := range spans {
+		result = append(result, fmt.Sprintf("%d-%d %T", span.Start, span.End, span.Node))
+	}
+
+	// spans inside wrapped block are omitted, even if its output is found in block output
+	expected := []string{
+		"0-1 *ast.ContentStatement",
+		"1-2 *ast.BlockStatement",
+		"1-2 *ast.ContentStatement",
+		"2-6 *ast.BlockStatement",
+		"6-8 *ast.BlockStatement",
+		"6-7 *ast.ContentStatement",
+		"7-8 *ast.ContentStatement",
+	}
+
+	if (output != "xxx[x]xx") || (strings.Join(result, "\n") != strings.Join(expected, "\n")) {
+		t.Errorf("Unexpected spans for %q:\n%s", output, strings.Join(result, "\n"))
+	}
+}
+
+func TestExecSourceMapError(t *testing.T) {
+	t.Parallel()
+
+	tpl := MustParse("{{> missing}}")
+
+	if _, _, err := tpl.ExecSourceMap(nil); err == nil {
+		t.Errorf("Expected an error")
+	}
+}
@@ -22,6 +22,17 @@ func isSafeString(value interface{}) bool {
 
 // Str returns string representation of any basic type value.
 func Str(value interface{}) string {
+	switch val := value.(type) {
+	case string:
+		return val
+	case SafeString:
+		return string(val)
+	case int:
+		return strconv.Itoa(val)
+	case *jsonObject:
+		return val.str()
+	}
+
 	return strValue(reflect.ValueOf(value))
 }
 
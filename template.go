@@ -1,33 +1,63 @@
 package raymond
 
 import (
+	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"reflect"
 	"runtime"
 	"sync"
+	"sync/atomic"
 
 	"github.com/komand/raymond/ast"
 	"github.com/komand/raymond/parser"
 )
 
+// default mustache delimiters
+const (
+	defaultOpenDelim  = "{{"
+	defaultCloseDelim = "}}"
+)
+
 // Template represents a handlebars template.
 type Template struct {
-	source    string
-	program   *ast.Program
-	helpers   map[string]reflect.Value
-	partials  map[string]*partial
-	mutex     sync.RWMutex // protects helpers and partials
-	unescaped bool
+	// incremented each time a helper is registered, so that compiled helper calls are bound again
+	// @note First field, for 64-bit alignment of atomic operations
+	helpersVersion uint64
+
+	name       string
+	source     string
+	program    *ast.Program
+	helpers    map[string]reflect.Value
+	partials   map[string]*partial
+	decorators map[string]Decorator
+	mutex      sync.RWMutex // protects helpers, partials and decorators
+	unescaped  bool
+	escaper    func(string) string
+	strict     bool
+	mustache   bool // mustache lambdas semantics
+	fieldNames FieldNameResolver
+	sandbox    *Sandbox
+	tracer     Tracer
+	logger     Logger
+
+	// custom mustache delimiters
+	openDelim  string
+	closeDelim string
+
+	// compiled programs, shared with clones as they share the AST
+	compiled *programCache
 }
 
 // newTemplate instanciate a new template without parsing it
 func newTemplate(source string, unescaped bool) *Template {
 	return &Template{
-		source:    source,
-		helpers:   make(map[string]reflect.Value),
-		partials:  make(map[string]*partial),
-		unescaped: unescaped,
+		source:     source,
+		helpers:    make(map[string]reflect.Value),
+		partials:   make(map[string]*partial),
+		decorators: make(map[string]Decorator),
+		unescaped:  unescaped,
+		compiled:   newProgramCache(),
 	}
 }
 
@@ -67,6 +97,23 @@ func Parse(source string) (*Template, error) {
 	return tpl, nil
 }
 
+// ParseWithDelimiters instanciates a template by parsing given source, with given mustache delimiters instead of
+// {{ and }}, eg. "<%" and "%>". Delimiters can't contain whitespaces nor equal signs.
+//
+// Partials are still parsed with default delimiters.
+func ParseWithDelimiters(source string, open string, close string) (*Template, error) {
+	tpl := newTemplate(source, false)
+	tpl.openDelim = open
+	tpl.closeDelim = close
+
+	// parse template
+	if err := tpl.parse(); err != nil {
+		return nil, err
+	}
+
+	return tpl, nil
+}
+
 // MustParse instanciates a template by parsing given source. It panics on error.
 func MustParseTemplate(source string, unescaped bool) *Template {
 	result, err := ParseTemplate(source, unescaped)
@@ -88,7 +135,15 @@ func ParseFile(filePath string) (*Template, error) {
 		return nil, err
 	}
 
-	return ParseTemplate(string(b), false)
+	tpl := newTemplate(string(b), false)
+	tpl.name = filePath
+
+	// parse template
+	if err := tpl.parse(); err != nil {
+		return nil, err
+	}
+
+	return tpl, nil
 }
 
 // parse parses the template
@@ -98,9 +153,13 @@ func (tpl *Template) parse() error {
 	if tpl.program == nil {
 		var err error
 
-		tpl.program, err = parser.Parse(tpl.source, tpl.unescaped)
+		if tpl.openDelim != "" {
+			tpl.program, err = parser.ParseWithDelimiters(tpl.source, tpl.unescaped, tpl.openDelim, tpl.closeDelim)
+		} else {
+			tpl.program, err = parser.Parse(tpl.source, tpl.unescaped)
+		}
 		if err != nil {
-			return err
+			return newParseError(tpl, err)
 		}
 	}
 
@@ -111,7 +170,18 @@ func (tpl *Template) parse() error {
 func (tpl *Template) Clone() *Template {
 	result := newTemplate(tpl.source, tpl.unescaped)
 
+	result.name = tpl.name
 	result.program = tpl.program
+	result.escaper = tpl.escaper
+	result.strict = tpl.strict
+	result.mustache = tpl.mustache
+	result.fieldNames = tpl.fieldNames
+	result.sandbox = tpl.sandbox
+	result.tracer = tpl.tracer
+	result.logger = tpl.logger
+	result.openDelim = tpl.openDelim
+	result.closeDelim = tpl.closeDelim
+	result.compiled = tpl.compiled
 
 	tpl.mutex.RLock()
 	defer tpl.mutex.RUnlock()
@@ -124,6 +194,10 @@ func (tpl *Template) Clone() *Template {
 		result.addPartial(name, partial.source, partial.tpl)
 	}
 
+	for name, decorator := range tpl.decorators {
+		result.RegisterDecorator(name, decorator)
+	}
+
 	return result
 }
 
@@ -147,6 +221,8 @@ func (tpl *Template) RegisterHelper(name string, helper interface{}) {
 	ensureValidHelper(name, val)
 
 	tpl.helpers[name] = val
+
+	atomic.AddUint64(&tpl.helpersVersion, 1)
 }
 
 // RegisterHelpers registers several helpers for that template.
@@ -165,6 +241,9 @@ func (tpl *Template) addPartial(name string, source string, template *Template)
 	}
 
 	tpl.partials[name] = newPartial(name, source, template)
+	if template == nil {
+		tpl.partials[name].unescaped = tpl.unescaped
+	}
 }
 
 func (tpl *Template) findPartial(name string) *partial {
@@ -220,6 +299,104 @@ func (tpl *Template) RegisterPartialTemplate(name string, template *Template) {
 	tpl.addPartial(name, "", template)
 }
 
+func (tpl *Template) findDecorator(name string) Decorator {
+	tpl.mutex.RLock()
+	defer tpl.mutex.RUnlock()
+
+	return tpl.decorators[name]
+}
+
+// RegisterDecorator registers a decorator for that template.
+func (tpl *Template) RegisterDecorator(name string, decorator Decorator) {
+	tpl.mutex.Lock()
+	defer tpl.mutex.Unlock()
+
+	if tpl.decorators[name] != nil {
+		panic(fmt.Sprintf("Decorator %s already registered", name))
+	}
+
+	tpl.decorators[name] = decorator
+}
+
+// RegisterDecorators registers several decorators for that template.
+func (tpl *Template) RegisterDecorators(decorators map[string]Decorator) {
+	for name, decorator := range decorators {
+		tpl.RegisterDecorator(name, decorator)
+	}
+}
+
+// SetEscaper sets the function used to escape mustaches output, instead of the default HTML escaping with Escape().
+//
+// Partials evaluated by that template are escaped with that function too. Setting a nil escaper restores HTML escaping.
+func (tpl *Template) SetEscaper(escaper func(string) string) {
+	tpl.escaper = escaper
+}
+
+// SetStrict enables or disables strict mode. In strict mode, evaluating a path that is missing from context
+// fails with an ExecError instead of silently rendering an empty string.
+//
+// A path holding a nil value is not missing.
+func (tpl *Template) SetStrict(strict bool) {
+	tpl.strict = strict
+}
+
+// SetMustacheCompat enables or disables mustache compatibility mode. In that mode, context functions follow the
+// mustache lambdas semantics instead of being called as helpers:
+//
+//   - a func() string used in a mustache returns a template, that is rendered against current context,
+//   - a func(text string) string used as a section receives the unrendered section text, and returns a template
+//     that is rendered against current context, with the delimiters of that section.
+//
+// Other context functions are still called as helpers. A lambda used as an inverted section is truthy.
+func (tpl *Template) SetMustacheCompat(compat bool) {
+	tpl.mustache = compat
+}
+
+// SetFieldNameResolver sets the resolver used to map template names to struct fields, and to compute @key when
+// iterating over a struct with #each, eg. JSONTagFieldNames. Struct methods are not affected.
+//
+// Partials evaluated by that template use that resolver too. Setting a nil resolver restores the default
+// resolution: capitalized name (eg: firstName => FirstName), then `handlebars` struct tag, with Go field names as
+// @key. It panics if the resolver is not comparable.
+func (tpl *Template) SetFieldNameResolver(resolver FieldNameResolver) {
+	if (resolver != nil) && !reflect.TypeOf(resolver).Comparable() {
+		panic(fmt.Errorf("Field name resolver must be comparable: %T", resolver))
+	}
+
+	tpl.fieldNames = resolver
+}
+
+// SetSandbox restricts the methods and functions that template can call from its context to the ones allowed by
+// given sandbox. Calling any other one fails with an ExecError holding a CallNotAllowedError.
+//
+// Partials evaluated by that template are restricted too. Setting a nil sandbox removes restrictions.
+func (tpl *Template) SetSandbox(sandbox *Sandbox) {
+	tpl.sandbox = sandbox
+}
+
+// SetTracer sets the tracer notified of evaluation steps: nodes, helper calls, partials and path resolutions. Setting
+// a nil tracer disables tracing.
+//
+// Partials evaluated by that template are traced too.
+func (tpl *Template) SetTracer(tracer Tracer) {
+	tpl.tracer = tracer
+}
+
+// SetLogger sets the logger receiving the messages of the log helper, instead of the default
+// NewStdLogger(nil, LogLevelInfo) that writes to the standard logger. Use DataFrame.SetLogger() with ExecWith() to
+// set a logger for a single execution.
+//
+// Partials evaluated by that template use that logger too.
+func (tpl *Template) SetLogger(logger Logger) {
+	tpl.logger = logger
+}
+
+// Name returns the template name: the file path for a template parsed with ParseFile(), or the partial name
+// for a partial template. It is used in error messages.
+func (tpl *Template) Name() string {
+	return tpl.name
+}
+
 // Exec evaluates template with given context.
 func (tpl *Template) Exec(ctx interface{}) (result string, err error) {
 	return tpl.ExecWith(ctx, nil)
@@ -234,6 +411,18 @@ func (tpl *Template) MustExec(ctx interface{}) string {
 	return result
 }
 
+// ExecJSON evaluates template with given JSON data as context.
+//
+// JSON objects are decoded lazily, only the members referenced by the template are decoded. Numbers are kept as
+// json.Number so that they are output verbatim, and #each iterates over object members in order.
+func (tpl *Template) ExecJSON(data []byte) (string, error) {
+	if !json.Valid(data) {
+		return "", errInvalidJSON
+	}
+
+	return tpl.ExecWith(newJSONValue(data), nil)
+}
+
 // ExecWith evaluates template with given context and private data frame.
 func (tpl *Template) ExecWith(ctx interface{}, privData *DataFrame) (result string, err error) {
 	defer errRecover(&err)
@@ -248,7 +437,40 @@ func (tpl *Template) ExecWith(ctx interface{}, privData *DataFrame) (result stri
 	v := newEvalVisitor(tpl, ctx, privData)
 
 	// visit AST
-	result, _ = tpl.program.Accept(v).(string)
+	result = v.program(tpl.program)(v)
+
+	// on error, visitor is not recycled as its state is unknown
+	v.release()
+
+	// named return values
+	return
+}
+
+// ExecSourceMap evaluates template with given context, and returns the spans mapping output byte ranges to the
+// statements that produced them.
+//
+// Spans are ordered by start offset, and a span is followed by the spans it contains: the spans of the blocks and
+// partials it renders. When a block helper modifies the output of its block, or when a partial is indented, the spans
+// inside that block or partial are omitted.
+func (tpl *Template) ExecSourceMap(ctx interface{}) (result string, spans []Span, err error) {
+	defer errRecover(&err)
+
+	// parses template if necessary
+	err = tpl.parse()
+	if err != nil {
+		return
+	}
+
+	// setup visitor
+	v := newEvalVisitor(tpl, ctx, nil)
+	v.sourceMap = newSourceMapper()
+
+	// visit AST
+	result = v.program(tpl.program)(v)
+	spans = v.sourceMap.spans()
+
+	// on error, visitor is not recycled as its state is unknown
+	v.release()
 
 	// named return values
 	return
@@ -278,10 +500,15 @@ func (tpl *Template) PrintAST() string {
 	return ast.Print(tpl.program)
 }
 
-// Print returns string representation of parsed template.
+// Print returns the template source regenerated from its AST.
+//
+// Unmodified nodes are printed exactly as in source, so that it is possible to save a template after calling Rename().
 func (tpl *Template) Print() string {
-	// XXX
-	return ast.PrintOriginal(tpl.program)
+	if err := tpl.parse(); err != nil {
+		return fmt.Sprintf("PARSER ERROR: %s", err)
+	}
+
+	return ast.PrintSource(tpl.program, tpl.source)
 }
 
 // Validate
@@ -289,25 +516,38 @@ func (tpl *Template) Validate(variables map[string]struct{}) (err error) {
 	// parses template if necessary
 	err = tpl.parse()
 	if err != nil {
-		return fmt.Errorf("Template could not be parsed: %s", err)
+		return fmt.Errorf("Template could not be parsed: %w", err)
 	}
 
 	// setup visitor
 	v := newValidateVisitor(tpl, variables)
 
 	// visit AST
-	err, _ = tpl.program.Accept(v).(error)
+	tpl.program.Accept(v)
+	err = v.err
 
 	// named return values
 	return err
 }
 
+// References returns all context paths, helpers and partials referenced by template, in source order.
+func (tpl *Template) References() ([]Reference, error) {
+	if err := tpl.parse(); err != nil {
+		return nil, err
+	}
+
+	v := newReferencesVisitor(tpl)
+	tpl.program.Accept(v)
+
+	return v.result, nil
+}
+
 // Rename
 func (tpl *Template) Rename(variables map[string]string) (err error) {
 	// parses template if necessary
 	err = tpl.parse()
 	if err != nil {
-		return fmt.Errorf("Template could not be parsed: %s", err)
+		return fmt.Errorf("Template could not be parsed: %w", err)
 	}
 
 	defer errRecover(&err)
@@ -316,7 +556,10 @@ func (tpl *Template) Rename(variables map[string]string) (err error) {
 	v := newRenameVisitor(tpl, variables)
 
 	// visit AST
-	err, _ = tpl.program.Accept(v).(error)
+	tpl.program.Accept(v)
+
+	// renamed paths must be compiled again
+	tpl.compiled.reset()
 
 	// named return values
 	return err
@@ -2,6 +2,7 @@ package raymond
 
 import (
 	"fmt"
+	"strings"
 	"testing"
 )
 
@@ -46,6 +47,27 @@ func TestParse(t *testing.T) {
 	}
 }
 
+func TestParseWithDelimiters(t *testing.T) {
+	t.Parallel()
+
+	tpl, err := ParseWithDelimiters("<%#each items%>- {{ .Values.<%.%> }} <%> tag%>\n<%/each%>", "<%", "%>")
+	if err != nil {
+		t.Fatalf("Failed to parse template: %s", err)
+	}
+
+	// partials are parsed with default delimiters
+	tpl.RegisterPartial("tag", "[{{.}}]")
+
+	expected := "- {{ .Values.foo }} [foo]\n- {{ .Values.bar }} [bar]\n"
+	if output := tpl.Clone().MustExec(map[string][]string{"items": {"foo", "bar"}}); output != expected {
+		t.Errorf("Test failed\nexpected\n\t%q\ngot\n\t%q", expected, output)
+	}
+
+	if _, err := ParseWithDelimiters("foo", "<%", "%= >"); err == nil {
+		t.Errorf("Expected an error with invalid delimiters")
+	}
+}
+
 func TestClone(t *testing.T) {
 	t.Parallel()
 
@@ -76,6 +98,182 @@ func TestClone(t *testing.T) {
 	}
 }
 
+var strictTests = []struct {
+	name   string
+	input  string
+	output string
+	err    string
+}{
+	{"present paths", "{{title}} {{author.name}}{{nothing}}{{#each tags}}{{.}}{{/each}}", "Hi Johnab", ""},
+	{"missing field", "{{title}} {{titel}}", "", "Evaluation error on line 1, column 13: Missing field 'titel'"},
+	{"missing nested field", "{{#if author.age}}old{{/if}}", "", "Evaluation error on line 1, column 7: Missing field 'author.age'"},
+	{"missing index", "{{tags.[5]}}", "", "Evaluation error on line 1, column 3: Missing field 'tags.[5]'"},
+	{"missing private data", "{{#with author}}{{@index}}{{/with}}", "", "Evaluation error on line 1, column 19: Missing field '@index'"},
+	{"helper", "{{#with author}}{{name}}{{/with}}", "John", ""},
+	{"missing parent context", "{{../title}}", "", "Evaluation error on line 1, column 3: Missing field '../title'"},
+}
+
+func TestStrict(t *testing.T) {
+	t.Parallel()
+
+	ctx := map[string]interface{}{
+		"title":   "Hi",
+		"author":  map[string]string{"name": "John"},
+		"nothing": nil,
+		"tags":    []string{"a", "b"},
+	}
+
+	for _, test := range strictTests {
+		tpl := MustParse(test.input)
+		tpl.SetStrict(true)
+
+		output, err := tpl.Clone().Exec(ctx)
+
+		errMsg := ""
+		if err != nil {
+			errMsg = err.Error()
+		}
+
+		if (output != test.output) || (errMsg != test.err) {
+			t.Errorf("Test '%s' failed\ninput:\n\t%s\nexpected\n\t%q %q\ngot\n\t%q %q", test.name, test.input, test.output, test.err, output, errMsg)
+		}
+	}
+}
+
+var printTests = []struct {
+	name      string
+	input     string
+	variables map[string]string
+	output    string
+}{
+	{
+		"unmodified template",
+		"{{~ title }} {{{body}}} {{> (lookup . 'p') foo=\"bar\" }}{{!-- comment --}}{{{{raw}}}} {{x}} {{{{/raw}}}}",
+		nil,
+		"{{~ title }} {{{body}}} {{> (lookup . 'p') foo=\"bar\" }}{{!-- comment --}}{{{{raw}}}} {{x}} {{{{/raw}}}}",
+	},
+	{
+		"string ending with an escaped backslash",
+		"{{foo \"a\\\\\" step1 'b\\\\\\'c'}}",
+		map[string]string{"step1": "step2"},
+		"{{foo \"a\\\\\" step2 'b\\\\\\'c'}}",
+	},
+	{
+		"renamed variables",
+		"Hello {{~ [step 1].name }} {{#if step2.ok as |ok| ~}} yes {{else if step2.ko}}no{{/if}}{{! keep me }}",
+		map[string]string{"step 1": "step one", "step2": "step3"},
+		"Hello {{~ [step one].name }} {{#if step3.ok as |ok| ~}} yes {{else if step3.ko}}no{{/if}}{{! keep me }}",
+	},
+	{
+		"renamed block name",
+		"{{#step1.items}} - {{name}}{{~/step1.items}}",
+		map[string]string{"step1": "step2"},
+		"{{#step2.items}} - {{name}}{{~/step2.items}}",
+	},
+	{
+		"hash values and partial params",
+		"{{{link \"x\" url=step1.url}}} {{> row step1 title=(lookup step1 \"title\")}}",
+		map[string]string{"step1": "step2"},
+		"{{{link \"x\" url=step2.url}}} {{> row step2 title=(lookup step2 \"title\")}}",
+	},
+	{
+		"renamed whole path segments",
+		"{{step1.name}} {{step10.name}} {{[step1].x}} {{step1}}",
+		map[string]string{"step1": "step2"},
+		"{{step2.name}} {{step10.name}} {{step2.x}} {{step2}}",
+	},
+	{
+		"renamed dotted variables",
+		"{{step1.output.x}} {{step1.outputs}} {{step1.x}}",
+		map[string]string{"step1": "step2", "step1.output": "step 3.result"},
+		"{{[step 3].result.x}} {{step2.outputs}} {{step2.x}}",
+	},
+	{
+		"renamed @root paths",
+		"{{#each step1.items}}{{@root.step1.name}}{{@index}}{{@step1}}{{/each}}",
+		map[string]string{"step1": "step2"},
+		"{{#each step2.items}}{{@root.step2.name}}{{@index}}{{@step1}}{{/each}}",
+	},
+	{
+		"block params and relative paths",
+		"{{#each step1.items as |step1|}}{{step1.name}}{{/each}}{{#with step1}}{{this.step1}}{{../step1}}{{/with}}",
+		map[string]string{"step1": "step2"},
+		"{{#each step2.items as |step1|}}{{step1.name}}{{/each}}{{#with step2}}{{this.step1}}{{../step1}}{{/with}}",
+	},
+	{
+		"inline partial",
+		"{{#*inline \"row\" ~}} {{step1.name}} {{~/inline}}{{> row}}",
+		map[string]string{"step1": "step2"},
+		"{{#*inline \"row\" ~}} {{step2.name}} {{~/inline}}{{> row}}",
+	},
+	{
+		"decorator",
+		"{{~* locale step1.lang }}{{step1.name}}",
+		map[string]string{"step1": "step2"},
+		"{{~* locale step2.lang }}{{step2.name}}",
+	},
+	{
+		"partial block",
+		"{{#> layout step1 }}{{step1.name}}{{~/layout}}",
+		map[string]string{"step1": "step2"},
+		"{{#> layout step2 }}{{step2.name}}{{~/layout}}",
+	},
+}
+
+func TestPrint(t *testing.T) {
+	t.Parallel()
+
+	for _, test := range printTests {
+		tpl := MustParse(test.input)
+
+		if test.variables != nil {
+			if err := tpl.Rename(test.variables); err != nil {
+				t.Errorf("Test '%s' failed - Failed to rename variables: %s", test.name, err)
+				continue
+			}
+		}
+
+		if output := tpl.Print(); output != test.output {
+			t.Errorf("Test '%s' failed\ninput:\n\t%q\nexpected\n\t%q\ngot\n\t%q", test.name, test.input, test.output, output)
+		}
+	}
+}
+
+var validateTests = []struct {
+	name  string
+	input string
+	err   string
+}{
+	{"valid paths", "{{step1.name}} {{[step 2].name}} {{#each step1.items}}{{name}}{{/each}}", ""},
+	{"invalid path", "{{step1.name}} {{step3.name}}", "Invalid variable reference step3.name"},
+	{"invalid hash value", "{{{lookup step1 \"name\" default=step3.name}}}", "Invalid variable reference step3.name"},
+	{"whole path segments", "{{step1.name}} {{step10.name}}", "Invalid variable reference step10.name"},
+	{"@root paths", "{{@root.step1.name}} {{@root.[step 2]}} {{@root}}", ""},
+	{"invalid @root path", "{{@root.step3.name}}", "Invalid variable reference @root.step3.name"},
+	{"block params", "{{#each step1.items as |item|}}{{item.name}}{{/each}}", ""},
+	{"partial params", "{{> row step1 title=step3.title}}", "Invalid variable reference step3.title"},
+	{"helpers and private data", "{{#if step1.ok}}{{@index}}{{/if}}{{{lookup step1 \"name\"}}}", ""},
+}
+
+func TestValidate(t *testing.T) {
+	t.Parallel()
+
+	variables := map[string]struct{}{"step1": {}, "step 2": {}}
+
+	for _, test := range validateTests {
+		err := MustParse(test.input).Validate(variables)
+
+		errMsg := ""
+		if err != nil {
+			errMsg = err.Error()
+		}
+
+		if !strings.HasPrefix(errMsg, test.err) || ((errMsg == "") != (test.err == "")) {
+			t.Errorf("Test '%s' failed\ninput:\n\t%s\nexpected error\n\t%q\ngot\n\t%q", test.name, test.input, test.err, errMsg)
+		}
+	}
+}
+
 func ExampleTemplate_Exec() {
 	source := "<h1>{{title}}</h1><p>{{body.content}}</p>"
 
@@ -0,0 +1,84 @@
+package raymond
+
+import (
+	"reflect"
+	"time"
+
+	"github.com/komand/raymond/ast"
+)
+
+// Tracer is notified of template evaluation steps, see Template.SetTracer().
+//
+// Tracer methods are called synchronously, from the goroutine evaluating the template.
+type Tracer interface {
+	// EnterNode is called before evaluating a program or a statement.
+	EnterNode(node ast.Node)
+
+	// ExitNode is called after evaluating a program or a statement, even if that evaluation failed.
+	ExitNode(node ast.Node)
+
+	// HelperCall is called after a helper or a context function returns. Calls that fail are not traced.
+	HelperCall(call HelperTrace)
+
+	// EnterPartial is called before evaluating the partial with given name.
+	EnterPartial(name string)
+
+	// ResolvePath is called after evaluating a path expression, eg. "author.name", with resolved set to true if it
+	// resolved to a non nil value, and the type of that value.
+	ResolvePath(path string, resolved bool, typ reflect.Type)
+}
+
+// HelperTrace describes a helper or a context function call.
+type HelperTrace struct {
+	// Name is the helper name, or the context function name
+	Name string
+
+	// Params are the call parameters
+	Params []interface{}
+
+	// Hash is the call hash
+	Hash map[string]interface{}
+
+	// Result is the value returned
+	Result interface{}
+
+	// Duration is the call duration
+	Duration time.Duration
+}
+
+// NopTracer is a Tracer that does nothing, to be embedded by tracers that only implement some methods.
+type NopTracer struct{}
+
+// EnterNode implements Tracer
+func (NopTracer) EnterNode(node ast.Node) {}
+
+// ExitNode implements Tracer
+func (NopTracer) ExitNode(node ast.Node) {}
+
+// HelperCall implements Tracer
+func (NopTracer) HelperCall(call HelperTrace) {}
+
+// EnterPartial implements Tracer
+func (NopTracer) EnterPartial(name string) {}
+
+// ResolvePath implements Tracer
+func (NopTracer) ResolvePath(path string, resolved bool, typ reflect.Type) {}
+
+// traceHelper notifies tracer of given helper call, options are nil if the helper does not take them
+func (v *evalVisitor) traceHelper(name string, params []interface{}, options *Options, result interface{}, start time.Time) {
+	call := HelperTrace{
+		Name:     name,
+		Params:   params,
+		Result:   result,
+		Duration: time.Since(start),
+	}
+
+	if options == nil {
+		// params are on the args stack
+		call.Params = append([]interface{}(nil), params...)
+	} else {
+		call.Hash = options.hash
+	}
+
+	v.tracer.HelperCall(call)
+}
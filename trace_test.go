@@ -0,0 +1,153 @@
+package raymond
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/komand/raymond/ast"
+)
+
+// recordTracer records traced events
+type recordTracer struct {
+	events []string
+}
+
+func (tracer *recordTracer) EnterNode(node ast.Node) {
+	tracer.events = append(tracer.events, fmt.Sprintf("enter %T", node))
+}
+
+func (tracer *recordTracer) ExitNode(node ast.Node) {
+	tracer.events = append(tracer.events, fmt.Sprintf("exit %T", node))
+}
+
+func (tracer *recordTracer) HelperCall(call HelperTrace) {
+	tracer.events = append(tracer.events, fmt.Sprintf("helper %s %v %v => %v", call.Name, call.Params, call.Hash, call.Result))
+}
+
+func (tracer *recordTracer) EnterPartial(name string) {
+	tracer.events = append(tracer.events, fmt.Sprintf("partial %s", name))
+}
+
+func (tracer *recordTracer) ResolvePath(path string, resolved bool, typ reflect.Type) {
+	tracer.events = append(tracer.events, fmt.Sprintf("path %s %t %v", path, resolved, typ))
+}
+
+func TestTracer(t *testing.T) {
+	t.Parallel()
+
+	tpl := MustParse(`a{{foo}}{{#if bar}}{{> part}}{{/if}}`)
+	tpl.RegisterHelper("upper", func(options *Options) string { return "UP" })
+	tpl.RegisterPartial("part", `{{upper}}{{missing}}`)
+
+	tracer := &recordTracer{}
+	tpl.SetTracer(tracer)
+
+	output := tpl.MustExec(map[string]interface{}{"foo": 1, "bar": true})
+	if output != "a1UP" {
+		t.Errorf("Unexpected output: %q", output)
+	}
+
+	expected := []string{
+		"enter *ast.Program",
+		"enter *ast.ContentStatement",
+		"exit *ast.ContentStatement",
+		"enter *ast.MustacheStatement",
+		"path foo true int",
+		"exit *ast.MustacheStatement",
+		"enter *ast.BlockStatement",
+		"path bar true bool",
+		"helper if [true] map[] => UP",
+		"exit *ast.BlockStatement",
+		"exit *ast.Program",
+	}
+
+	// nested events of #if block
+	nested := []string{
+		"enter *ast.Program",
+		"enter *ast.PartialStatement",
+		"partial part",
+		"enter *ast.Program",
+		"enter *ast.MustacheStatement",
+		"helper upper [] map[] => UP",
+		"exit *ast.MustacheStatement",
+		"enter *ast.MustacheStatement",
+		"path missing false <nil>",
+		"exit *ast.MustacheStatement",
+		"exit *ast.Program",
+		"exit *ast.PartialStatement",
+		"exit *ast.Program",
+	}
+
+	expected = append(expected[:8], append(nested, expected[8:]...)...)
+
+	if strings.Join(tracer.events, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("Unexpected traced events:\n%s", strings.Join(tracer.events, "\n"))
+	}
+}
+
+// helpersTracer only counts helper calls
+type helpersTracer struct {
+	NopTracer
+	calls map[string]int
+}
+
+func (tracer helpersTracer) HelperCall(call HelperTrace) {
+	tracer.calls[call.Name]++
+}
+
+func TestNopTracer(t *testing.T) {
+	t.Parallel()
+
+	tracer := helpersTracer{calls: make(map[string]int)}
+
+	tpl := MustParse(`{{#each items}}{{#if .}}{{.}}{{/if}}{{/each}}`)
+	tpl.SetTracer(tracer)
+
+	if output := tpl.MustExec(map[string][]int{"items": {1, 0, 2}}); output != "12" {
+		t.Errorf("Unexpected output: %q", output)
+	}
+
+	if (tracer.calls["each"] != 1) || (tracer.calls["if"] != 3) {
+		t.Errorf("Unexpected traced helper calls: %v", tracer.calls)
+	}
+}
+
+func TestTracerError(t *testing.T) {
+	t.Parallel()
+
+	tpl := MustParse(`{{#each items}}<{{check .}}>{{/each}}`)
+	tpl.RegisterHelper("check", func(item string) string {
+		if item == "" {
+			panic(errors.New("empty item"))
+		}
+		return item
+	})
+
+	tracer := &recordTracer{}
+	tpl.SetTracer(tracer)
+
+	if _, err := tpl.Exec(map[string]interface{}{"items": []string{"a", ""}}); err == nil {
+		t.Fatalf("Expected an error")
+	}
+
+	// every entered node is exited, in reverse order
+	var entered []string
+	for _, event := range tracer.events {
+		if strings.HasPrefix(event, "enter ") {
+			entered = append(entered, strings.TrimPrefix(event, "enter "))
+		} else if strings.HasPrefix(event, "exit ") {
+			last := len(entered) - 1
+			if (last < 0) || (entered[last] != strings.TrimPrefix(event, "exit ")) {
+				t.Fatalf("Unbalanced traced events:\n%s", strings.Join(tracer.events, "\n"))
+			}
+			entered = entered[:last]
+		}
+	}
+
+	if len(entered) != 0 {
+		t.Errorf("Nodes not exited after error: %v", entered)
+	}
+}
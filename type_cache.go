@@ -0,0 +1,122 @@
+package raymond
+
+import (
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// typeInfo caches the results of resolving template names against a type
+type typeInfo struct {
+	typ      reflect.Type
+	resolver bool     // implements Resolver
+	fields   sync.Map // string => []int (nil if no field matches)
+	methods  sync.Map // string => int (-1 if no method matches)
+
+	// with a custom FieldNameResolver
+	resolved       sync.Map // resolvedName => []int (nil if no field matches)
+	resolvedFields sync.Map // FieldNameResolver => []reflect.StructField
+}
+
+// resolvedName is a template name resolved with a custom FieldNameResolver
+type resolvedName struct {
+	resolver FieldNameResolver
+	name     string
+}
+
+// typeInfos stores typeInfo by reflect.Type
+var typeInfos sync.Map
+
+// typeInfoFor returns the cached informations for given type
+func typeInfoFor(typ reflect.Type) *typeInfo {
+	if info, ok := typeInfos.Load(typ); ok {
+		return info.(*typeInfo)
+	}
+
+	info, _ := typeInfos.LoadOrStore(typ, &typeInfo{typ: typ, resolver: typ.Implements(resolverType)})
+	return info.(*typeInfo)
+}
+
+// fieldIndex returns the index of the struct field matching given template name, or nil if not found
+//
+// The exported field named after the capitalized name is looked up first (eg: firstName => FirstName), then
+// the first field with a `handlebars` struct tag equal to that name.
+func (info *typeInfo) fieldIndex(name string) []int {
+	if index, ok := info.fields.Load(name); ok {
+		return index.([]int)
+	}
+
+	var index []int
+
+	if field, ok := info.typ.FieldByName(strings.Title(name)); ok && (field.PkgPath == "") {
+		index = field.Index
+	} else {
+		for i := 0; i < info.typ.NumField(); i++ {
+			if info.typ.Field(i).Tag.Get("handlebars") == name {
+				index = []int{i}
+				break
+			}
+		}
+	}
+
+	info.fields.Store(name, index)
+
+	return index
+}
+
+// visibleFields returns the struct fields visible with given resolver
+func (info *typeInfo) visibleFields(resolver FieldNameResolver) []reflect.StructField {
+	if fields, ok := info.resolvedFields.Load(resolver); ok {
+		return fields.([]reflect.StructField)
+	}
+
+	fields := structFields(info.typ, resolver)
+	info.resolvedFields.Store(resolver, fields)
+
+	return fields
+}
+
+// resolvedFieldIndex returns the index of the first struct field matching given template name with given resolver,
+// or nil if not found
+func (info *typeInfo) resolvedFieldIndex(resolver FieldNameResolver, name string) []int {
+	key := resolvedName{resolver, name}
+
+	if index, ok := info.resolved.Load(key); ok {
+		return index.([]int)
+	}
+
+	var index []int
+
+	for _, field := range info.visibleFields(resolver) {
+		if resolver.Match(field, name) {
+			index = field.Index
+			break
+		}
+	}
+
+	info.resolved.Store(key, index)
+
+	return index
+}
+
+// methodIndex returns the index of the method matching given template name, or -1 if not found
+//
+// The method with that exact name is looked up first, then the method named after the capitalized name
+// (eg: subject => Subject).
+func (info *typeInfo) methodIndex(name string) int {
+	if index, ok := info.methods.Load(name); ok {
+		return index.(int)
+	}
+
+	index := -1
+
+	if method, ok := info.typ.MethodByName(name); ok {
+		index = method.Index
+	} else if method, ok := info.typ.MethodByName(strings.Title(name)); ok {
+		index = method.Index
+	}
+
+	info.methods.Store(name, index)
+
+	return index
+}
@@ -0,0 +1,56 @@
+package raymond
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+)
+
+type typeCacheInner struct {
+	Value string
+}
+
+type typeCacheCtx struct {
+	FirstName string
+	Tagged    string `handlebars:"nick-name"`
+	secret    string
+	typeCacheInner
+}
+
+func (ctx *typeCacheCtx) Subject() string {
+	return "subject of " + ctx.FirstName
+}
+
+func TestTypeCache(t *testing.T) {
+	t.Parallel()
+
+	tpl := MustParse(`{{firstName}} {{FirstName}} {{nick-name}} {{subject}} {{value}} [{{secret}}] [{{missing}}]`)
+
+	var wg sync.WaitGroup
+
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			ctx := &typeCacheCtx{"Jean", "JJ", "hidden", typeCacheInner{"inner"}}
+
+			expected := "Jean Jean JJ subject of Jean inner [] []"
+			if output := tpl.MustExec(ctx); output != expected {
+				t.Errorf("Failed to evaluate struct context, expected: %q, got: %q", expected, output)
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	info := typeInfoFor(reflect.TypeOf(typeCacheCtx{}))
+	if index := info.fieldIndex("missing"); index != nil {
+		t.Errorf("Unexpected field index for missing field: %v", index)
+	}
+
+	if index := info.methodIndex("missing"); index != -1 {
+		t.Errorf("Unexpected method index for missing method: %d", index)
+	}
+}
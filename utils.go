@@ -3,6 +3,7 @@ package raymond
 import (
 	"path"
 	"reflect"
+	"strconv"
 )
 
 // indirect returns the item at the end of indirection, and a bool to indicate if it's nil.
@@ -40,6 +41,11 @@ func isTrueValue(val reflect.Value) (truth, ok bool) {
 		// Something like var x interface{}, never set. It's a form of nil.
 		return false, true
 	}
+	if val.Type() == jsonNumberType {
+		// JSON number from ExecJSON()
+		f, err := strconv.ParseFloat(val.String(), 64)
+		return (err == nil) && (f != 0), true
+	}
 	switch val.Kind() {
 	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
 		truth = val.Len() > 0